@@ -0,0 +1,192 @@
+package ewmh
+
+/*
+ewmh/compositing.go contains the properties and client messages used between
+clients and compositing managers: _NET_WM_BYPASS_COMPOSITOR, the
+_NET_WM_CM_Sn selection, the _NET_WM_FRAME_DRAWN and _NET_WM_FRAME_TIMINGS
+messages of extended frame synchronization, and _GTK_FRAME_EXTENTS (which
+isn't part of the EWMH, but is how windows with client-side decorations
+describe their shadows).
+*/
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/icccm"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// _NET_WM_BYPASS_COMPOSITOR values. BypassCompositorOn asks the compositor
+// to unredirect the window (e.g., a fullscreen game or video) and
+// BypassCompositorOff asks it never to do so.
+const (
+	BypassCompositorNoPreference = iota
+	BypassCompositorOn
+	BypassCompositorOff
+)
+
+// WmFrameTimingsUnknown is the presentation offset of a
+// _NET_WM_FRAME_TIMINGS message when the compositor doesn't know when the
+// frame was presented.
+const WmFrameTimingsUnknown = -1 << 31
+
+// _NET_WM_BYPASS_COMPOSITOR get
+func WmBypassCompositorGet(xu *xgbutil.XUtil, win xproto.Window) (uint, error) {
+	return xprop.PropValNum(xprop.GetProperty(xu, win,
+		"_NET_WM_BYPASS_COMPOSITOR"))
+}
+
+// _NET_WM_BYPASS_COMPOSITOR set
+func WmBypassCompositorSet(xu *xgbutil.XUtil, win xproto.Window,
+	bypass uint) error {
+
+	return xprop.ChangeProp32(xu, win, "_NET_WM_BYPASS_COMPOSITOR",
+		"CARDINAL", bypass)
+}
+
+// WmCmSelectionName returns the name of the selection owned by the
+// compositing manager of the default screen. e.g., "_NET_WM_CM_S0".
+func WmCmSelectionName(xu *xgbutil.XUtil) string {
+	return icccm.ManagerSelectionName(xu, "_NET_WM_CM_S")
+}
+
+// WmCmOwnerGet returns the window owning the _NET_WM_CM_Sn selection of the
+// default screen, or 0 if no compositing manager is running. A compositing
+// manager can acquire the selection with icccm.AcquireManagerSelection.
+func WmCmOwnerGet(xu *xgbutil.XUtil) (xproto.Window, error) {
+	return icccm.ManagerSelectionOwner(xu, WmCmSelectionName(xu))
+}
+
+// IsCompositing returns whether a compositing manager is running on the
+// default screen. If the selection owner can't be found, it is assumed that
+// there is no compositing manager.
+func IsCompositing(xu *xgbutil.XUtil) bool {
+	owner, err := WmCmOwnerGet(xu)
+	return err == nil && owner != 0
+}
+
+// _GTK_FRAME_EXTENTS get
+// These are the sizes of the invisible borders (usually shadows) drawn by a
+// client around its client-side decorations, which shouldn't be taken into
+// account when placing or tiling the window.
+func GtkFrameExtentsGet(xu *xgbutil.XUtil,
+	win xproto.Window) (*FrameExtents, error) {
+
+	raw, err := xprop.PropValNums(xprop.GetProperty(xu, win,
+		"_GTK_FRAME_EXTENTS"))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != 4 {
+		return nil, fmt.Errorf("GtkFrameExtentsGet: There must be exactly "+
+			"four values in _GTK_FRAME_EXTENTS, but there are %d.", len(raw))
+	}
+
+	return &FrameExtents{
+		Left:   int(raw[0]),
+		Right:  int(raw[1]),
+		Top:    int(raw[2]),
+		Bottom: int(raw[3]),
+	}, nil
+}
+
+// _GTK_FRAME_EXTENTS set
+func GtkFrameExtentsSet(xu *xgbutil.XUtil, win xproto.Window,
+	extents *FrameExtents) error {
+
+	return xprop.ChangeProp32(xu, win, "_GTK_FRAME_EXTENTS", "CARDINAL",
+		uint(extents.Left), uint(extents.Right), uint(extents.Top),
+		uint(extents.Bottom))
+}
+
+// WmFrameDrawnMsg is a _NET_WM_FRAME_DRAWN message, sent by the compositing
+// manager to a client using extended frame synchronization once it has drawn
+// the frame that ended with the extended counter set to Counter. Drawn is
+// the time the frame was drawn, in microseconds of the monotonic clock.
+type WmFrameDrawnMsg struct {
+	Window  xproto.Window
+	Counter uint64
+	Drawn   uint64
+}
+
+// WmFrameTimingsMsg is a _NET_WM_FRAME_TIMINGS message, sent by the
+// compositing manager after a frame has been presented on the screen.
+// All times are in microseconds:
+//
+// PresentationOffset is the time the frame was presented relative to the
+// time in its _NET_WM_FRAME_DRAWN message, or WmFrameTimingsUnknown.
+// RefreshInterval is the refresh interval of the monitor, or 0 if unknown.
+// Delay is how long before the next frame is presented the compositor
+// starts drawing it, or 0 if unknown.
+type WmFrameTimingsMsg struct {
+	Window             xproto.Window
+	Counter            uint64
+	PresentationOffset int
+	RefreshInterval    uint
+	Delay              uint
+}
+
+// _NET_WM_FRAME_DRAWN
+func WmFrameDrawn(xu *xgbutil.XUtil, msg WmFrameDrawnMsg) error {
+	return xevent.SendClientMessage(xu, msg.Window, msg.Window,
+		"_NET_WM_FRAME_DRAWN",
+		int(msg.Counter&0xffffffff), int(msg.Counter>>32),
+		int(msg.Drawn&0xffffffff), int(msg.Drawn>>32))
+}
+
+// _NET_WM_FRAME_DRAWN decode
+func WmFrameDrawnDecode(xu *xgbutil.XUtil,
+	ev xevent.ClientMessageEvent) (WmFrameDrawnMsg, bool) {
+
+	if !isClientMessage(xu, ev, "_NET_WM_FRAME_DRAWN") {
+		return WmFrameDrawnMsg{}, false
+	}
+	d := ev.Data.Data32
+	return WmFrameDrawnMsg{
+		Window:  ev.Window,
+		Counter: uint64(d[0]) | uint64(d[1])<<32,
+		Drawn:   uint64(d[2]) | uint64(d[3])<<32,
+	}, true
+}
+
+// _NET_WM_FRAME_TIMINGS
+func WmFrameTimings(xu *xgbutil.XUtil, msg WmFrameTimingsMsg) error {
+	return xevent.SendClientMessage(xu, msg.Window, msg.Window,
+		"_NET_WM_FRAME_TIMINGS",
+		int(msg.Counter&0xffffffff), int(msg.Counter>>32),
+		int(uint32(int32(msg.PresentationOffset))), int(msg.RefreshInterval),
+		int(msg.Delay))
+}
+
+// _NET_WM_FRAME_TIMINGS decode
+func WmFrameTimingsDecode(xu *xgbutil.XUtil,
+	ev xevent.ClientMessageEvent) (WmFrameTimingsMsg, bool) {
+
+	if !isClientMessage(xu, ev, "_NET_WM_FRAME_TIMINGS") {
+		return WmFrameTimingsMsg{}, false
+	}
+	d := ev.Data.Data32
+	return WmFrameTimingsMsg{
+		Window:             ev.Window,
+		Counter:            uint64(d[0]) | uint64(d[1])<<32,
+		PresentationOffset: int(int32(d[2])),
+		RefreshInterval:    uint(d[3]),
+		Delay:              uint(d[4]),
+	}, true
+}
+
+// isClientMessage returns whether 'ev' is a 32 bit client message of type
+// 'typ'.
+func isClientMessage(xu *xgbutil.XUtil, ev xevent.ClientMessageEvent,
+	typ string) bool {
+
+	if ev.Format != 32 {
+		return false
+	}
+	name, err := xprop.AtomName(xu, ev.Type)
+	return err == nil && name == typ
+}
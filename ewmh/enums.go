@@ -0,0 +1,400 @@
+package ewmh
+
+/*
+ewmh/enums.go contains typed alternatives to the lists of atom names found in
+the _NET_WM_STATE, _NET_WM_WINDOW_TYPE and _NET_WM_ALLOWED_ACTIONS properties.
+Each of State, WindowType and Action is a bit set, so that checking for a
+state is a matter of
+
+	if states.Has(ewmh.StateFullscreen) { ... }
+
+rather than comparing atom names. Atoms that aren't defined by the EWMH are
+dropped when converting to a bit set.
+*/
+
+import (
+	"strings"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// State is a set of _NET_WM_STATE values.
+type State uint32
+
+// _NET_WM_STATE values
+const (
+	StateModal State = 1 << iota
+	StateSticky
+	StateMaximizedVert
+	StateMaximizedHorz
+	StateShaded
+	StateSkipTaskbar
+	StateSkipPager
+	StateHidden
+	StateFullscreen
+	StateAbove
+	StateBelow
+	StateDemandsAttention
+	StateFocused
+
+	// StateMaximized is both StateMaximizedVert and StateMaximizedHorz.
+	StateMaximized = StateMaximizedVert | StateMaximizedHorz
+)
+
+// stateNames is indexed by bit position.
+var stateNames = []string{
+	"_NET_WM_STATE_MODAL",
+	"_NET_WM_STATE_STICKY",
+	"_NET_WM_STATE_MAXIMIZED_VERT",
+	"_NET_WM_STATE_MAXIMIZED_HORZ",
+	"_NET_WM_STATE_SHADED",
+	"_NET_WM_STATE_SKIP_TASKBAR",
+	"_NET_WM_STATE_SKIP_PAGER",
+	"_NET_WM_STATE_HIDDEN",
+	"_NET_WM_STATE_FULLSCREEN",
+	"_NET_WM_STATE_ABOVE",
+	"_NET_WM_STATE_BELOW",
+	"_NET_WM_STATE_DEMANDS_ATTENTION",
+	"_NET_WM_STATE_FOCUSED",
+}
+
+// WindowType is a set of _NET_WM_WINDOW_TYPE values.
+type WindowType uint32
+
+// _NET_WM_WINDOW_TYPE values
+const (
+	WindowTypeDesktop WindowType = 1 << iota
+	WindowTypeDock
+	WindowTypeToolbar
+	WindowTypeMenu
+	WindowTypeUtility
+	WindowTypeSplash
+	WindowTypeDialog
+	WindowTypeDropdownMenu
+	WindowTypePopupMenu
+	WindowTypeTooltip
+	WindowTypeNotification
+	WindowTypeCombo
+	WindowTypeDnd
+	WindowTypeNormal
+)
+
+// windowTypeNames is indexed by bit position.
+var windowTypeNames = []string{
+	"_NET_WM_WINDOW_TYPE_DESKTOP",
+	"_NET_WM_WINDOW_TYPE_DOCK",
+	"_NET_WM_WINDOW_TYPE_TOOLBAR",
+	"_NET_WM_WINDOW_TYPE_MENU",
+	"_NET_WM_WINDOW_TYPE_UTILITY",
+	"_NET_WM_WINDOW_TYPE_SPLASH",
+	"_NET_WM_WINDOW_TYPE_DIALOG",
+	"_NET_WM_WINDOW_TYPE_DROPDOWN_MENU",
+	"_NET_WM_WINDOW_TYPE_POPUP_MENU",
+	"_NET_WM_WINDOW_TYPE_TOOLTIP",
+	"_NET_WM_WINDOW_TYPE_NOTIFICATION",
+	"_NET_WM_WINDOW_TYPE_COMBO",
+	"_NET_WM_WINDOW_TYPE_DND",
+	"_NET_WM_WINDOW_TYPE_NORMAL",
+}
+
+// Action is a set of _NET_WM_ALLOWED_ACTIONS values.
+type Action uint32
+
+// _NET_WM_ALLOWED_ACTIONS values
+const (
+	ActionMove Action = 1 << iota
+	ActionResize
+	ActionMinimize
+	ActionShade
+	ActionStick
+	ActionMaximizeHorz
+	ActionMaximizeVert
+	ActionFullscreen
+	ActionChangeDesktop
+	ActionClose
+	ActionAbove
+	ActionBelow
+)
+
+// actionNames is indexed by bit position.
+var actionNames = []string{
+	"_NET_WM_ACTION_MOVE",
+	"_NET_WM_ACTION_RESIZE",
+	"_NET_WM_ACTION_MINIMIZE",
+	"_NET_WM_ACTION_SHADE",
+	"_NET_WM_ACTION_STICK",
+	"_NET_WM_ACTION_MAXIMIZE_HORZ",
+	"_NET_WM_ACTION_MAXIMIZE_VERT",
+	"_NET_WM_ACTION_FULLSCREEN",
+	"_NET_WM_ACTION_CHANGE_DESKTOP",
+	"_NET_WM_ACTION_CLOSE",
+	"_NET_WM_ACTION_ABOVE",
+	"_NET_WM_ACTION_BELOW",
+}
+
+// Has returns whether every state in 'states' is in 's'.
+func (s State) Has(states State) bool {
+	return s&states == states
+}
+
+// Add returns 's' with every state in 'states' added.
+func (s State) Add(states State) State {
+	return s | states
+}
+
+// Remove returns 's' with every state in 'states' removed.
+func (s State) Remove(states State) State {
+	return s &^ states
+}
+
+// Names returns the atom names of the states in 's'.
+func (s State) Names() []string {
+	return bitNames(uint32(s), stateNames)
+}
+
+// Atoms returns the atoms of the states in 's'.
+func (s State) Atoms(xu *xgbutil.XUtil) ([]xproto.Atom, error) {
+	return namesToAtoms(xu, s.Names())
+}
+
+func (s State) String() string {
+	return strings.Join(s.Names(), "|")
+}
+
+// StateFromNames returns the set of states with the given atom names.
+// Names that aren't EWMH states are ignored.
+func StateFromNames(names []string) State {
+	return State(namesBits(names, stateNames))
+}
+
+// StateFromAtoms returns the set of states with the given atoms.
+// Atoms that aren't EWMH states are ignored.
+func StateFromAtoms(xu *xgbutil.XUtil, atoms []xproto.Atom) (State, error) {
+	names, err := atomsToNames(xu, atoms)
+	return StateFromNames(names), err
+}
+
+// Has returns whether every window type in 'types' is in 't'.
+func (t WindowType) Has(types WindowType) bool {
+	return t&types == types
+}
+
+// Add returns 't' with every window type in 'types' added.
+func (t WindowType) Add(types WindowType) WindowType {
+	return t | types
+}
+
+// Remove returns 't' with every window type in 'types' removed.
+func (t WindowType) Remove(types WindowType) WindowType {
+	return t &^ types
+}
+
+// Names returns the atom names of the window types in 't'.
+func (t WindowType) Names() []string {
+	return bitNames(uint32(t), windowTypeNames)
+}
+
+// Atoms returns the atoms of the window types in 't'.
+func (t WindowType) Atoms(xu *xgbutil.XUtil) ([]xproto.Atom, error) {
+	return namesToAtoms(xu, t.Names())
+}
+
+func (t WindowType) String() string {
+	return strings.Join(t.Names(), "|")
+}
+
+// WindowTypeFromNames returns the set of window types with the given atom
+// names. Names that aren't EWMH window types are ignored.
+func WindowTypeFromNames(names []string) WindowType {
+	return WindowType(namesBits(names, windowTypeNames))
+}
+
+// WindowTypeFromAtoms returns the set of window types with the given atoms.
+// Atoms that aren't EWMH window types are ignored.
+func WindowTypeFromAtoms(xu *xgbutil.XUtil,
+	atoms []xproto.Atom) (WindowType, error) {
+
+	names, err := atomsToNames(xu, atoms)
+	return WindowTypeFromNames(names), err
+}
+
+// Has returns whether every action in 'actions' is in 'a'.
+func (a Action) Has(actions Action) bool {
+	return a&actions == actions
+}
+
+// Add returns 'a' with every action in 'actions' added.
+func (a Action) Add(actions Action) Action {
+	return a | actions
+}
+
+// Remove returns 'a' with every action in 'actions' removed.
+func (a Action) Remove(actions Action) Action {
+	return a &^ actions
+}
+
+// Names returns the atom names of the actions in 'a'.
+func (a Action) Names() []string {
+	return bitNames(uint32(a), actionNames)
+}
+
+// Atoms returns the atoms of the actions in 'a'.
+func (a Action) Atoms(xu *xgbutil.XUtil) ([]xproto.Atom, error) {
+	return namesToAtoms(xu, a.Names())
+}
+
+func (a Action) String() string {
+	return strings.Join(a.Names(), "|")
+}
+
+// ActionFromNames returns the set of actions with the given atom names.
+// Names that aren't EWMH actions are ignored.
+func ActionFromNames(names []string) Action {
+	return Action(namesBits(names, actionNames))
+}
+
+// ActionFromAtoms returns the set of actions with the given atoms.
+// Atoms that aren't EWMH actions are ignored.
+func ActionFromAtoms(xu *xgbutil.XUtil, atoms []xproto.Atom) (Action, error) {
+	names, err := atomsToNames(xu, atoms)
+	return ActionFromNames(names), err
+}
+
+// _NET_WM_STATE get (typed)
+func WmStatesGet(xu *xgbutil.XUtil, win xproto.Window) (State, error) {
+	names, err := WmStateGet(xu, win)
+	return StateFromNames(names), err
+}
+
+// _NET_WM_STATE set (typed)
+// Note that any states in the property that aren't defined by the EWMH are
+// lost. Use WmStateSet to preserve them.
+func WmStatesSet(xu *xgbutil.XUtil, win xproto.Window, states State) error {
+	return WmStateSet(xu, win, states.Names())
+}
+
+// _NET_WM_STATE req (typed)
+// Every state in 'states' is changed according to 'action' (one of
+// StateRemove, StateAdd or StateToggle). Since a single request can only
+// carry two states, one request is sent for each pair of states.
+// (StateMaximized is sent as a single request, as the EWMH intends.)
+func WmStatesReq(xu *xgbutil.XUtil, win xproto.Window, action int,
+	states State) error {
+
+	return WmStatesReqExtra(xu, win, action, states, 2)
+}
+
+// _NET_WM_STATE req extra (typed)
+func WmStatesReqExtra(xu *xgbutil.XUtil, win xproto.Window, action int,
+	states State, source int) error {
+
+	// Maximizing in both directions should be a single request, so that the
+	// window manager doesn't maximize in two steps.
+	if states.Has(StateMaximized) {
+		err := WmStateReqExtra(xu, win, action,
+			"_NET_WM_STATE_MAXIMIZED_VERT", "_NET_WM_STATE_MAXIMIZED_HORZ",
+			source)
+		if err != nil {
+			return err
+		}
+		states = states.Remove(StateMaximized)
+	}
+
+	names := states.Names()
+	for i := 0; i < len(names); i += 2 {
+		second := ""
+		if i+1 < len(names) {
+			second = names[i+1]
+		}
+		err := WmStateReqExtra(xu, win, action, names[i], second, source)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// _NET_WM_WINDOW_TYPE get (typed)
+func WmWindowTypesGet(xu *xgbutil.XUtil,
+	win xproto.Window) (WindowType, error) {
+
+	names, err := WmWindowTypeGet(xu, win)
+	return WindowTypeFromNames(names), err
+}
+
+// _NET_WM_WINDOW_TYPE set (typed)
+// Note that _NET_WM_WINDOW_TYPE is a list in order of preference, but a
+// WindowType has no order. Use WmWindowTypeSet when the order matters.
+func WmWindowTypesSet(xu *xgbutil.XUtil, win xproto.Window,
+	types WindowType) error {
+
+	return WmWindowTypeSet(xu, win, types.Names())
+}
+
+// _NET_WM_ALLOWED_ACTIONS get (typed)
+func WmActionsGet(xu *xgbutil.XUtil, win xproto.Window) (Action, error) {
+	names, err := WmAllowedActionsGet(xu, win)
+	return ActionFromNames(names), err
+}
+
+// _NET_WM_ALLOWED_ACTIONS set (typed)
+func WmActionsSet(xu *xgbutil.XUtil, win xproto.Window,
+	actions Action) error {
+
+	return WmAllowedActionsSet(xu, win, actions.Names())
+}
+
+// bitNames returns the names of the bits set in 'bits', where 'table' is
+// indexed by bit position.
+func bitNames(bits uint32, table []string) []string {
+	names := make([]string, 0)
+	for i, name := range table {
+		if bits&(1<<uint(i)) > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// namesBits is the inverse of bitNames. Unknown names are ignored.
+func namesBits(names []string, table []string) uint32 {
+	bits := uint32(0)
+	for _, name := range names {
+		for i, known := range table {
+			if name == known {
+				bits |= 1 << uint(i)
+				break
+			}
+		}
+	}
+	return bits
+}
+
+// namesToAtoms interns each of the atom names in 'names'.
+func namesToAtoms(xu *xgbutil.XUtil, names []string) ([]xproto.Atom, error) {
+	atoms := make([]xproto.Atom, len(names))
+	for i, name := range names {
+		atom, err := xprop.Atm(xu, name)
+		if err != nil {
+			return nil, err
+		}
+		atoms[i] = atom
+	}
+	return atoms, nil
+}
+
+// atomsToNames looks up the name of each atom in 'atoms'.
+func atomsToNames(xu *xgbutil.XUtil, atoms []xproto.Atom) ([]string, error) {
+	names := make([]string, len(atoms))
+	for i, atom := range atoms {
+		name, err := xprop.AtomName(xu, atom)
+		if err != nil {
+			return nil, err
+		}
+		names[i] = name
+	}
+	return names, nil
+}
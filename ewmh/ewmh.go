@@ -1,6 +1,8 @@
 package ewmh
 
 import (
+	"fmt"
+
 	"github.com/BurntSushi/xgb/xproto"
 
 	"github.com/BurntSushi/xgbutil"
@@ -420,6 +422,17 @@ func ShowingDesktopReq(xu *xgbutil.XUtil, show bool) error {
 	return ClientEvent(xu, xu.RootWin(), "_NET_SHOWING_DESKTOP", showInt)
 }
 
+// _NET_STARTUP_ID get
+func StartupIdGet(xu *xgbutil.XUtil, win xproto.Window) (string, error) {
+	return xprop.PropValStr(xprop.GetProperty(xu, win, "_NET_STARTUP_ID"))
+}
+
+// _NET_STARTUP_ID set
+func StartupIdSet(xu *xgbutil.XUtil, win xproto.Window, id string) error {
+	return xprop.ChangeProp(xu, win, 8, "_NET_STARTUP_ID", "UTF8_STRING",
+		[]byte(id))
+}
+
 // _NET_SUPPORTED get
 func SupportedGet(xu *xgbutil.XUtil) ([]string, error) {
 	reply, err := xprop.GetProperty(xu, xu.RootWin(), "_NET_SUPPORTED")
@@ -992,11 +1005,26 @@ func WmSyncRequestExtra(xu *xgbutil.XUtil, win xproto.Window, reqNum uint64,
 		return err
 	}
 
-	high := int(reqNum >> 32)
-	low := int(reqNum<<32 ^ reqNum)
+	// Like every WM_PROTOCOLS message, this is sent to the client window
+	// itself rather than to the root window.
+	return xevent.SendClientMessage(xu, win, win, "WM_PROTOCOLS",
+		int(syncReq), int(time), int(reqNum&0xffffffff), int(reqNum>>32))
+}
+
+// _NET_WM_SYNC_REQUEST req (extended frame synchronization)
+// This asks the client to update its extended counter rather than its basic
+// counter. It should only be sent to clients that set two counters in
+// _NET_WM_SYNC_REQUEST_COUNTER.
+func WmSyncRequestExtended(xu *xgbutil.XUtil, win xproto.Window,
+	reqNum uint64, time xproto.Timestamp) error {
+
+	syncReq, err := xprop.Atm(xu, "_NET_WM_SYNC_REQUEST")
+	if err != nil {
+		return err
+	}
 
-	return ClientEvent(xu, win, "WM_PROTOCOLS", int(syncReq), int(time),
-		low, high)
+	return xevent.SendClientMessage(xu, win, win, "WM_PROTOCOLS",
+		int(syncReq), int(time), int(reqNum&0xffffffff), int(reqNum>>32), 1)
 }
 
 // _NET_WM_SYNC_REQUEST_COUNTER get
@@ -1017,6 +1045,36 @@ func WmSyncRequestCounterSet(xu *xgbutil.XUtil, win xproto.Window,
 		"CARDINAL", counter)
 }
 
+// _NET_WM_SYNC_REQUEST_COUNTER get (extended frame synchronization)
+// A client that supports extended frame synchronization stores two counters
+// in _NET_WM_SYNC_REQUEST_COUNTER: the basic counter followed by the extended
+// one. The extended counter is 0 if the client only set the basic counter.
+func WmSyncRequestCountersGet(xu *xgbutil.XUtil,
+	win xproto.Window) (basic uint, extended uint, err error) {
+
+	counters, err := xprop.PropValNums(xprop.GetProperty(xu, win,
+		"_NET_WM_SYNC_REQUEST_COUNTER"))
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(counters) == 0 {
+		return 0, 0, fmt.Errorf("WmSyncRequestCountersGet: " +
+			"_NET_WM_SYNC_REQUEST_COUNTER is empty.")
+	}
+	if len(counters) > 1 {
+		extended = counters[1]
+	}
+	return counters[0], extended, nil
+}
+
+// _NET_WM_SYNC_REQUEST_COUNTER set (extended frame synchronization)
+func WmSyncRequestCountersSet(xu *xgbutil.XUtil, win xproto.Window,
+	basic, extended uint) error {
+
+	return xprop.ChangeProp32(xu, win, "_NET_WM_SYNC_REQUEST_COUNTER",
+		"CARDINAL", basic, extended)
+}
+
 // _NET_WM_USER_TIME get
 func WmUserTimeGet(xu *xgbutil.XUtil, win xproto.Window) (uint, error) {
 	return xprop.PropValNum(xprop.GetProperty(xu, win, "_NET_WM_USER_TIME"))
@@ -0,0 +1,436 @@
+package ewmh
+
+/*
+ewmh/rootmsg.go contains the window manager side of the client messages that
+the "Req" functions in this package send to the root window. Each message is
+decoded into a struct, and handlers for each kind of message are registered
+in the same way as event handlers in the xevent package:
+
+	ewmh.ActiveWindowMsgFun(
+		func(X *xgbutil.XUtil, msg ewmh.ActiveWindowMsg) {
+			// activate msg.Window
+		}).Connect(X)
+
+Connect returns a RootMsgHandler, whose Detach method removes the handler.
+The window manager must have SubstructureRedirect selected on the root window
+to receive these messages.
+*/
+
+import (
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// Source indication values, found in most client messages. See the section
+// "Source indication in requests" of the EWMH.
+const (
+	SourceNone = iota
+	SourceApplication
+	SourcePager
+)
+
+// CurrentDesktopMsg is a _NET_CURRENT_DESKTOP request.
+type CurrentDesktopMsg struct {
+	Desktop uint
+	Time    xproto.Timestamp
+}
+
+// NumberOfDesktopsMsg is a _NET_NUMBER_OF_DESKTOPS request.
+type NumberOfDesktopsMsg struct {
+	Number uint
+}
+
+// DesktopGeometryMsg is a _NET_DESKTOP_GEOMETRY request.
+type DesktopGeometryMsg struct {
+	Width, Height int
+}
+
+// DesktopViewportMsg is a _NET_DESKTOP_VIEWPORT request.
+type DesktopViewportMsg struct {
+	X, Y int
+}
+
+// ShowingDesktopMsg is a _NET_SHOWING_DESKTOP request.
+type ShowingDesktopMsg struct {
+	Show bool
+}
+
+// ActiveWindowMsg is a _NET_ACTIVE_WINDOW request. Current is the window
+// that the requestor thinks is active, and may be 0.
+type ActiveWindowMsg struct {
+	Window  xproto.Window
+	Source  int
+	Time    xproto.Timestamp
+	Current xproto.Window
+}
+
+// CloseWindowMsg is a _NET_CLOSE_WINDOW request.
+type CloseWindowMsg struct {
+	Window xproto.Window
+	Time   xproto.Timestamp
+	Source int
+}
+
+// MoveresizeWindowMsg is a _NET_MOVERESIZE_WINDOW request. Only the fields
+// whose Has* flag is set should be changed. A Gravity of 0 means that the
+// window's own gravity (from WM_NORMAL_HINTS) should be used.
+type MoveresizeWindowMsg struct {
+	Window                          xproto.Window
+	Gravity                         int
+	HasX, HasY, HasWidth, HasHeight bool
+	X, Y, Width, Height             int
+	Source                          int
+}
+
+// WmMoveresizeMsg is a _NET_WM_MOVERESIZE request. Direction is one of the
+// _NET_WM_MOVERESIZE constants (SizeTopLeft, ..., Move, ..., Cancel).
+type WmMoveresizeMsg struct {
+	Window       xproto.Window
+	XRoot, YRoot int
+	Direction    int
+	Button       int
+	Source       int
+}
+
+// RestackWindowMsg is a _NET_RESTACK_WINDOW request. Sibling may be 0, and
+// Detail is a stack mode (e.g., xproto.StackModeAbove).
+type RestackWindowMsg struct {
+	Window  xproto.Window
+	Source  int
+	Sibling xproto.Window
+	Detail  int
+}
+
+// RequestFrameExtentsMsg is a _NET_REQUEST_FRAME_EXTENTS request.
+type RequestFrameExtentsMsg struct {
+	Window xproto.Window
+}
+
+// WmDesktopMsg is a _NET_WM_DESKTOP request. A Desktop of 0xFFFFFFFF means
+// that the window should appear on all desktops.
+type WmDesktopMsg struct {
+	Window  xproto.Window
+	Desktop uint
+	Source  int
+}
+
+// WmStateMsg is a _NET_WM_STATE request. Action is one of StateRemove,
+// StateAdd or StateToggle. First and Second are the names of the properties
+// to change (Second may be empty), and States contains the same properties
+// as a State.
+type WmStateMsg struct {
+	Window        xproto.Window
+	Action        int
+	First, Second string
+	States        State
+	Source        int
+}
+
+// WmFullscreenMonitorsMsg is a _NET_WM_FULLSCREEN_MONITORS request.
+type WmFullscreenMonitorsMsg struct {
+	Window   xproto.Window
+	Monitors WmFullscreenMonitors
+	Source   int
+}
+
+// RootMsgDecode decodes a client message sent to the root window into one of
+// the *Msg structs in this file. The second return value is false if the
+// message isn't one of the EWMH root window messages.
+func RootMsgDecode(xu *xgbutil.XUtil,
+	ev xevent.ClientMessageEvent) (interface{}, bool) {
+
+	if ev.Format != 32 {
+		return nil, false
+	}
+	name, err := xprop.AtomName(xu, ev.Type)
+	if err != nil {
+		return nil, false
+	}
+	return decodeRootMsg(xu, name, ev)
+}
+
+// decodeRootMsg decodes a client message whose type has the atom name 'name'.
+func decodeRootMsg(xu *xgbutil.XUtil, name string,
+	ev xevent.ClientMessageEvent) (interface{}, bool) {
+
+	d := ev.Data.Data32
+	switch name {
+	case "_NET_CURRENT_DESKTOP":
+		return CurrentDesktopMsg{uint(d[0]), xproto.Timestamp(d[1])}, true
+	case "_NET_NUMBER_OF_DESKTOPS":
+		return NumberOfDesktopsMsg{uint(d[0])}, true
+	case "_NET_DESKTOP_GEOMETRY":
+		return DesktopGeometryMsg{int(d[0]), int(d[1])}, true
+	case "_NET_DESKTOP_VIEWPORT":
+		return DesktopViewportMsg{int(d[0]), int(d[1])}, true
+	case "_NET_SHOWING_DESKTOP":
+		return ShowingDesktopMsg{d[0] != 0}, true
+	case "_NET_ACTIVE_WINDOW":
+		return ActiveWindowMsg{ev.Window, int(d[0]), xproto.Timestamp(d[1]),
+			xproto.Window(d[2])}, true
+	case "_NET_CLOSE_WINDOW":
+		return CloseWindowMsg{ev.Window, xproto.Timestamp(d[0]),
+			int(d[1])}, true
+	case "_NET_MOVERESIZE_WINDOW":
+		return MoveresizeWindowMsg{
+			Window:    ev.Window,
+			Gravity:   int(d[0] & 0xff),
+			HasX:      d[0]&(1<<8) > 0,
+			HasY:      d[0]&(1<<9) > 0,
+			HasWidth:  d[0]&(1<<10) > 0,
+			HasHeight: d[0]&(1<<11) > 0,
+			X:         int(int32(d[1])),
+			Y:         int(int32(d[2])),
+			Width:     int(d[3]),
+			Height:    int(d[4]),
+			Source:    int((d[0] >> 12) & 0xf),
+		}, true
+	case "_NET_WM_MOVERESIZE":
+		return WmMoveresizeMsg{ev.Window, int(int32(d[0])), int(int32(d[1])),
+			int(d[2]), int(d[3]), int(d[4])}, true
+	case "_NET_RESTACK_WINDOW":
+		return RestackWindowMsg{ev.Window, int(d[0]), xproto.Window(d[1]),
+			int(d[2])}, true
+	case "_NET_REQUEST_FRAME_EXTENTS":
+		return RequestFrameExtentsMsg{ev.Window}, true
+	case "_NET_WM_DESKTOP":
+		return WmDesktopMsg{ev.Window, uint(d[0]), int(d[1])}, true
+	case "_NET_WM_STATE":
+		first := atomNameOrEmpty(xu, xproto.Atom(d[1]))
+		second := atomNameOrEmpty(xu, xproto.Atom(d[2]))
+		return WmStateMsg{
+			Window: ev.Window,
+			Action: int(d[0]),
+			First:  first,
+			Second: second,
+			States: StateFromNames([]string{first, second}),
+			Source: int(d[3]),
+		}, true
+	case "_NET_WM_FULLSCREEN_MONITORS":
+		return WmFullscreenMonitorsMsg{ev.Window,
+			WmFullscreenMonitors{uint(d[0]), uint(d[1]), uint(d[2]),
+				uint(d[3])},
+			int(d[4])}, true
+	}
+	return nil, false
+}
+
+// atomNameOrEmpty returns the name of 'atom', or "" if 'atom' is 0 or
+// invalid.
+func atomNameOrEmpty(xu *xgbutil.XUtil, atom xproto.Atom) string {
+	if atom == 0 {
+		return ""
+	}
+	name, err := xprop.AtomName(xu, atom)
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// RootMsgHandler is a handler for one type of root window message, returned
+// by the Connect method of the "MsgFun" types. Its Detach method removes it.
+type RootMsgHandler struct {
+	X    *xgbutil.XUtil
+	name string
+	atom xproto.Atom
+	run  func(xu *xgbutil.XUtil, msg interface{})
+
+	// hook is the event hook that runs the handler. The messages must be
+	// caught before dispatch, since most of them are about client windows
+	// and xevent dispatches client messages on the window they are about.
+	hook xevent.HookFun
+}
+
+// connectRootMsg attaches an event hook that runs 'run' with every decoded
+// root window message of the type 'name'.
+func connectRootMsg(xu *xgbutil.XUtil, name string,
+	run func(xu *xgbutil.XUtil, msg interface{})) *RootMsgHandler {
+
+	h := &RootMsgHandler{X: xu, name: name, run: run}
+	h.hook = func(xu *xgbutil.XUtil, event interface{}) bool {
+		if ev, ok := event.(xproto.ClientMessageEvent); ok {
+			h.message(ev)
+		}
+		return true
+	}
+	xevent.AttachHook(xu, &h.hook)
+	return h
+}
+
+// Detach removes the handler.
+func (h *RootMsgHandler) Detach() {
+	xevent.DisconnectHook(h.X, &h.hook)
+}
+
+// message decodes 'ev' and runs the handler, if 'ev' is a message of the
+// handler's type. The atom of the type is looked up with the first message,
+// so that other client messages can be skipped by comparing atoms.
+func (h *RootMsgHandler) message(ev xproto.ClientMessageEvent) {
+	if ev.Format != 32 {
+		return
+	}
+	if h.atom == 0 {
+		atom, err := xprop.Atm(h.X, h.name)
+		if err != nil {
+			return
+		}
+		h.atom = atom
+	}
+	if ev.Type != h.atom {
+		return
+	}
+
+	msg, ok := decodeRootMsg(h.X, h.name,
+		xevent.ClientMessageEvent{ClientMessageEvent: &ev})
+	if ok {
+		h.run(h.X, msg)
+	}
+}
+
+type CurrentDesktopMsgFun func(xu *xgbutil.XUtil, msg CurrentDesktopMsg)
+
+func (callback CurrentDesktopMsgFun) Connect(
+	xu *xgbutil.XUtil) *RootMsgHandler {
+
+	return connectRootMsg(xu, "_NET_CURRENT_DESKTOP",
+		func(xu *xgbutil.XUtil, msg interface{}) {
+			callback(xu, msg.(CurrentDesktopMsg))
+		})
+}
+
+type NumberOfDesktopsMsgFun func(xu *xgbutil.XUtil, msg NumberOfDesktopsMsg)
+
+func (callback NumberOfDesktopsMsgFun) Connect(
+	xu *xgbutil.XUtil) *RootMsgHandler {
+
+	return connectRootMsg(xu, "_NET_NUMBER_OF_DESKTOPS",
+		func(xu *xgbutil.XUtil, msg interface{}) {
+			callback(xu, msg.(NumberOfDesktopsMsg))
+		})
+}
+
+type DesktopGeometryMsgFun func(xu *xgbutil.XUtil, msg DesktopGeometryMsg)
+
+func (callback DesktopGeometryMsgFun) Connect(
+	xu *xgbutil.XUtil) *RootMsgHandler {
+
+	return connectRootMsg(xu, "_NET_DESKTOP_GEOMETRY",
+		func(xu *xgbutil.XUtil, msg interface{}) {
+			callback(xu, msg.(DesktopGeometryMsg))
+		})
+}
+
+type DesktopViewportMsgFun func(xu *xgbutil.XUtil, msg DesktopViewportMsg)
+
+func (callback DesktopViewportMsgFun) Connect(
+	xu *xgbutil.XUtil) *RootMsgHandler {
+
+	return connectRootMsg(xu, "_NET_DESKTOP_VIEWPORT",
+		func(xu *xgbutil.XUtil, msg interface{}) {
+			callback(xu, msg.(DesktopViewportMsg))
+		})
+}
+
+type ShowingDesktopMsgFun func(xu *xgbutil.XUtil, msg ShowingDesktopMsg)
+
+func (callback ShowingDesktopMsgFun) Connect(
+	xu *xgbutil.XUtil) *RootMsgHandler {
+
+	return connectRootMsg(xu, "_NET_SHOWING_DESKTOP",
+		func(xu *xgbutil.XUtil, msg interface{}) {
+			callback(xu, msg.(ShowingDesktopMsg))
+		})
+}
+
+type ActiveWindowMsgFun func(xu *xgbutil.XUtil, msg ActiveWindowMsg)
+
+func (callback ActiveWindowMsgFun) Connect(xu *xgbutil.XUtil) *RootMsgHandler {
+	return connectRootMsg(xu, "_NET_ACTIVE_WINDOW",
+		func(xu *xgbutil.XUtil, msg interface{}) {
+			callback(xu, msg.(ActiveWindowMsg))
+		})
+}
+
+type CloseWindowMsgFun func(xu *xgbutil.XUtil, msg CloseWindowMsg)
+
+func (callback CloseWindowMsgFun) Connect(xu *xgbutil.XUtil) *RootMsgHandler {
+	return connectRootMsg(xu, "_NET_CLOSE_WINDOW",
+		func(xu *xgbutil.XUtil, msg interface{}) {
+			callback(xu, msg.(CloseWindowMsg))
+		})
+}
+
+type MoveresizeWindowMsgFun func(xu *xgbutil.XUtil, msg MoveresizeWindowMsg)
+
+func (callback MoveresizeWindowMsgFun) Connect(
+	xu *xgbutil.XUtil) *RootMsgHandler {
+
+	return connectRootMsg(xu, "_NET_MOVERESIZE_WINDOW",
+		func(xu *xgbutil.XUtil, msg interface{}) {
+			callback(xu, msg.(MoveresizeWindowMsg))
+		})
+}
+
+type WmMoveresizeMsgFun func(xu *xgbutil.XUtil, msg WmMoveresizeMsg)
+
+func (callback WmMoveresizeMsgFun) Connect(xu *xgbutil.XUtil) *RootMsgHandler {
+	return connectRootMsg(xu, "_NET_WM_MOVERESIZE",
+		func(xu *xgbutil.XUtil, msg interface{}) {
+			callback(xu, msg.(WmMoveresizeMsg))
+		})
+}
+
+type RestackWindowMsgFun func(xu *xgbutil.XUtil, msg RestackWindowMsg)
+
+func (callback RestackWindowMsgFun) Connect(xu *xgbutil.XUtil) *RootMsgHandler {
+	return connectRootMsg(xu, "_NET_RESTACK_WINDOW",
+		func(xu *xgbutil.XUtil, msg interface{}) {
+			callback(xu, msg.(RestackWindowMsg))
+		})
+}
+
+type RequestFrameExtentsMsgFun func(xu *xgbutil.XUtil,
+	msg RequestFrameExtentsMsg)
+
+func (callback RequestFrameExtentsMsgFun) Connect(
+	xu *xgbutil.XUtil) *RootMsgHandler {
+
+	return connectRootMsg(xu, "_NET_REQUEST_FRAME_EXTENTS",
+		func(xu *xgbutil.XUtil, msg interface{}) {
+			callback(xu, msg.(RequestFrameExtentsMsg))
+		})
+}
+
+type WmDesktopMsgFun func(xu *xgbutil.XUtil, msg WmDesktopMsg)
+
+func (callback WmDesktopMsgFun) Connect(xu *xgbutil.XUtil) *RootMsgHandler {
+	return connectRootMsg(xu, "_NET_WM_DESKTOP",
+		func(xu *xgbutil.XUtil, msg interface{}) {
+			callback(xu, msg.(WmDesktopMsg))
+		})
+}
+
+type WmStateMsgFun func(xu *xgbutil.XUtil, msg WmStateMsg)
+
+func (callback WmStateMsgFun) Connect(xu *xgbutil.XUtil) *RootMsgHandler {
+	return connectRootMsg(xu, "_NET_WM_STATE",
+		func(xu *xgbutil.XUtil, msg interface{}) {
+			callback(xu, msg.(WmStateMsg))
+		})
+}
+
+type WmFullscreenMonitorsMsgFun func(xu *xgbutil.XUtil,
+	msg WmFullscreenMonitorsMsg)
+
+func (callback WmFullscreenMonitorsMsgFun) Connect(
+	xu *xgbutil.XUtil) *RootMsgHandler {
+
+	return connectRootMsg(xu, "_NET_WM_FULLSCREEN_MONITORS",
+		func(xu *xgbutil.XUtil, msg interface{}) {
+			callback(xu, msg.(WmFullscreenMonitorsMsg))
+		})
+}
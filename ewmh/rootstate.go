@@ -0,0 +1,454 @@
+package ewmh
+
+/*
+ewmh/rootstate.go contains RootState, which a window manager can use to keep
+the EWMH properties that it owns on the root window consistent with each
+other. Namely, _NET_CLIENT_LIST, _NET_CLIENT_LIST_STACKING,
+_NET_ACTIVE_WINDOW, _NET_NUMBER_OF_DESKTOPS, _NET_WORKAREA, _NET_SUPPORTED
+and _NET_SUPPORTING_WM_CHECK.
+
+Changes are recorded in memory and only written to the root window when
+Flush is called, so that a window manager can make several changes in
+response to a single event (e.g., a client being unmapped changes the client
+lists, the active window and perhaps the workarea) with at most one request
+per property.
+*/
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xprop"
+	"github.com/BurntSushi/xgbutil/xrect"
+)
+
+// The properties owned by a RootState. Used to keep track of which ones need
+// to be written by Flush.
+const (
+	rootClientList = 1 << iota
+	rootClientListStacking
+	rootActiveWindow
+	rootNumberOfDesktops
+	rootWorkarea
+	rootSupported
+)
+
+// RootState owns the root window properties of an EWMH compliant window
+// manager. Use NewRootState to create one. Each method that changes state only
+// changes it in memory; call Flush to write the properties that actually
+// changed to the root window.
+//
+// RootState is not safe for concurrent use.
+type RootState struct {
+	X *xgbutil.XUtil
+
+	// CheckWin is the child window of the root used for
+	// _NET_SUPPORTING_WM_CHECK.
+	CheckWin xproto.Window
+
+	clients   []xproto.Window // in initial mapping order
+	stacking  []xproto.Window // bottom to top
+	active    xproto.Window
+	desktops  uint
+	supported []string
+
+	rootWidth, rootHeight uint
+	heads                 []xrect.Rect
+	struts                map[xproto.Window]*WmStrutPartial
+
+	dirty int
+}
+
+// NewRootState creates the supporting WM check window, names it 'wmName'
+// with _NET_WM_NAME, sets _NET_SUPPORTING_WM_CHECK on it and on the root
+// window, and writes an initial value for every property owned by the
+// returned RootState: empty client lists, no active window, a single desktop,
+// a workarea covering the whole root window and 'supported' as the list of
+// supported hints.
+//
+// Note that _NET_SUPPORTING_WM_CHECK is set on the root window *last*, so
+// that other clients don't see a half-initialized window manager.
+func NewRootState(xu *xgbutil.XUtil, wmName string,
+	supported []string) (*RootState, error) {
+
+	win, err := xproto.NewWindowId(xu.Conn())
+	if err != nil {
+		return nil, err
+	}
+	err = xproto.CreateWindowChecked(xu.Conn(), xu.Screen().RootDepth, win,
+		xu.RootWin(), -1, -1, 1, 1, 0,
+		xproto.WindowClassInputOutput, xu.Screen().RootVisual,
+		xproto.CwOverrideRedirect, []uint32{1}).Check()
+	if err != nil {
+		return nil, fmt.Errorf("NewRootState: Could not create the "+
+			"supporting WM check window: %s", err)
+	}
+
+	rs := &RootState{
+		X:          xu,
+		CheckWin:   win,
+		clients:    make([]xproto.Window, 0),
+		stacking:   make([]xproto.Window, 0),
+		desktops:   1,
+		supported:  supported,
+		rootWidth:  uint(xu.Screen().WidthInPixels),
+		rootHeight: uint(xu.Screen().HeightInPixels),
+		struts:     make(map[xproto.Window]*WmStrutPartial),
+		dirty: rootClientList | rootClientListStacking | rootActiveWindow |
+			rootNumberOfDesktops | rootWorkarea | rootSupported,
+	}
+	if err := WmNameSet(xu, win, wmName); err != nil {
+		rs.Destroy()
+		return nil, err
+	}
+	if err := SupportingWmCheckSet(xu, win, win); err != nil {
+		rs.Destroy()
+		return nil, err
+	}
+	if err := rs.Flush(); err != nil {
+		rs.Destroy()
+		return nil, err
+	}
+	if err := SupportingWmCheckSet(xu, xu.RootWin(), win); err != nil {
+		rs.Destroy()
+		return nil, err
+	}
+	return rs, nil
+}
+
+// Destroy removes _NET_SUPPORTING_WM_CHECK from the root window and destroys
+// the supporting WM check window. It should be called when the window manager
+// exits (or is replaced).
+func (rs *RootState) Destroy() {
+	atm, err := xprop.Atm(rs.X, "_NET_SUPPORTING_WM_CHECK")
+	if err == nil {
+		xproto.DeleteProperty(rs.X.Conn(), rs.X.RootWin(), atm)
+	}
+	xproto.DestroyWindow(rs.X.Conn(), rs.CheckWin)
+}
+
+// Flush writes every property that has changed since the last call to Flush
+// to the root window. If writing a property fails, it is retried on the next
+// call to Flush and the first error encountered is returned.
+func (rs *RootState) Flush() error {
+	var first error
+	write := func(prop int, set func() error) {
+		if rs.dirty&prop == 0 {
+			return
+		}
+		if err := set(); err != nil {
+			if first == nil {
+				first = err
+			}
+			return
+		}
+		rs.dirty &^= prop
+	}
+
+	write(rootSupported, func() error {
+		return SupportedSet(rs.X, rs.supported)
+	})
+	write(rootNumberOfDesktops, func() error {
+		return NumberOfDesktopsSet(rs.X, rs.desktops)
+	})
+	write(rootWorkarea, func() error {
+		return WorkareaSet(rs.X, rs.workareas())
+	})
+	write(rootClientList, func() error {
+		return ClientListSet(rs.X, rs.clients)
+	})
+	write(rootClientListStacking, func() error {
+		return ClientListStackingSet(rs.X, rs.stacking)
+	})
+	write(rootActiveWindow, func() error {
+		return ActiveWindowSet(rs.X, rs.active)
+	})
+	return first
+}
+
+// Supported returns the atom names in _NET_SUPPORTED.
+func (rs *RootState) Supported() []string {
+	return rs.supported
+}
+
+// SupportedSet replaces the list of atom names in _NET_SUPPORTED.
+func (rs *RootState) SupportedSet(names []string) {
+	if !stringsEqual(rs.supported, names) {
+		rs.supported = names
+		rs.dirty |= rootSupported
+	}
+}
+
+// Clients returns the managed clients in initial mapping order.
+// (i.e., the value of _NET_CLIENT_LIST.)
+func (rs *RootState) Clients() []xproto.Window {
+	return rs.clients
+}
+
+// Stacking returns the managed clients in stacking order from bottom to top.
+// (i.e., the value of _NET_CLIENT_LIST_STACKING.)
+func (rs *RootState) Stacking() []xproto.Window {
+	return rs.stacking
+}
+
+// ClientAdd adds 'win' to the end of the client list and to the top of the
+// stacking list. Adding a client that is already managed does nothing.
+func (rs *RootState) ClientAdd(win xproto.Window) {
+	if windowIndex(rs.clients, win) > -1 {
+		return
+	}
+	rs.clients = append(rs.clients, win)
+	rs.stacking = append(rs.stacking, win)
+	rs.dirty |= rootClientList | rootClientListStacking
+}
+
+// ClientRemove removes 'win' from the client lists. If 'win' is the active
+// window, there will no longer be an active window. If 'win' has a strut,
+// the strut is removed and the workarea recomputed.
+func (rs *RootState) ClientRemove(win xproto.Window) {
+	if i := windowIndex(rs.clients, win); i > -1 {
+		rs.clients = append(rs.clients[:i], rs.clients[i+1:]...)
+		rs.dirty |= rootClientList
+	}
+	if i := windowIndex(rs.stacking, win); i > -1 {
+		rs.stacking = append(rs.stacking[:i], rs.stacking[i+1:]...)
+		rs.dirty |= rootClientListStacking
+	}
+	if rs.active == win {
+		rs.ActiveSet(0)
+	}
+	rs.StrutSet(win, nil)
+}
+
+// StackingSet sets the stacking order of the managed clients, from bottom to
+// top. Windows in 'wins' that aren't managed are ignored, and managed clients
+// missing from 'wins' are put at the bottom in their previous order. So a
+// window manager may pass the stacking order of all of its frames' clients
+// without filtering out unmanaged windows.
+func (rs *RootState) StackingSet(wins []xproto.Window) {
+	stacking := make([]xproto.Window, 0, len(rs.clients))
+	for _, win := range rs.stacking {
+		if windowIndex(wins, win) == -1 {
+			stacking = append(stacking, win)
+		}
+	}
+	for _, win := range wins {
+		if windowIndex(rs.clients, win) > -1 &&
+			windowIndex(stacking, win) == -1 {
+
+			stacking = append(stacking, win)
+		}
+	}
+	if !windowsEqual(rs.stacking, stacking) {
+		rs.stacking = stacking
+		rs.dirty |= rootClientListStacking
+	}
+}
+
+// Raise moves 'win' to the top of the stacking list.
+func (rs *RootState) Raise(win xproto.Window) {
+	i := windowIndex(rs.stacking, win)
+	if i == -1 || i == len(rs.stacking)-1 {
+		return
+	}
+	rs.stacking = append(rs.stacking[:i], rs.stacking[i+1:]...)
+	rs.stacking = append(rs.stacking, win)
+	rs.dirty |= rootClientListStacking
+}
+
+// Active returns the active window, or 0 if there isn't one.
+func (rs *RootState) Active() xproto.Window {
+	return rs.active
+}
+
+// ActiveSet sets the active window. Use 0 for no active window.
+func (rs *RootState) ActiveSet(win xproto.Window) {
+	if rs.active != win {
+		rs.active = win
+		rs.dirty |= rootActiveWindow
+	}
+}
+
+// NumberOfDesktops returns the number of desktops.
+func (rs *RootState) NumberOfDesktops() uint {
+	return rs.desktops
+}
+
+// NumberOfDesktopsSet sets the number of desktops. Since _NET_WORKAREA has
+// one entry per desktop, this also causes the workarea to be rewritten.
+func (rs *RootState) NumberOfDesktopsSet(n uint) {
+	if rs.desktops != n {
+		rs.desktops = n
+		rs.dirty |= rootNumberOfDesktops | rootWorkarea
+	}
+}
+
+// RootSizeSet sets the size of the root window used to compute the workarea.
+// It is initialized from the screen information, and only needs to be set
+// when the root window is resized (e.g., by RandR).
+func (rs *RootState) RootSizeSet(width, height uint) {
+	if rs.rootWidth != width || rs.rootHeight != height {
+		rs.rootWidth, rs.rootHeight = width, height
+		rs.dirty |= rootWorkarea
+	}
+}
+
+// HeadsSet sets the geometry of each physical head (e.g., as reported by
+// Xinerama), which is used by Workareas. If 'heads' is empty, the whole root
+// window is treated as a single head.
+func (rs *RootState) HeadsSet(heads []xrect.Rect) {
+	rs.heads = heads
+}
+
+// StrutSet sets the strut reserved by the client 'win'. Use a nil strut to
+// remove it. The workarea is recomputed on the next call to Flush.
+func (rs *RootState) StrutSet(win xproto.Window, strut *WmStrutPartial) {
+	old, ok := rs.struts[win]
+	switch {
+	case strut == nil && !ok:
+		return
+	case strut == nil:
+		delete(rs.struts, win)
+	case ok && *old == *strut:
+		return
+	default:
+		rs.struts[win] = strut
+	}
+	rs.dirty |= rootWorkarea
+}
+
+// StrutUpdate reads the strut of 'win' from _NET_WM_STRUT_PARTIAL, falling
+// back to _NET_WM_STRUT, and records it with StrutSet. If the client has
+// neither property, its strut is removed. This should be called when a client
+// is managed and whenever either property changes.
+func (rs *RootState) StrutUpdate(win xproto.Window) {
+	if strut, err := WmStrutPartialGet(rs.X, win); err == nil {
+		rs.StrutSet(win, strut)
+		return
+	}
+
+	strut, err := WmStrutGet(rs.X, win)
+	if err != nil {
+		rs.StrutSet(win, nil)
+		return
+	}
+	// A _NET_WM_STRUT reserves the entire length of its edge. The range of
+	// an edge without a strut is left empty.
+	partial := &WmStrutPartial{
+		Left: strut.Left, Right: strut.Right,
+		Top: strut.Top, Bottom: strut.Bottom,
+	}
+	if strut.Left > 0 {
+		partial.LeftEndY = rs.rootHeight - 1
+	}
+	if strut.Right > 0 {
+		partial.RightEndY = rs.rootHeight - 1
+	}
+	if strut.Top > 0 {
+		partial.TopEndX = rs.rootWidth - 1
+	}
+	if strut.Bottom > 0 {
+		partial.BottomEndX = rs.rootWidth - 1
+	}
+	rs.StrutSet(win, partial)
+}
+
+// Workarea returns the area of the root window that isn't reserved by any
+// client strut. This is the value written to _NET_WORKAREA for each desktop.
+func (rs *RootState) Workarea() xrect.Rect {
+	root := []xrect.Rect{
+		xrect.New(0, 0, int(rs.rootWidth), int(rs.rootHeight)),
+	}
+	rs.applyStruts(root)
+	return root[0]
+}
+
+// Workareas returns the geometry of each head set with HeadsSet with all
+// client struts applied. Window managers should use this (rather than
+// Workarea) when placing or maximizing windows on multi-head setups.
+func (rs *RootState) Workareas() []xrect.Rect {
+	heads := make([]xrect.Rect, 0, len(rs.heads))
+	for _, head := range rs.heads {
+		heads = append(heads, xrect.New(xrect.RectPieces(head)))
+	}
+	if len(heads) == 0 {
+		heads = append(heads,
+			xrect.New(0, 0, int(rs.rootWidth), int(rs.rootHeight)))
+	}
+	rs.applyStruts(heads)
+	return heads
+}
+
+// applyStruts shrinks each rectangle in 'rects' with every client strut.
+// xrect.ApplyStrut only applies one edge of a strut (the first one it finds
+// with a non-empty range), so it is called once for each reserved edge.
+func (rs *RootState) applyStruts(rects []xrect.Rect) {
+	rw, rh := rs.rootWidth, rs.rootHeight
+	for _, s := range rs.struts {
+		if s.Left > 0 {
+			xrect.ApplyStrut(rects, rw, rh, s.Left, 0, 0, 0,
+				s.LeftStartY, s.LeftEndY, 0, 0, 0, 0, 0, 0)
+		}
+		if s.Right > 0 {
+			xrect.ApplyStrut(rects, rw, rh, 0, s.Right, 0, 0,
+				0, 0, s.RightStartY, s.RightEndY, 0, 0, 0, 0)
+		}
+		if s.Top > 0 {
+			xrect.ApplyStrut(rects, rw, rh, 0, 0, s.Top, 0,
+				0, 0, 0, 0, s.TopStartX, s.TopEndX, 0, 0)
+		}
+		if s.Bottom > 0 {
+			xrect.ApplyStrut(rects, rw, rh, 0, 0, 0, s.Bottom,
+				0, 0, 0, 0, 0, 0, s.BottomStartX, s.BottomEndX)
+		}
+	}
+}
+
+// workareas returns the value of _NET_WORKAREA: the workarea repeated for
+// each desktop.
+func (rs *RootState) workareas() []Workarea {
+	x, y, w, h := xrect.RectPieces(rs.Workarea())
+	areas := make([]Workarea, rs.desktops)
+	for i := range areas {
+		areas[i] = Workarea{X: x, Y: y, Width: uint(w), Height: uint(h)}
+	}
+	return areas
+}
+
+// windowIndex returns the index of 'win' in 'wins', or -1 if it isn't there.
+func windowIndex(wins []xproto.Window, win xproto.Window) int {
+	for i, w := range wins {
+		if w == win {
+			return i
+		}
+	}
+	return -1
+}
+
+// windowsEqual returns whether two lists of windows are identical.
+func windowsEqual(a, b []xproto.Window) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// stringsEqual returns whether two lists of strings are identical.
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package ewmh
+
+/*
+ewmh/stateengine.go contains the window manager side of _NET_WM_STATE
+requests. That is, applying a request (as decoded into a WmStateMsg) to the
+current state of a window, while following the rules of the EWMH:
+
+	* A request to toggle both _NET_WM_STATE_MAXIMIZED_VERT and
+	  _NET_WM_STATE_MAXIMIZED_HORZ toggles them together. If the window is
+	  maximized in only one direction, it becomes maximized in both.
+	* _NET_WM_STATE_ABOVE and _NET_WM_STATE_BELOW are mutually exclusive.
+	  Adding one removes the other.
+	* _NET_WM_STATE_HIDDEN and _NET_WM_STATE_FOCUSED are managed by the window
+	  manager, so requests to change them are ignored.
+*/
+
+import (
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+)
+
+// wmOnlyStates are the states that clients can't change.
+const wmOnlyStates = StateHidden | StateFocused
+
+// StateDiff is the result of applying a _NET_WM_STATE request.
+type StateDiff struct {
+	Window   xproto.Window
+	Old, New State
+}
+
+// Added returns the states that were added by the request.
+func (d StateDiff) Added() State {
+	return d.New &^ d.Old
+}
+
+// Removed returns the states that were removed by the request.
+func (d StateDiff) Removed() State {
+	return d.Old &^ d.New
+}
+
+// Changed returns whether the request changed anything.
+func (d StateDiff) Changed() bool {
+	return d.Old != d.New
+}
+
+// StateApply returns the result of applying the _NET_WM_STATE action
+// 'action' (StateRemove, StateAdd or StateToggle) for the states in 'states'
+// to the current states 'cur'. It doesn't touch any windows, so a window
+// manager can use it to decide whether to honor a request (e.g., by checking
+// the window's allowed actions) before applying it with WmStateApply.
+func StateApply(cur State, action int, states State) State {
+	states = states.Remove(wmOnlyStates)
+
+	next := cur
+	switch action {
+	case StateAdd:
+		next = cur.Add(states)
+	case StateRemove:
+		next = cur.Remove(states)
+	case StateToggle:
+		// Toggle maximization as a unit when both directions are requested.
+		if states.Has(StateMaximized) {
+			if cur.Has(StateMaximized) {
+				next = next.Remove(StateMaximized)
+			} else {
+				next = next.Add(StateMaximized)
+			}
+			states = states.Remove(StateMaximized)
+		}
+		next ^= states
+	default:
+		return cur
+	}
+
+	// ABOVE and BELOW are exclusive. The newly added one wins, and ABOVE wins
+	// if both were added.
+	added := next &^ cur
+	switch {
+	case added.Has(StateAbove):
+		next = next.Remove(StateBelow)
+	case added.Has(StateBelow):
+		next = next.Remove(StateAbove)
+	case next.Has(StateAbove | StateBelow):
+		next = next.Remove(StateBelow)
+	}
+	return next
+}
+
+// WmStateApply applies a decoded _NET_WM_STATE request to its window. The
+// window's current _NET_WM_STATE is read, the request is applied with
+// StateApply and the property is written back if it changed. States in the
+// property that aren't defined by the EWMH are kept.
+//
+// The returned StateDiff tells the window manager what it has to do, e.g.,
+// make the window fullscreen if diff.Added().Has(StateFullscreen).
+func WmStateApply(xu *xgbutil.XUtil, msg WmStateMsg) (StateDiff, error) {
+	names, _ := WmStateGet(xu, msg.Window)
+	cur := StateFromNames(names)
+	diff := StateDiff{
+		Window: msg.Window,
+		Old:    cur,
+		New:    StateApply(cur, msg.Action, msg.States),
+	}
+	if !diff.Changed() {
+		return diff, nil
+	}
+
+	// Keep the states that we don't know about.
+	all := make([]string, 0, len(names))
+	for _, name := range names {
+		if StateFromNames([]string{name}) == 0 {
+			all = append(all, name)
+		}
+	}
+	all = append(all, diff.New.Names()...)
+	return diff, WmStateSet(xu, msg.Window, all)
+}
@@ -0,0 +1,67 @@
+package ewmh
+
+import "testing"
+
+func TestStateApply(t *testing.T) {
+	tests := []struct {
+		desc   string
+		cur    State
+		action int
+		states State
+		want   State
+	}{
+		{"toggle maximized on", 0, StateToggle, StateMaximized,
+			StateMaximized},
+		{"toggle maximized off", StateMaximized, StateToggle,
+			StateMaximized, 0},
+		{"toggle half maximized", StateMaximizedVert, StateToggle,
+			StateMaximized, StateMaximized},
+		{"toggle one direction", StateMaximized, StateToggle,
+			StateMaximizedHorz, StateMaximizedVert},
+		{"toggle maximized with another state", StateMaximizedHorz |
+			StateSticky, StateToggle, StateMaximized | StateShaded,
+			StateMaximized | StateSticky | StateShaded},
+		{"add maximized", StateMaximizedVert, StateAdd, StateMaximized,
+			StateMaximized},
+		{"remove maximized", StateMaximized | StateSticky, StateRemove,
+			StateMaximized, StateSticky},
+		{"add above over below", StateBelow, StateAdd, StateAbove,
+			StateAbove},
+		{"add below over above", StateAbove, StateAdd, StateBelow,
+			StateBelow},
+		{"add above and below", 0, StateAdd, StateAbove | StateBelow,
+			StateAbove},
+		{"toggle above over below", StateBelow, StateToggle, StateAbove,
+			StateAbove},
+		{"toggle above off", StateAbove, StateToggle, StateAbove, 0},
+		{"toggle above and below", StateAbove, StateToggle,
+			StateAbove | StateBelow, StateBelow},
+		{"above and below already set", StateAbove | StateBelow, StateAdd,
+			StateSticky, StateAbove | StateSticky},
+		{"add hidden", 0, StateAdd, StateHidden, 0},
+		{"remove hidden", StateHidden, StateRemove, StateHidden,
+			StateHidden},
+		{"toggle focused", StateFocused, StateToggle,
+			StateFocused | StateShaded, StateFocused | StateShaded},
+		{"bad action", StateSticky, 3, StateSticky, StateSticky},
+	}
+	for _, test := range tests {
+		got := StateApply(test.cur, test.action, test.states)
+		if got != test.want {
+			t.Errorf("%s: got %s, want %s", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestStateDiff(t *testing.T) {
+	d := StateDiff{
+		Old: StateSticky | StateAbove,
+		New: StateSticky | StateBelow,
+	}
+	if d.Added() != StateBelow || d.Removed() != StateAbove {
+		t.Errorf("got added %s and removed %s", d.Added(), d.Removed())
+	}
+	if !d.Changed() {
+		t.Error("diff is unchanged")
+	}
+}
@@ -49,13 +49,39 @@ often looks like this:
 Something similar can be said for the _NET_WM_ICON and the IconPixmap field
 in WM_HINTS.
 
+Input focus
+
+Section 4.1.7 of the ICCCM defines four input models that depend on a client's
+WM_HINTS and WM_PROTOCOLS. Window managers can use Focus to give the focus to
+a client in whichever way its input model requires:
+
+	if _, err := icccm.Focus(XUtilValue, window-id); err != nil {
+		// handle error
+	}
+
+Manager selections
+
+Section 2.8 of the ICCCM describes how "managers" (window managers, system
+trays, compositing managers, etc.) announce themselves by owning a selection
+like WM_S0. AcquireManagerSelection takes care of the whole dance, including
+replacing a running manager:
+
+	ms, err := icccm.AcquireManagerSelection(XUtilValue,
+		icccm.ManagerSelectionName(XUtilValue, "WM_S"), true, 5*time.Second,
+		func(ms *icccm.ManagerSelection) {
+			// We've been replaced. Clean up and quit.
+			xevent.Quit(ms.X)
+		})
+	if ms == nil {
+		log.Fatal(err)
+	}
+
 Naming scheme
 
 The naming scheme is precisely the same as the one found in the ewmh package.
 The documentation for the ewmh package describes the naming scheme in more
-detail. The only difference (currently) is that the icccm package only contains
-functions ending in "Get" and "Set". It is planned to add "Req" functions. (An
-example of a Req function would be to send a ClientMessage implementing the
-WM_DELETE_WINDOW protocol to a client window.)
+detail. Most functions end in "Get" and "Set", but there are also a few "Req"
+functions that send client messages. (For example, TakeFocusReq sends a
+ClientMessage implementing the WM_TAKE_FOCUS protocol to a client window.)
 */
 package icccm
@@ -0,0 +1,124 @@
+package icccm
+
+/*
+icccm/focus.go contains the window manager side of the input focus models
+described in section 4.1.7 of the ICCCM. The model a client uses is decided by
+the Input field of its WM_HINTS and whether WM_TAKE_FOCUS is in its
+WM_PROTOCOLS:
+
+	Input Model         Input Field   WM_TAKE_FOCUS
+	No Input            False         Absent
+	Passive             True          Absent
+	Locally Active      True          Present
+	Globally Active     False         Present
+*/
+
+import (
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// Input models. See the table at the top of icccm/focus.go.
+const (
+	InputModelNone = iota
+	InputModelPassive
+	InputModelLocallyActive
+	InputModelGloballyActive
+)
+
+// InputModel inspects the WM_HINTS and WM_PROTOCOLS of a client window and
+// returns its input model (one of the InputModel constants).
+// A client without WM_HINTS, or whose WM_HINTS doesn't have the Input flag
+// set, is assumed to want input. This is what most window managers do, since
+// many clients forget to set it.
+func InputModel(xu *xgbutil.XUtil, win xproto.Window) int {
+	input := true
+	if hints, err := WmHintsGet(xu, win); err == nil {
+		if hints.Flags&HintInput > 0 {
+			input = hints.Input != 0
+		}
+	}
+
+	takeFocus := false
+	prots, _ := WmProtocolsGet(xu, win)
+	for _, prot := range prots {
+		if prot == "WM_TAKE_FOCUS" {
+			takeFocus = true
+			break
+		}
+	}
+
+	switch {
+	case input && takeFocus:
+		return InputModelLocallyActive
+	case input:
+		return InputModelPassive
+	case takeFocus:
+		return InputModelGloballyActive
+	}
+	return InputModelNone
+}
+
+// Focus gives the input focus to a client window according to its input
+// model, and returns the input model used. Namely:
+//
+// No Input: nothing is done. (The window manager should probably give the
+// focus to some other window, like the root window or the frame.)
+//
+// Passive: the focus is set to the client with SetInputFocus.
+//
+// Locally Active: the focus is set to the client with SetInputFocus, and a
+// WM_TAKE_FOCUS message is sent to it so that it can move the focus to one of
+// its sub-windows.
+//
+// Globally Active: a WM_TAKE_FOCUS message is sent to the client, and it is
+// up to the client to set the focus (or not).
+//
+// The timestamp used is xu.TimeGet, i.e., the time of the last event that
+// was processed. Using CurrentTime instead is forbidden by the ICCCM, and
+// causes races when the focus changes quickly.
+func Focus(xu *xgbutil.XUtil, win xproto.Window) (int, error) {
+	model := InputModel(xu, win)
+	tstamp := xu.TimeGet()
+
+	if model == InputModelPassive || model == InputModelLocallyActive {
+		err := xproto.SetInputFocusChecked(xu.Conn(),
+			xproto.InputFocusPointerRoot, win, tstamp).Check()
+		if err != nil {
+			return model, err
+		}
+	}
+	if model == InputModelLocallyActive || model == InputModelGloballyActive {
+		if err := TakeFocusReq(xu, win, tstamp); err != nil {
+			return model, err
+		}
+	}
+	return model, nil
+}
+
+// WM_TAKE_FOCUS req
+// This sends a WM_TAKE_FOCUS message to 'win'. It doesn't check whether 'win'
+// supports the protocol. (Use InputModel or Focus for that.)
+func TakeFocusReq(xu *xgbutil.XUtil, win xproto.Window,
+	tstamp xproto.Timestamp) error {
+
+	return protocolReq(xu, win, "WM_TAKE_FOCUS", tstamp)
+}
+
+// protocolReq sends a WM_PROTOCOLS client message for the protocol 'name' to
+// the client window 'win', as described in section 4.2.8 of the ICCCM.
+// The message is sent to the client itself with an empty event mask, so only
+// the client that created the window receives it.
+func protocolReq(xu *xgbutil.XUtil, win xproto.Window, name string,
+	tstamp xproto.Timestamp) error {
+
+	protAtom, err := xprop.Atm(xu, name)
+	if err != nil {
+		return err
+	}
+	return xevent.SendClientMessage(xu, win, win, "WM_PROTOCOLS",
+		int(protAtom), int(tstamp))
+}
@@ -283,6 +283,62 @@ func WmClientMachineSet(xu *xgbutil.XUtil, win xproto.Window,
 		([]byte)(client))
 }
 
+// WM_CLIENT_LEADER get
+func WmClientLeaderGet(xu *xgbutil.XUtil,
+	win xproto.Window) (xproto.Window, error) {
+
+	return xprop.PropValWindow(xprop.GetProperty(xu, win, "WM_CLIENT_LEADER"))
+}
+
+// WM_CLIENT_LEADER set
+func WmClientLeaderSet(xu *xgbutil.XUtil, win xproto.Window,
+	leader xproto.Window) error {
+
+	return xprop.ChangeProp32(xu, win, "WM_CLIENT_LEADER", "WINDOW",
+		uint(leader))
+}
+
+// SM_CLIENT_ID get
+// This property should be set on the client leader window (the window in
+// WM_CLIENT_LEADER).
+func SmClientIdGet(xu *xgbutil.XUtil, win xproto.Window) (string, error) {
+	return xprop.PropValStr(xprop.GetProperty(xu, win, "SM_CLIENT_ID"))
+}
+
+// SM_CLIENT_ID set
+func SmClientIdSet(xu *xgbutil.XUtil, win xproto.Window, id string) error {
+	return xprop.ChangeProp(xu, win, 8, "SM_CLIENT_ID", "STRING",
+		([]byte)(id))
+}
+
+// WM_WINDOW_ROLE get
+func WmWindowRoleGet(xu *xgbutil.XUtil, win xproto.Window) (string, error) {
+	return xprop.PropValStr(xprop.GetProperty(xu, win, "WM_WINDOW_ROLE"))
+}
+
+// WM_WINDOW_ROLE set
+func WmWindowRoleSet(xu *xgbutil.XUtil, win xproto.Window, role string) error {
+	return xprop.ChangeProp(xu, win, 8, "WM_WINDOW_ROLE", "STRING",
+		([]byte)(role))
+}
+
+// WM_COMMAND get
+func WmCommandGet(xu *xgbutil.XUtil, win xproto.Window) ([]string, error) {
+	return xprop.PropValStrs(xprop.GetProperty(xu, win, "WM_COMMAND"))
+}
+
+// WM_COMMAND set
+// WM_COMMAND is obsolete, but is still used by some session managers (and
+// window managers) when the client doesn't speak XSMP.
+func WmCommandSet(xu *xgbutil.XUtil, win xproto.Window, args []string) error {
+	nullterm := make([]byte, 0)
+	for _, arg := range args {
+		nullterm = append(nullterm, arg...)
+		nullterm = append(nullterm, 0)
+	}
+	return xprop.ChangeProp(xu, win, 8, "WM_COMMAND", "STRING", nullterm)
+}
+
 // WmState is a struct that organizes information related to the WM_STATE
 // property. Namely, the state (corresponding to a State* constant in this file)
 // and the icon window (probably not used).
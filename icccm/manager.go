@@ -0,0 +1,254 @@
+package icccm
+
+/*
+icccm/manager.go implements the manager selection conventions described in
+section 2.8 of the ICCCM. They are used by window managers (WM_Sn), system
+trays (_NET_SYSTEM_TRAY_Sn), compositing managers (_NET_WM_CM_Sn), settings
+managers (_XSETTINGS_Sn) and the like.
+*/
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// ManagerSelection represents ownership of a manager selection.
+// Win is the window created to own the selection, Time is the timestamp used
+// to acquire it and Previous is the window of the manager that was replaced.
+// (Previous is 0 if there was no manager running.)
+type ManagerSelection struct {
+	X        *xgbutil.XUtil
+	Name     string
+	Atom     xproto.Atom
+	Win      xproto.Window
+	Time     xproto.Timestamp
+	Previous xproto.Window
+}
+
+// ManagerSelectionName appends the number of the default screen to 'prefix'.
+// e.g., ManagerSelectionName(X, "WM_S") returns "WM_S0" on screen 0.
+func ManagerSelectionName(xu *xgbutil.XUtil, prefix string) string {
+	return fmt.Sprintf("%s%d", prefix, xu.Conn().DefaultScreen)
+}
+
+// ManagerSelectionOwner returns the window that currently owns the selection
+// 'name', or 0 if the selection isn't owned.
+func ManagerSelectionOwner(xu *xgbutil.XUtil,
+	name string) (xproto.Window, error) {
+
+	selAtom, err := xprop.Atm(xu, name)
+	if err != nil {
+		return 0, err
+	}
+
+	reply, err := xproto.GetSelectionOwner(xu.Conn(), selAtom).Reply()
+	if err != nil {
+		return 0, err
+	}
+	return reply.Owner, nil
+}
+
+// AcquireManagerSelection takes ownership of the manager selection 'name'
+// (e.g., "WM_S0") following section 2.8 of the ICCCM. Namely, it creates a
+// window to own the selection, acquires the selection with a proper
+// timestamp, waits for the previous owner (if any) to destroy its window and
+// finally announces the new manager with a MANAGER client message sent to
+// the root window.
+//
+// If the selection is already owned, an error is returned unless 'replace'
+// is true. (This corresponds to the '--replace' flag found on most window
+// managers.)
+//
+// The previous owner is given 'timeout' to destroy its window. If it doesn't,
+// the selection is still ours and a valid ManagerSelection is returned *along
+// with* an error. Callers can then decide whether to carry on anyway or to
+// kill the old manager with xproto.KillClient.
+//
+// 'replaced' is called when another manager takes the selection away from
+// us, and may be nil. It should do whatever is needed to exit gracefully.
+// The selection window is destroyed after 'replaced' returns, which tells
+// the new manager that it may proceed.
+//
+// AcquireManagerSelection reads events directly from X while it waits. It
+// should be called either before the main event loop is started, or from
+// within an event handler.
+func AcquireManagerSelection(xu *xgbutil.XUtil, name string, replace bool,
+	timeout time.Duration,
+	replaced func(ms *ManagerSelection)) (*ManagerSelection, error) {
+
+	selAtom, err := xprop.Atm(xu, name)
+	if err != nil {
+		return nil, err
+	}
+	managerAtom, err := xprop.Atm(xu, "MANAGER")
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := ManagerSelectionOwner(xu, name)
+	if err != nil {
+		return nil, err
+	}
+	if old != 0 && !replace {
+		return nil, fmt.Errorf("AcquireManagerSelection: The selection '%s' "+
+			"is already owned by window %x.", name, old)
+	}
+
+	win, err := xproto.NewWindowId(xu.Conn())
+	if err != nil {
+		return nil, err
+	}
+	err = xproto.CreateWindowChecked(xu.Conn(), xu.Screen().RootDepth, win,
+		xu.RootWin(), -1, -1, 1, 1, 0,
+		xproto.WindowClassInputOutput, xu.Screen().RootVisual,
+		xproto.CwOverrideRedirect|xproto.CwEventMask,
+		[]uint32{1, xproto.EventMaskPropertyChange}).Check()
+	if err != nil {
+		return nil, err
+	}
+
+	ms := &ManagerSelection{
+		X:        xu,
+		Name:     name,
+		Atom:     selAtom,
+		Win:      win,
+		Previous: old,
+	}
+	if ms.Time, err = serverTime(xu, win); err != nil {
+		xproto.DestroyWindow(xu.Conn(), win)
+		return nil, err
+	}
+
+	// We want to know when the old owner destroys its window. If this fails,
+	// then the old owner has already gone away.
+	if old != 0 {
+		err = xproto.ChangeWindowAttributesChecked(xu.Conn(), old,
+			xproto.CwEventMask,
+			[]uint32{xproto.EventMaskStructureNotify}).Check()
+		if err != nil {
+			old = 0
+		}
+	}
+
+	xproto.SetSelectionOwner(xu.Conn(), win, selAtom, ms.Time)
+	owner, err := ManagerSelectionOwner(xu, name)
+	if err != nil || owner != win {
+		xproto.DestroyWindow(xu.Conn(), win)
+		return nil, fmt.Errorf("AcquireManagerSelection: Could not acquire "+
+			"ownership of the selection '%s'.", name)
+	}
+
+	xevent.SelectionClearFun(
+		func(xu *xgbutil.XUtil, ev xevent.SelectionClearEvent) {
+			if ev.Selection != ms.Atom {
+				return
+			}
+			if replaced != nil {
+				replaced(ms)
+			}
+			xevent.Detach(xu, ms.Win)
+			xproto.DestroyWindow(xu.Conn(), ms.Win)
+		}).Connect(xu, win)
+
+	var waitErr error
+	if old != 0 {
+		_, destroyed := waitEvent(xu, timeout, false,
+			func(ev xgb.Event) bool {
+				e, ok := ev.(xproto.DestroyNotifyEvent)
+				return ok && e.Window == old
+			})
+		if !destroyed {
+			waitErr = fmt.Errorf("AcquireManagerSelection: The previous "+
+				"owner of '%s' (%x) did not destroy its window within %s.",
+				name, old, timeout)
+		}
+	}
+
+	cm, err := xevent.NewClientMessage(32, xu.RootWin(), managerAtom,
+		int(ms.Time), int(selAtom), int(win))
+	if err != nil {
+		ms.Release()
+		return nil, err
+	}
+	err = xevent.SendRootEvent(xu, cm, xproto.EventMaskStructureNotify)
+	if err != nil {
+		ms.Release()
+		return nil, err
+	}
+	return ms, waitErr
+}
+
+// Release gives up ownership of the manager selection and destroys the
+// selection window.
+func (ms *ManagerSelection) Release() {
+	xproto.SetSelectionOwner(ms.X.Conn(), 0, ms.Atom, ms.Time)
+	xevent.Detach(ms.X, ms.Win)
+	xproto.DestroyWindow(ms.X.Conn(), ms.Win)
+}
+
+// serverTime gets a current timestamp from the X server by appending zero
+// bytes to a property on 'win' and waiting for the resulting PropertyNotify
+// event. 'win' must have PropertyChange events selected.
+// (ICCCM forbids using CurrentTime when acquiring a selection.)
+func serverTime(xu *xgbutil.XUtil,
+	win xproto.Window) (xproto.Timestamp, error) {
+
+	propAtom, err := xprop.Atm(xu, "_XGBUTIL_TIMESTAMP")
+	if err != nil {
+		return 0, err
+	}
+	strAtom, err := xprop.Atm(xu, "STRING")
+	if err != nil {
+		return 0, err
+	}
+
+	xproto.ChangeProperty(xu.Conn(), xproto.PropModeAppend, win,
+		propAtom, strAtom, 8, 0, nil)
+	ev, ok := waitEvent(xu, 5*time.Second, true,
+		func(ev xgb.Event) bool {
+			e, ok := ev.(xproto.PropertyNotifyEvent)
+			return ok && e.Window == win && e.Atom == propAtom
+		})
+	if !ok {
+		return 0, fmt.Errorf("serverTime: Timed out waiting for a " +
+			"PropertyNotify event.")
+	}
+	return ev.(xproto.PropertyNotifyEvent).Time, nil
+}
+
+// waitEvent reads events into xgbutil's event queue until an event that
+// satisfies 'match' shows up or until 'timeout' has elapsed. If 'remove' is
+// true, the matching event is removed from the queue. Otherwise, it will be
+// processed as usual by the main event loop.
+//
+// Events are read with blocking reads. When 'timeout' has elapsed, the
+// message sent by an xevent timer wakes up the last read.
+func waitEvent(xu *xgbutil.XUtil, timeout time.Duration, remove bool,
+	match func(ev xgb.Event) bool) (xgb.Event, bool) {
+
+	deadline := time.Now().Add(timeout)
+	wake := xevent.AfterFunc(xu, timeout, func() {})
+	defer wake.Stop()
+
+	for {
+		for i, everr := range xevent.Peek(xu) {
+			if everr.Event != nil && match(everr.Event) {
+				if remove {
+					xevent.DequeueAt(xu, i)
+				}
+				return everr.Event, true
+			}
+		}
+		if !time.Now().Before(deadline) {
+			return nil, false
+		}
+		xevent.Read(xu, true)
+	}
+}
@@ -0,0 +1,148 @@
+package icccm
+
+/*
+icccm/sizehints.go contains functions that apply the constraints described
+by WM_NORMAL_HINTS to window sizes, as described in section 4.1.2.3 of the
+ICCCM. This is typically what a window manager does whenever a client is
+resized.
+*/
+
+// sizeLimits collects the constraints in a NormalHints value, with the
+// ICCCM defaults filled in for any constraint that isn't set.
+type sizeLimits struct {
+	minW, minH, maxW, maxH, baseW, baseH, incW, incH int
+
+	aspect                         bool
+	minNum, minDen, maxNum, maxDen int
+}
+
+// limits computes the sizeLimits for a NormalHints value. Namely, a base size
+// is used as the minimum size if there is no minimum size, and vice versa.
+// Increments default to 1 and maximums default to "unbounded" (a value of 0).
+func limits(nh *NormalHints) sizeLimits {
+	lim := sizeLimits{incW: 1, incH: 1}
+	if nh == nil {
+		return lim
+	}
+
+	hasMin := nh.Flags&SizeHintPMinSize > 0
+	hasBase := nh.Flags&SizeHintPBaseSize > 0
+	switch {
+	case hasMin && hasBase:
+		lim.minW, lim.minH = int(nh.MinWidth), int(nh.MinHeight)
+		lim.baseW, lim.baseH = int(nh.BaseWidth), int(nh.BaseHeight)
+	case hasMin:
+		lim.minW, lim.minH = int(nh.MinWidth), int(nh.MinHeight)
+		lim.baseW, lim.baseH = lim.minW, lim.minH
+	case hasBase:
+		lim.baseW, lim.baseH = int(nh.BaseWidth), int(nh.BaseHeight)
+		lim.minW, lim.minH = lim.baseW, lim.baseH
+	}
+
+	if nh.Flags&SizeHintPMaxSize > 0 {
+		lim.maxW, lim.maxH = int(nh.MaxWidth), int(nh.MaxHeight)
+	}
+	if nh.Flags&SizeHintPResizeInc > 0 {
+		if nh.WidthInc > 0 {
+			lim.incW = int(nh.WidthInc)
+		}
+		if nh.HeightInc > 0 {
+			lim.incH = int(nh.HeightInc)
+		}
+	}
+	if nh.Flags&SizeHintPAspect > 0 {
+		lim.minNum, lim.minDen = int(nh.MinAspectNum), int(nh.MinAspectDen)
+		lim.maxNum, lim.maxDen = int(nh.MaxAspectNum), int(nh.MaxAspectDen)
+		lim.aspect = lim.minNum > 0 && lim.minDen > 0 &&
+			lim.maxNum > 0 && lim.maxDen > 0
+	}
+	return lim
+}
+
+// ConstrainSize takes a proposed width and height for a client window and
+// returns the closest size that satisfies the WM_NORMAL_HINTS 'nh'. Namely,
+// the size is clamped to the minimum and maximum sizes, shrunk to satisfy the
+// aspect ratio limits (but never below the minimum size) and rounded down to
+// the nearest resize increment (relative to the base size). Only the hints
+// with the corresponding bit set in nh.Flags are used. 'nh' may be nil, in
+// which case only the requirement that the width and height be at least 1 is
+// applied.
+//
+// The width and height are the size of the client window alone, i.e., without
+// any window manager decorations.
+func ConstrainSize(nh *NormalHints, width, height int) (int, int) {
+	lim := limits(nh)
+	w, h := clamp(width, lim.minW, lim.maxW), clamp(height, lim.minH, lim.maxH)
+
+	// Aspect ratios are applied to the size without the base size. The
+	// window is shrunk along whichever dimension is too big.
+	if lim.aspect {
+		bw, bh := 0, 0
+		if nh.Flags&SizeHintPBaseSize > 0 {
+			bw, bh = lim.baseW, lim.baseH
+		}
+		dw, dh := w-bw, h-bh
+		if dw > 0 && dh > 0 {
+			if dw*lim.minDen < dh*lim.minNum {
+				dh = dw * lim.minDen / lim.minNum
+			} else if dw*lim.maxDen > dh*lim.maxNum {
+				dw = dh * lim.maxNum / lim.maxDen
+			}
+			w, h = dw+bw, dh+bh
+		}
+
+		// The minimum size takes precedence over the aspect ratio
+		// (ICCCM 4.1.2.3), so shrinking mustn't go below it.
+		w, h = clamp(w, lim.minW, lim.maxW), clamp(h, lim.minH, lim.maxH)
+	}
+
+	w = increment(w, lim.baseW, lim.incW, lim.minW, lim.maxW)
+	h = increment(h, lim.baseH, lim.incH, lim.minH, lim.maxH)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+// SizeIncrements returns the size of a client window in terms of its resize
+// increments. For example, a terminal with a base width of 4, a width
+// increment of 8 and a width of 644 is 80 columns wide. If the window has no
+// resize increments, the size is returned in pixels (minus the base size).
+// This is useful for showing feedback to the user during a resize.
+func SizeIncrements(nh *NormalHints, width, height int) (int, int) {
+	lim := limits(nh)
+	return (width - lim.baseW) / lim.incW, (height - lim.baseH) / lim.incH
+}
+
+// increment rounds 'size' down to the nearest value of the form
+// 'base + i*inc' that is still within [lo, hi]. If rounding down would
+// violate the minimum, the size is rounded up instead.
+func increment(size, base, inc, lo, hi int) int {
+	if inc <= 1 || size <= base {
+		return size
+	}
+
+	size = base + ((size-base)/inc)*inc
+	if size < lo {
+		size += inc
+	}
+	if hi > 0 && size > hi {
+		size -= inc
+	}
+	return size
+}
+
+// clamp forces 'v' into the range [lo, hi]. A 'hi' of 0 means the range is
+// unbounded above.
+func clamp(v, lo, hi int) int {
+	if hi > 0 && v > hi {
+		v = hi
+	}
+	if v < lo {
+		v = lo
+	}
+	return v
+}
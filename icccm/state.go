@@ -0,0 +1,228 @@
+package icccm
+
+/*
+icccm/state.go contains both sides of the client state transitions described
+in section 4.1.4 of the ICCCM. Namely:
+
+	Withdrawn -> Normal/Iconic   the client maps the window
+	Iconic -> Normal             the client maps the window
+	Normal -> Iconic             the client sends WM_CHANGE_STATE to the root
+	Normal/Iconic -> Withdrawn   the client unmaps the window and sends a
+	                             synthetic UnmapNotify event to the root
+*/
+
+import (
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// WM_CHANGE_STATE req
+// The only state transition that the ICCCM defines for WM_CHANGE_STATE is to
+// StateIconic. Use Iconify for that.
+func WmChangeStateReq(xu *xgbutil.XUtil, win xproto.Window,
+	state uint) error {
+
+	changeAtom, err := xprop.Atm(xu, "WM_CHANGE_STATE")
+	if err != nil {
+		return err
+	}
+
+	cm, err := xevent.NewClientMessage(32, win, changeAtom, int(state))
+	if err != nil {
+		return err
+	}
+	return xevent.SendRootEvent(xu, cm, uint32(
+		xproto.EventMaskSubstructureRedirect|
+			xproto.EventMaskSubstructureNotify))
+}
+
+// Iconify asks the window manager to move 'win' from the Normal state to the
+// Iconic state. (To go back to the Normal state, simply map the window.)
+func Iconify(xu *xgbutil.XUtil, win xproto.Window) error {
+	return WmChangeStateReq(xu, win, StateIconic)
+}
+
+// Withdraw moves 'win' to the Withdrawn state by unmapping it and then
+// sending a synthetic UnmapNotify event to the root window. The synthetic
+// event is needed because the window manager may not see the real one (for
+// instance, when the window is iconified and therefore already unmapped).
+// Once the window manager has removed the WM_STATE property from 'win', the
+// window may be reused.
+func Withdraw(xu *xgbutil.XUtil, win xproto.Window) error {
+	xproto.UnmapWindow(xu.Conn(), win)
+
+	ev := xproto.UnmapNotifyEvent{
+		Event:         xu.RootWin(),
+		Window:        win,
+		FromConfigure: false,
+	}
+	return xevent.SendRootEvent(xu, ev, uint32(
+		xproto.EventMaskSubstructureRedirect|
+			xproto.EventMaskSubstructureNotify))
+}
+
+// StateChange describes a request from a client to move one of its windows
+// from one state to another. From is the state in the window's WM_STATE
+// property (or StateWithdrawn if it has none), and To is the requested state.
+// Both are one of StateWithdrawn, StateNormal or StateIconic.
+type StateChange struct {
+	Window   xproto.Window
+	From, To uint
+}
+
+// StateChangeFun is called for each state change request decoded by a
+// StateWatcher.
+type StateChangeFun func(xu *xgbutil.XUtil, sc StateChange)
+
+// StateWatcher decodes client state change requests into StateChange values.
+// Use WatchStateChanges to create one, and Detach to stop it.
+type StateWatcher struct {
+	X   *xgbutil.XUtil
+	fun StateChangeFun
+
+	changeAtom, stateAtom xproto.Atom
+
+	// states holds the WM_STATE of the windows that have one, as seen in
+	// PropertyNotify events. Only these windows are managed by the window
+	// manager, so other windows' UnmapNotify events are skipped.
+	states map[xproto.Window]uint
+
+	// hook sees the events before they are dispatched, since they are
+	// reported on whatever window the window manager selected them on.
+	hook xevent.HookFun
+}
+
+// WatchStateChanges decodes client state change requests into StateChange
+// values and passes them to 'fun'. It is meant to be used by window managers,
+// which must already have SubstructureRedirect selected on the root window,
+// and PropertyChange selected on every window they manage. The events
+// involved are still processed by any other event handlers.
+//
+// MapRequest events become changes to StateNormal, unless a withdrawn window
+// asks to start in the Iconic state with the InitialState of its WM_HINTS.
+// WM_CHANGE_STATE client messages become changes to StateIconic.
+// UnmapNotify events reported to the root window (which includes the
+// synthetic events sent by Withdraw) become changes to StateWithdrawn.
+//
+// The current state of each window is taken from the PropertyNotify events
+// of its WM_STATE property, so the watcher should be started before any
+// windows are managed. Since XGB doesn't tell synthetic events from real
+// ones, UnmapNotify events are only decoded for windows that have WM_STATE
+// (which leaves out frames and override-redirect windows). Note that a
+// non-reparenting window manager will also see the UnmapNotify events caused
+// by its own unmapping of client windows (e.g., when iconifying them). It
+// must ignore those itself.
+//
+// Also note that WM_STATE isn't updated. The window manager should do that
+// with WmStateSet (or remove the property for StateWithdrawn) once it has
+// carried out the request.
+func WatchStateChanges(xu *xgbutil.XUtil,
+	fun StateChangeFun) (*StateWatcher, error) {
+
+	w := &StateWatcher{
+		X:      xu,
+		fun:    fun,
+		states: make(map[xproto.Window]uint),
+	}
+	var err error
+	w.changeAtom, err = xprop.Atm(xu, "WM_CHANGE_STATE")
+	if err != nil {
+		return nil, err
+	}
+	w.stateAtom, err = xprop.Atm(xu, "WM_STATE")
+	if err != nil {
+		return nil, err
+	}
+
+	w.hook = func(xu *xgbutil.XUtil, event interface{}) bool {
+		if sc, ok := w.decode(event); ok {
+			w.fun(xu, sc)
+		}
+		return true
+	}
+	xevent.AttachHook(xu, &w.hook)
+	return w, nil
+}
+
+// Detach stops the watcher.
+func (w *StateWatcher) Detach() {
+	xevent.DisconnectHook(w.X, &w.hook)
+}
+
+// decode returns the state change requested by 'event', if any. Requests
+// are only made to the X server for MapRequest events and changes to
+// WM_STATE.
+func (w *StateWatcher) decode(event interface{}) (StateChange, bool) {
+	var sc StateChange
+	switch ev := event.(type) {
+	case xproto.PropertyNotifyEvent:
+		if ev.Atom == w.stateAtom {
+			w.stateLoad(ev)
+		}
+		return sc, false
+	case xproto.DestroyNotifyEvent:
+		delete(w.states, ev.Window)
+		return sc, false
+	case xproto.MapRequestEvent:
+		sc.Window = ev.Window
+		sc.From = w.current(ev.Window)
+		sc.To = StateNormal
+		if sc.From == StateWithdrawn {
+			hints, err := WmHintsGet(w.X, ev.Window)
+			if err == nil && hints.Flags&HintState > 0 &&
+				hints.InitialState == StateIconic {
+
+				sc.To = StateIconic
+			}
+		}
+	case xproto.ClientMessageEvent:
+		if ev.Format != 32 || ev.Type != w.changeAtom ||
+			ev.Data.Data32[0] != StateIconic {
+
+			return sc, false
+		}
+		sc.Window = ev.Window
+		sc.From = w.current(ev.Window)
+		sc.To = StateIconic
+	case xproto.UnmapNotifyEvent:
+		if ev.Event != w.X.RootWin() {
+			return sc, false
+		}
+		if _, ok := w.states[ev.Window]; !ok {
+			return sc, false
+		}
+		sc.Window = ev.Window
+		sc.From = w.current(ev.Window)
+		sc.To = StateWithdrawn
+	default:
+		return sc, false
+	}
+	return sc, true
+}
+
+// stateLoad reads the WM_STATE of a window after it changed, or forgets it
+// if it was removed.
+func (w *StateWatcher) stateLoad(ev xproto.PropertyNotifyEvent) {
+	if ev.State == xproto.PropertyDelete {
+		delete(w.states, ev.Window)
+		return
+	}
+	state, err := WmStateGet(w.X, ev.Window)
+	if err != nil {
+		delete(w.states, ev.Window)
+		return
+	}
+	w.states[ev.Window] = state.State
+}
+
+// current returns the state in the WM_STATE property of 'win', or
+// StateWithdrawn if it has no such property.
+func (w *StateWatcher) current(win xproto.Window) uint {
+	if state, ok := w.states[win]; ok {
+		return state
+	}
+	return StateWithdrawn
+}
@@ -0,0 +1,64 @@
+/*
+Package startup implements the startup notification protocol, which lets
+launchers, panels and window managers give feedback (like a busy cursor or a
+"Starting..." entry in a task bar) while an application is starting up.
+
+The protocol is described here:
+http://www.freedesktop.org/wiki/Specifications/startup-notification-spec
+
+In short, a launcher broadcasts a "new" message with a unique ID before
+starting an application, and passes the ID to the application in the
+DESKTOP_STARTUP_ID environment variable. The application stores the ID in
+_NET_STARTUP_ID on its first top-level window, and broadcasts a "remove"
+message once that window has been mapped. Messages are broadcast with
+multiple client messages, since each one can only carry 20 bytes; Send and
+Listen take care of that.
+
+Launching an application
+
+	id := startup.NewId(XUtilValue, "my-launcher")
+	startup.Initiate(XUtilValue, id, map[string]string{
+		"NAME":   "Text Editor",
+		"BIN":    "gedit",
+		"SCREEN": "0",
+	})
+
+	cmd := exec.Command("gedit")
+	cmd.Env = append(os.Environ(), startup.EnvVar+"="+id)
+	if err := cmd.Start(); err != nil {
+		startup.Complete(XUtilValue, id)
+	}
+
+Being launched
+
+	id := startup.IdFromEnv()
+	if len(id) > 0 {
+		ewmh.StartupIdSet(XUtilValue, win.Id, id)
+	}
+	win.Map()
+	if len(id) > 0 {
+		startup.Complete(XUtilValue, id)
+	}
+
+Following startup sequences
+
+A Monitor keeps track of every startup sequence in progress, and calls a
+function whenever one starts, changes, completes or times out:
+
+	monitor, err := startup.NewMonitor(XUtilValue, 0,
+		func(X *xgbutil.XUtil, seq *startup.Sequence, event int) {
+			switch event {
+			case startup.Initiated:
+				// Show a busy cursor or a task bar entry for seq.Name().
+			case startup.Completed, startup.TimedOut:
+				// Remove it.
+			}
+		})
+
+Window managers can use Monitor.Match to find the sequence that a new client
+window belongs to, e.g., to place it on Sequence.Desktop or to use
+Sequence.Timestamp for focus stealing prevention.
+
+A Monitor relies on xgbutil's main event loop (see xevent.Main).
+*/
+package startup
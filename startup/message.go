@@ -0,0 +1,314 @@
+package startup
+
+/*
+startup/message.go contains the wire format of startup notification messages.
+A message is a string like
+
+	new: ID=foo-1234_TIME5678 NAME="Text Editor" SCREEN=0
+
+which is sent to the root window, NUL terminated, in pieces of 20 bytes: the
+first piece in a _NET_STARTUP_INFO_BEGIN client message, and the rest in
+_NET_STARTUP_INFO client messages. All pieces of a message are sent with the
+same window, which is how receivers tell concurrent messages apart.
+
+Values that contain spaces, double quotes or backslashes are quoted with
+double quotes, and double quotes and backslashes in them are escaped with a
+backslash.
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// The types of startup notification messages.
+const (
+	TypeNew    = "new"
+	TypeChange = "change"
+	TypeRemove = "remove"
+)
+
+// Message is a single startup notification message. Values maps keys (e.g.,
+// "ID" or "NAME") to their unquoted values. Every message must have an "ID".
+type Message struct {
+	Type   string
+	Values map[string]string
+}
+
+// Id returns the ID of the startup sequence this message is about.
+func (msg *Message) Id() string {
+	return msg.Values["ID"]
+}
+
+// String returns the message in its wire format (without the terminating
+// NUL byte). ID always comes first, followed by the other keys in
+// alphabetical order.
+func (msg *Message) String() string {
+	keys := make([]string, 0, len(msg.Values))
+	for key := range msg.Values {
+		if key != "ID" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if _, ok := msg.Values["ID"]; ok {
+		keys = append([]string{"ID"}, keys...)
+	}
+
+	buf := bytes.NewBufferString(msg.Type)
+	buf.WriteString(":")
+	for _, key := range keys {
+		buf.WriteString(" ")
+		buf.WriteString(key)
+		buf.WriteString("=")
+		buf.WriteString(quote(msg.Values[key]))
+	}
+	return buf.String()
+}
+
+// ParseMessage parses a message in its wire format (without the terminating
+// NUL byte).
+func ParseMessage(s string) (*Message, error) {
+	colon := strings.IndexByte(s, ':')
+	if colon == -1 {
+		return nil, fmt.Errorf("ParseMessage: No message type in '%s'.", s)
+	}
+
+	msg := &Message{
+		Type:   s[:colon],
+		Values: make(map[string]string),
+	}
+	rest := s[colon+1:]
+	for {
+		rest = strings.TrimLeft(rest, " ")
+		if len(rest) == 0 {
+			break
+		}
+
+		eq := strings.IndexByte(rest, '=')
+		if eq == -1 || strings.IndexByte(rest[:eq], ' ') > -1 {
+			return nil, fmt.Errorf("ParseMessage: Expected KEY=VALUE but "+
+				"got '%s'.", rest)
+		}
+		key := rest[:eq]
+
+		var value string
+		var err error
+		value, rest, err = unquote(rest[eq+1:])
+		if err != nil {
+			return nil, fmt.Errorf("ParseMessage: Bad value for '%s': %s",
+				key, err)
+		}
+		msg.Values[key] = value
+	}
+	if len(msg.Id()) == 0 {
+		return nil, fmt.Errorf("ParseMessage: Message '%s' has no ID.", s)
+	}
+	return msg, nil
+}
+
+// quote quotes 'value' if it contains a space, a double quote or a
+// backslash.
+func quote(value string) string {
+	if !strings.ContainsAny(value, " \"\\") {
+		return value
+	}
+
+	buf := bytes.NewBufferString("\"")
+	for i := 0; i < len(value); i++ {
+		if value[i] == '"' || value[i] == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(value[i])
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// unquote reads a value from the beginning of 's', which ends at the first
+// space that isn't quoted or escaped. The unquoted value and the remainder of
+// 's' are returned.
+func unquote(s string) (string, string, error) {
+	buf := bytes.NewBuffer(nil)
+	quoted, escaped := false, false
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			buf.WriteByte(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == ' ' && !quoted:
+			return buf.String(), s[i:], nil
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	if quoted || escaped {
+		return "", "", fmt.Errorf("Unterminated quote or escape in '%s'.", s)
+	}
+	return buf.String(), "", nil
+}
+
+// Send broadcasts 'msg' to every client listening for startup notification
+// messages. A window is created to send the message with, and destroyed
+// afterwards.
+func Send(xu *xgbutil.XUtil, msg *Message) error {
+	beginAtom, err := xprop.Atm(xu, "_NET_STARTUP_INFO_BEGIN")
+	if err != nil {
+		return err
+	}
+	infoAtom, err := xprop.Atm(xu, "_NET_STARTUP_INFO")
+	if err != nil {
+		return err
+	}
+
+	win, err := xproto.NewWindowId(xu.Conn())
+	if err != nil {
+		return err
+	}
+	err = xproto.CreateWindowChecked(xu.Conn(), xu.Screen().RootDepth, win,
+		xu.RootWin(), -100, -100, 1, 1, 0,
+		xproto.WindowClassInputOutput, xu.Screen().RootVisual,
+		xproto.CwOverrideRedirect, []uint32{1}).Check()
+	if err != nil {
+		return err
+	}
+	defer xproto.DestroyWindow(xu.Conn(), win)
+
+	data := append([]byte(msg.String()), 0)
+	for i, typ := 0, beginAtom; i < len(data); i, typ = i+20, infoAtom {
+		piece := make([]byte, 20)
+		copy(piece, data[i:])
+
+		cm := xproto.ClientMessageEvent{
+			Format: 8,
+			Window: win,
+			Type:   typ,
+			Data:   xproto.ClientMessageDataUnionData8New(piece),
+		}
+		err = xproto.SendEventChecked(xu.Conn(), false, xu.RootWin(),
+			xproto.EventMaskPropertyChange, string(cm.Bytes())).Check()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxMessageLength is the length of the longest message a Listener
+// assembles. A message that grows longer without being terminated is dropped.
+const maxMessageLength = 8192
+
+// MessageFun is called with every startup notification message received.
+type MessageFun func(xu *xgbutil.XUtil, msg *Message)
+
+// Listener receives startup notification messages. It is returned by Listen,
+// and its Detach method stops it.
+type Listener struct {
+	X   *xgbutil.XUtil
+	fun MessageFun
+
+	beginAtom, infoAtom xproto.Atom
+
+	// Messages that haven't been received completely yet, keyed by the
+	// window they're sent with. (That window is also why a hook is used:
+	// ClientMessage callbacks are run for the event's window, which isn't
+	// known in advance.)
+	partial map[xproto.Window][]byte
+	hook    xevent.HookFun
+}
+
+// Listen selects PropertyChange events on the root window (startup
+// notification messages are sent with that event mask), assembles messages
+// from their pieces and calls 'fun' with every complete message. Messages
+// that aren't valid UTF-8, can't be parsed or are longer than 8 KiB are
+// ignored.
+//
+// Listen relies on xgbutil's main event loop (see xevent.Main).
+func Listen(xu *xgbutil.XUtil, fun MessageFun) (*Listener, error) {
+	l := &Listener{
+		X:       xu,
+		fun:     fun,
+		partial: make(map[xproto.Window][]byte),
+	}
+
+	var err error
+	if l.beginAtom, err = xprop.Atm(xu, "_NET_STARTUP_INFO_BEGIN"); err != nil {
+		return nil, err
+	}
+	if l.infoAtom, err = xprop.Atm(xu, "_NET_STARTUP_INFO"); err != nil {
+		return nil, err
+	}
+
+	root := xwindow.New(xu, xu.RootWin())
+	if err := root.ListenAdd(xproto.EventMaskPropertyChange); err != nil {
+		return nil, err
+	}
+
+	l.hook = func(xu *xgbutil.XUtil, event interface{}) bool {
+		if ev, ok := event.(xproto.ClientMessageEvent); ok {
+			l.piece(ev)
+		}
+		return true
+	}
+	xevent.AttachHook(xu, &l.hook)
+	return l, nil
+}
+
+// Detach stops listening for messages. Messages that have only been received
+// in part are dropped.
+func (l *Listener) Detach() {
+	xevent.DisconnectHook(l.X, &l.hook)
+	l.partial = make(map[xproto.Window][]byte)
+}
+
+// piece adds a piece of a message, and calls the MessageFun if the message
+// is complete.
+func (l *Listener) piece(ev xproto.ClientMessageEvent) {
+	if ev.Format != 8 {
+		return
+	}
+	switch ev.Type {
+	case l.beginAtom:
+		l.partial[ev.Window] = nil
+	case l.infoAtom:
+		if _, ok := l.partial[ev.Window]; !ok {
+			return
+		}
+	default:
+		return
+	}
+
+	piece := ev.Data.Data8
+	if nul := bytes.IndexByte(piece, 0); nul > -1 {
+		data := append(l.partial[ev.Window], piece[:nul]...)
+		delete(l.partial, ev.Window)
+		if !utf8.Valid(data) {
+			return
+		}
+		if msg, err := ParseMessage(string(data)); err == nil {
+			l.fun(l.X, msg)
+		}
+		return
+	}
+	if len(l.partial[ev.Window])+len(piece) > maxMessageLength {
+		delete(l.partial, ev.Window)
+		return
+	}
+	l.partial[ev.Window] = append(l.partial[ev.Window], piece...)
+}
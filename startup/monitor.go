@@ -0,0 +1,300 @@
+package startup
+
+/*
+startup/monitor.go contains the receiving side of startup notification, as
+used by panels (to show "Starting..." feedback), by launchers (to show a busy
+cursor) and by window managers (to place new windows and to decide whether
+they may take the focus).
+*/
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/icccm"
+	"github.com/BurntSushi/xgbutil/xevent"
+)
+
+// DefaultTimeout is the time after which a startup sequence that hasn't seen
+// any messages is considered to have failed, when NewMonitor is given a zero
+// timeout.
+const DefaultTimeout = 15 * time.Second
+
+// The kinds of events reported to a SequenceFun.
+const (
+	Initiated = iota // a "new" message was received
+	Changed          // a "change" message was received
+	Completed        // a "remove" message was received, or Monitor.Complete
+	TimedOut         // no message was received for the monitor's timeout
+)
+
+// Sequence is a startup sequence that is in progress. Values contains every
+// key (other than ID) seen in its "new" and "change" messages.
+type Sequence struct {
+	Id      string
+	Values  map[string]string
+	Started time.Time
+}
+
+// Name returns the name of the application being launched (NAME).
+func (seq *Sequence) Name() string {
+	return seq.Values["NAME"]
+}
+
+// Bin returns the name of the program being launched (BIN).
+func (seq *Sequence) Bin() string {
+	return seq.Values["BIN"]
+}
+
+// Icon returns the icon name or path of the application (ICON).
+func (seq *Sequence) Icon() string {
+	return seq.Values["ICON"]
+}
+
+// WmClass returns the WM_CLASS the launched application's windows are
+// expected to have (WMCLASS).
+func (seq *Sequence) WmClass() string {
+	return seq.Values["WMCLASS"]
+}
+
+// Screen returns the screen the application is launched on (SCREEN), or -1
+// if it isn't known.
+func (seq *Sequence) Screen() int {
+	return seq.number("SCREEN")
+}
+
+// Desktop returns the desktop the application is launched on (DESKTOP), or
+// -1 if it isn't known.
+func (seq *Sequence) Desktop() int {
+	return seq.number("DESKTOP")
+}
+
+// Timestamp returns the time of the user event that caused the launch. It is
+// read from TIMESTAMP, or from the sequence's ID if TIMESTAMP isn't set.
+func (seq *Sequence) Timestamp() xproto.Timestamp {
+	t, err := strconv.ParseUint(seq.Values["TIMESTAMP"], 10, 32)
+	if err == nil {
+		return xproto.Timestamp(t)
+	}
+	return IdTime(seq.Id)
+}
+
+// number returns the value of 'key' as a non-negative integer, or -1.
+func (seq *Sequence) number(key string) int {
+	n, err := strconv.Atoi(seq.Values[key])
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
+
+// SequenceFun is called with a startup sequence whenever something happens
+// to it. 'event' is one of Initiated, Changed, Completed or TimedOut.
+type SequenceFun func(xu *xgbutil.XUtil, seq *Sequence, event int)
+
+// sequence is a startup sequence and the timer that expires it.
+type sequence struct {
+	*Sequence
+	timer *xevent.Timer
+}
+
+// Monitor keeps track of the startup sequences in progress. Use NewMonitor
+// to create one.
+type Monitor struct {
+	X       *xgbutil.XUtil
+	Timeout time.Duration
+
+	fun      SequenceFun
+	lck      *sync.Mutex
+	seqs     map[string]*sequence
+	listener *Listener
+}
+
+// NewMonitor starts listening for startup notification messages (see
+// Listen), and calls 'fun' whenever a sequence is initiated, changed,
+// completed or times out. A sequence times out when no message about it has
+// been received for 'timeout'; if 'timeout' is zero, DefaultTimeout is used.
+//
+// 'fun' may be nil, and is always called from the main event loop (timeouts
+// are run with xevent.AfterFunc).
+func NewMonitor(xu *xgbutil.XUtil, timeout time.Duration,
+	fun SequenceFun) (*Monitor, error) {
+
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	m := &Monitor{
+		X:       xu,
+		Timeout: timeout,
+		fun:     fun,
+		lck:     &sync.Mutex{},
+		seqs:    make(map[string]*sequence),
+	}
+	listener, err := Listen(xu, m.message)
+	if err != nil {
+		return nil, err
+	}
+	m.listener = listener
+	return m, nil
+}
+
+// Detach stops listening for startup notification messages and forgets
+// every sequence in progress, without reporting them.
+func (m *Monitor) Detach() {
+	m.listener.Detach()
+
+	m.lck.Lock()
+	defer m.lck.Unlock()
+
+	for id, seq := range m.seqs {
+		seq.timer.Stop()
+		delete(m.seqs, id)
+	}
+}
+
+// Sequences returns the startup sequences in progress.
+func (m *Monitor) Sequences() []*Sequence {
+	m.lck.Lock()
+	defer m.lck.Unlock()
+
+	seqs := make([]*Sequence, 0, len(m.seqs))
+	for _, seq := range m.seqs {
+		seqs = append(seqs, seq.Sequence)
+	}
+	return seqs
+}
+
+// Sequence returns the startup sequence 'id', or nil if it isn't in
+// progress.
+func (m *Monitor) Sequence(id string) *Sequence {
+	m.lck.Lock()
+	defer m.lck.Unlock()
+
+	if seq, ok := m.seqs[id]; ok {
+		return seq.Sequence
+	}
+	return nil
+}
+
+// Match returns the startup sequence that the client window 'win' belongs
+// to, or nil if there isn't one.
+//
+// _NET_STARTUP_ID is looked up on 'win' and then on its group leader. If
+// neither has it, a sequence with a WMCLASS equal to the instance or class
+// of 'win' in WM_CLASS is returned.
+func (m *Monitor) Match(win xproto.Window) *Sequence {
+	id, err := ewmh.StartupIdGet(m.X, win)
+	if err != nil {
+		if hints, err := icccm.WmHintsGet(m.X, win); err == nil &&
+			hints.Flags&icccm.HintWindowGroup > 0 {
+
+			id, _ = ewmh.StartupIdGet(m.X, hints.WindowGroup)
+		}
+	}
+	if len(id) > 0 {
+		return m.Sequence(id)
+	}
+
+	class, err := icccm.WmClassGet(m.X, win)
+	if err != nil {
+		return nil
+	}
+
+	m.lck.Lock()
+	defer m.lck.Unlock()
+
+	for _, seq := range m.seqs {
+		wmclass := seq.WmClass()
+		if len(wmclass) > 0 &&
+			(wmclass == class.Instance || wmclass == class.Class) {
+
+			return seq.Sequence
+		}
+	}
+	return nil
+}
+
+// Complete ends the startup sequence 'id' without waiting for its "remove"
+// message. A window manager may use this once it has mapped a window that
+// belongs to the sequence, since not all applications send "remove"
+// messages. Nothing happens if 'id' isn't in progress.
+func (m *Monitor) Complete(id string) {
+	m.lck.Lock()
+	seq, ok := m.seqs[id]
+	if ok {
+		seq.timer.Stop()
+		delete(m.seqs, id)
+	}
+	m.lck.Unlock()
+
+	if ok {
+		m.report(seq, Completed)
+	}
+}
+
+// message updates the sequences in progress with a received message.
+func (m *Monitor) message(xu *xgbutil.XUtil, msg *Message) {
+	id := msg.Id()
+	if msg.Type == TypeRemove {
+		m.Complete(id)
+		return
+	}
+	if msg.Type != TypeNew && msg.Type != TypeChange {
+		return
+	}
+
+	m.lck.Lock()
+	seq, ok := m.seqs[id]
+	event := Changed
+	switch {
+	case !ok && msg.Type == TypeChange:
+		// A change for a sequence we never saw start.
+		m.lck.Unlock()
+		return
+	case !ok:
+		seq = &sequence{Sequence: &Sequence{
+			Id:      id,
+			Values:  make(map[string]string),
+			Started: time.Now(),
+		}}
+		m.seqs[id] = seq
+		event = Initiated
+	default:
+		seq.timer.Stop()
+	}
+	seq.timer = xevent.AfterFunc(xu, m.Timeout, func() { m.timeout(seq) })
+	for key, value := range msg.Values {
+		if key != "ID" {
+			seq.Values[key] = value
+		}
+	}
+	m.lck.Unlock()
+
+	m.report(seq, event)
+}
+
+// timeout removes 'seq' if it's still in progress, and reports that it timed
+// out.
+func (m *Monitor) timeout(seq *sequence) {
+	m.lck.Lock()
+	if m.seqs[seq.Id] != seq {
+		m.lck.Unlock()
+		return
+	}
+	delete(m.seqs, seq.Id)
+	m.lck.Unlock()
+
+	m.report(seq, TimedOut)
+}
+
+// report calls the SequenceFun, if there is one.
+func (m *Monitor) report(seq *sequence, event int) {
+	if m.fun != nil {
+		m.fun(m.X, seq.Sequence, event)
+	}
+}
@@ -0,0 +1,102 @@
+package startup
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+)
+
+// EnvVar is the environment variable a launcher uses to pass the ID of a
+// startup sequence to the application it launches.
+const EnvVar = "DESKTOP_STARTUP_ID"
+
+var (
+	idCount    = 0
+	idCountLck = &sync.Mutex{}
+)
+
+// NewId returns a new, unique startup sequence ID for the launcher 'name'.
+// The timestamp of the last event is embedded in the ID (as "_TIMEnnn"), so
+// that window managers can use it for focus stealing prevention. It should
+// therefore be called in response to the user event that caused the launch.
+func NewId(xu *xgbutil.XUtil, name string) string {
+	idCountLck.Lock()
+	idCount++
+	count := idCount
+	idCountLck.Unlock()
+
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s-%d_TIME%d", name, os.Getpid(), host, count,
+		xu.TimeGet())
+}
+
+// IdTime returns the timestamp embedded in a startup sequence ID, or 0 if
+// there isn't one.
+func IdTime(id string) xproto.Timestamp {
+	i := strings.LastIndex(id, "_TIME")
+	if i == -1 {
+		return 0
+	}
+	t, err := strconv.ParseUint(id[i+len("_TIME"):], 10, 32)
+	if err != nil {
+		return 0
+	}
+	return xproto.Timestamp(t)
+}
+
+// Initiate starts the startup sequence 'id' with a "new" message. 'values'
+// holds the other keys of the message, like "NAME", "BIN", "ICON", "SCREEN"
+// or "WMCLASS", and may be nil.
+//
+// The launcher should then pass 'id' to the application it launches in the
+// DESKTOP_STARTUP_ID environment variable (see EnvVar).
+func Initiate(xu *xgbutil.XUtil, id string, values map[string]string) error {
+	return Send(xu, newMessage(TypeNew, id, values))
+}
+
+// Change updates the keys in 'values' for the startup sequence 'id'.
+func Change(xu *xgbutil.XUtil, id string, values map[string]string) error {
+	return Send(xu, newMessage(TypeChange, id, values))
+}
+
+// Complete ends the startup sequence 'id'. It is usually called by the
+// launched application once its first window has been mapped, but may also
+// be called by the launcher if the application fails to start.
+func Complete(xu *xgbutil.XUtil, id string) error {
+	return Send(xu, newMessage(TypeRemove, id, nil))
+}
+
+// IdFromEnv returns the startup sequence ID passed to this application by
+// its launcher, and removes it from the environment so that it isn't
+// inherited by processes started by this application. An empty string is
+// returned if the application wasn't launched with startup notification.
+//
+// An application should set _NET_STARTUP_ID (see ewmh.StartupIdSet) on its
+// first top-level window to this ID, and call Complete when that window has
+// been mapped.
+func IdFromEnv() string {
+	id := os.Getenv(EnvVar)
+	os.Unsetenv(EnvVar)
+	return id
+}
+
+// newMessage creates a message of type 'typ' for the startup sequence 'id'
+// that also contains 'values'.
+func newMessage(typ, id string, values map[string]string) *Message {
+	msg := &Message{
+		Type:   typ,
+		Values: map[string]string{"ID": id},
+	}
+	for key, value := range values {
+		if key != "ID" {
+			msg.Values[key] = value
+		}
+	}
+	return msg
+}
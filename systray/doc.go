@@ -0,0 +1,63 @@
+/*
+Package systray implements both sides of the system tray protocol: a tray
+manager that hosts tray icons (usually part of a panel), and tray icons that
+dock into it.
+
+The protocol is described here:
+http://standards.freedesktop.org/systemtray-spec/systemtray-spec-latest.html
+
+In short, the tray manager owns the _NET_SYSTEM_TRAY_Sn selection (where n is
+the screen number). A tray icon is a small window that asks the owner of that
+selection to dock it with a SYSTEM_TRAY_REQUEST_DOCK message. The tray
+manager then embeds the icon using the XEmbed protocol (see the xembed
+package). Icons may also ask the tray manager to show "balloon" messages.
+
+Tray icons
+
+Any window can be docked. Its contents are usually painted with xgraphics:
+
+	win, _ := xwindow.Generate(X)
+	win.Create(X.RootWin(), 0, 0, 24, 24, 0)
+	win.Listen(xproto.EventMaskStructureNotify)
+
+	icon, err := systray.Dock(win)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	xevent.ConfigureNotifyFun(
+		func(X *xgbutil.XUtil, ev xevent.ConfigureNotifyEvent) {
+			// The tray manager decides the size of the icon.
+			ximg := xgraphics.NewConvert(X, resize(img, ev.Width, ev.Height))
+			ximg.XSurfaceSet(win.Id)
+			ximg.XDraw()
+			ximg.XPaint(win.Id)
+		}).Connect(X, win.Id)
+
+	icon.Message("Download complete", 5*time.Second)
+
+Icons stay docked across tray manager restarts: when a new tray manager
+starts, the icon docks with it.
+
+Tray managers
+
+A tray manager embeds icons into a window of its choosing, and leaves their
+layout to the Dock handler:
+
+	tray, err := systray.NewManager(X, panelWin, false, systray.Handlers{
+		Dock: func(m *systray.Manager, icon *systray.Icon) {
+			icon.MoveResize(nextX(), 0, 24, 24)
+		},
+		Undock: func(m *systray.Manager, icon *systray.Icon) {
+			relayout(m.Icons())
+		},
+		Message: func(m *systray.Manager, icon *systray.Icon,
+			msg systray.Balloon) {
+
+			showBalloon(icon, msg.Text, msg.Timeout)
+		},
+	})
+
+Both sides rely on xgbutil's main event loop (see xevent.Main).
+*/
+package systray
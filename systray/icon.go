@@ -0,0 +1,230 @@
+package systray
+
+/*
+systray/icon.go contains the tray icon side of the system tray protocol.
+Namely, asking the tray manager to dock a window, docking again whenever a
+new tray manager starts and sending balloon messages.
+*/
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xembed"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+var (
+	messageId    uint32 = 0
+	messageIdLck        = &sync.Mutex{}
+)
+
+// TrayIcon is a window that is docked (or waiting to be docked) in the
+// system tray. Use Dock to create one.
+type TrayIcon struct {
+	Win *xwindow.Window
+
+	client  *xembed.Client
+	manager xproto.Window
+
+	managerAtom, selAtom xproto.Atom
+
+	// callbacks attached with xevent.Attach to the root and manager windows
+	managerFun xevent.ClientMessageFun
+	destroyFun xevent.DestroyNotifyFun
+}
+
+// Dock asks the tray manager to embed 'win' as a tray icon. 'win' should be
+// an unmapped top-level window that uses the default visual (which is what
+// xgraphics paints to), and should be painted whenever it is resized by the
+// tray manager.
+//
+// If no tray manager is running, 'win' is docked as soon as one starts. The
+// same happens if the tray manager exits, so a TrayIcon stays in the tray
+// for as long as it exists. A TrayIcon relies on xgbutil's main event loop
+// (see xevent.Main).
+func Dock(win *xwindow.Window) (*TrayIcon, error) {
+	client, err := xembed.NewClient(win, true, xembed.ClientHandlers{})
+	if err != nil {
+		return nil, err
+	}
+	icon := &TrayIcon{Win: win, client: client}
+	icon.managerAtom, err = xprop.Atm(win.X, "MANAGER")
+	if err != nil {
+		return nil, err
+	}
+	icon.selAtom, err = xprop.Atm(win.X, SelectionName(win.X))
+	if err != nil {
+		return nil, err
+	}
+
+	// MANAGER messages are sent to the root window with StructureNotify.
+	root := xwindow.New(win.X, win.X.RootWin())
+	if err := root.ListenAdd(xproto.EventMaskStructureNotify); err != nil {
+		return nil, err
+	}
+	icon.managerFun = func(xu *xgbutil.XUtil, ev xevent.ClientMessageEvent) {
+		icon.managerStarted(ev)
+	}
+	icon.destroyFun = func(xu *xgbutil.XUtil, ev xevent.DestroyNotifyEvent) {
+		icon.managerExited()
+	}
+	xevent.Attach(win.X, xevent.ClientMessage, win.X.RootWin(),
+		&icon.managerFun)
+
+	manager, err := ManagerGet(win.X)
+	if err != nil {
+		return nil, err
+	}
+	if manager != 0 {
+		if err := icon.requestDock(manager); err != nil {
+			return nil, err
+		}
+	}
+	return icon, nil
+}
+
+// Manager returns the selection owner window of the tray manager that the
+// icon was last sent to, or 0 if no tray manager is running.
+func (icon *TrayIcon) Manager() xproto.Window {
+	return icon.manager
+}
+
+// Docked returns whether the icon has been embedded by a tray manager.
+func (icon *TrayIcon) Docked() bool {
+	return icon.client.Embedded()
+}
+
+// Show asks the tray manager to show the icon, if it was hidden with Hide.
+func (icon *TrayIcon) Show() error {
+	return icon.client.Show()
+}
+
+// Hide asks the tray manager to hide the icon, without undocking it.
+func (icon *TrayIcon) Hide() error {
+	return icon.client.Hide()
+}
+
+// Undock removes the icon from the tray, by unmapping it and reparenting it
+// to the root window. The icon won't be docked again.
+func (icon *TrayIcon) Undock() {
+	X := icon.Win.X
+	xevent.Disconnect(X, xevent.ClientMessage, X.RootWin(), &icon.managerFun)
+	icon.forgetManager()
+	icon.client.Stop()
+	icon.Win.Unmap()
+	xproto.ReparentWindow(icon.Win.X.Conn(), icon.Win.Id,
+		icon.Win.X.RootWin(), 0, 0)
+}
+
+// Message asks the tray manager to show 'text' in a balloon next to the icon
+// for 'timeout' (or until it is cancelled, if 'timeout' is 0). The returned
+// id can be given to CancelMessage.
+func (icon *TrayIcon) Message(text string,
+	timeout time.Duration) (uint32, error) {
+
+	if icon.manager == 0 {
+		return 0, fmt.Errorf("Message: No tray manager is running.")
+	}
+
+	messageIdLck.Lock()
+	messageId++
+	id := messageId
+	messageIdLck.Unlock()
+
+	X, win := icon.Win.X, icon.Win.Id
+	err := xevent.SendClientMessage(X, icon.manager, win,
+		"_NET_SYSTEM_TRAY_OPCODE", int(X.TimeGet()), BeginMessage,
+		int(timeout/time.Millisecond), len(text), int(id))
+	if err != nil {
+		return 0, err
+	}
+
+	dataAtom, err := xprop.Atm(X, "_NET_SYSTEM_TRAY_MESSAGE_DATA")
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(text); i += 20 {
+		piece := make([]byte, 20)
+		copy(piece, text[i:])
+
+		cm := xproto.ClientMessageEvent{
+			Format: 8,
+			Window: win,
+			Type:   dataAtom,
+			Data:   xproto.ClientMessageDataUnionData8New(piece),
+		}
+		err = xproto.SendEventChecked(X.Conn(), false, icon.manager,
+			xproto.EventMaskNoEvent, string(cm.Bytes())).Check()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+// CancelMessage asks the tray manager to remove the balloon message 'id'.
+func (icon *TrayIcon) CancelMessage(id uint32) error {
+	if icon.manager == 0 {
+		return fmt.Errorf("CancelMessage: No tray manager is running.")
+	}
+	return xevent.SendClientMessage(icon.Win.X, icon.manager, icon.Win.Id,
+		"_NET_SYSTEM_TRAY_OPCODE", int(icon.Win.X.TimeGet()), CancelMessage,
+		int(id))
+}
+
+// requestDock sends SYSTEM_TRAY_REQUEST_DOCK to the tray manager 'manager',
+// and watches 'manager' so that we know when it goes away.
+func (icon *TrayIcon) requestDock(manager xproto.Window) error {
+	icon.forgetManager()
+
+	mwin := xwindow.New(icon.Win.X, manager)
+	if err := mwin.Listen(xproto.EventMaskStructureNotify); err != nil {
+		// The manager has already gone away.
+		return nil
+	}
+	icon.manager = manager
+	xevent.Attach(icon.Win.X, xevent.DestroyNotify, manager, &icon.destroyFun)
+	return xevent.SendClientMessage(icon.Win.X, manager, manager,
+		"_NET_SYSTEM_TRAY_OPCODE", int(icon.Win.X.TimeGet()), RequestDock,
+		int(icon.Win.Id))
+}
+
+// forgetManager stops watching the current tray manager, if there is one.
+func (icon *TrayIcon) forgetManager() {
+	if icon.manager == 0 {
+		return
+	}
+	xevent.Disconnect(icon.Win.X, xevent.DestroyNotify, icon.manager,
+		&icon.destroyFun)
+	icon.manager = 0
+}
+
+// managerStarted docks the icon in a new tray manager, which announces
+// itself with a MANAGER message sent to the root window.
+func (icon *TrayIcon) managerStarted(ev xevent.ClientMessageEvent) {
+	if ev.Format != 32 || ev.Type != icon.managerAtom {
+		return
+	}
+	d := ev.Data.Data32
+	if xproto.Atom(d[1]) != icon.selAtom {
+		return
+	}
+	if err := icon.requestDock(xproto.Window(d[2])); err != nil {
+		xgbutil.Logger.Println(err)
+	}
+}
+
+// managerExited is called when the window of the current tray manager is
+// destroyed.
+func (icon *TrayIcon) managerExited() {
+	// The save-set of the old manager maps the icon on the root window.
+	icon.forgetManager()
+	icon.Win.Unmap()
+}
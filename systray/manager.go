@@ -0,0 +1,344 @@
+package systray
+
+/*
+systray/manager.go contains the tray manager side of the system tray
+protocol. That is, owning the _NET_SYSTEM_TRAY_Sn selection, embedding the
+icons that ask to be docked and receiving balloon messages from them.
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/icccm"
+	"github.com/BurntSushi/xgbutil/xembed"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// Balloon is a balloon message sent by a tray icon. Timeout is how long the
+// icon would like the message to be shown, or 0 for no timeout.
+type Balloon struct {
+	Id      uint32
+	Text    string
+	Timeout time.Duration
+}
+
+// maxMessageLength is the length of the longest balloon message accepted.
+// Longer messages are dropped, since the length comes from the icon.
+const maxMessageLength = 4096
+
+// balloon is a balloon message that is still being received.
+type balloon struct {
+	Balloon
+	length int
+	data   []byte
+}
+
+// Icon is a tray icon embedded by a Manager. Win is the icon's window, and
+// Socket is the window it is embedded in, which is a child of the manager's
+// parent window. Mapped is whether the icon wants to be visible.
+type Icon struct {
+	Win    *xwindow.Window
+	Socket *xwindow.Window
+	Mapped bool
+
+	embedder *xembed.Embedder
+	balloon  *balloon
+
+	// msgFun receives balloon messages on the icon's window.
+	msgFun xevent.ClientMessageFun
+}
+
+// MoveResize places the icon in the manager's parent window.
+func (icon *Icon) MoveResize(x, y, width, height int) {
+	icon.Socket.MoveResize(x, y, width, height)
+	icon.Win.MoveResize(0, 0, width, height)
+}
+
+// Handlers are the functions called by a Manager. Any of them may be nil.
+//
+// Dock is called when a new icon has been embedded, and Undock when an icon
+// has gone away (either by being destroyed or by being reparented elsewhere).
+// Dock should put the icon in its place in the tray with Icon.MoveResize. Map
+// is called when an icon is mapped or unmapped because it changed its
+// _XEMBED_INFO.
+//
+// Message is called when an icon has sent a complete balloon message, and
+// Cancel when an icon cancels one of its messages.
+//
+// Replaced is called when another tray manager takes over. All icons have
+// been undocked by then.
+type Handlers struct {
+	Dock     func(m *Manager, icon *Icon)
+	Undock   func(m *Manager, icon *Icon)
+	Map      func(m *Manager, icon *Icon)
+	Message  func(m *Manager, icon *Icon, msg Balloon)
+	Cancel   func(m *Manager, icon *Icon, id uint32)
+	Replaced func(m *Manager)
+}
+
+// Manager is a running tray manager. Use NewManager to create one.
+type Manager struct {
+	X         *xgbutil.XUtil
+	Parent    *xwindow.Window
+	Selection *icccm.ManagerSelection
+
+	handlers Handlers
+	icons    map[xproto.Window]*Icon
+
+	opcodeAtom, dataAtom xproto.Atom
+}
+
+// NewManager acquires the system tray selection and starts accepting tray
+// icons, which are embedded into 'parent' (usually a panel's window).
+// The tray orientation is set to OrientationHorz; use OrientationSet to
+// change it.
+//
+// If another tray manager is running, an error is returned unless 'replace'
+// is true. See icccm.AcquireManagerSelection for the details.
+//
+// A Manager relies on xgbutil's main event loop (see xevent.Main).
+func NewManager(xu *xgbutil.XUtil, parent *xwindow.Window, replace bool,
+	handlers Handlers) (*Manager, error) {
+
+	m := &Manager{
+		X:        xu,
+		Parent:   parent,
+		handlers: handlers,
+		icons:    make(map[xproto.Window]*Icon),
+	}
+
+	var err error
+	m.opcodeAtom, err = xprop.Atm(xu, "_NET_SYSTEM_TRAY_OPCODE")
+	if err != nil {
+		return nil, err
+	}
+	m.dataAtom, err = xprop.Atm(xu, "_NET_SYSTEM_TRAY_MESSAGE_DATA")
+	if err != nil {
+		return nil, err
+	}
+
+	m.Selection, err = icccm.AcquireManagerSelection(xu, SelectionName(xu),
+		replace, 3*time.Second,
+		func(ms *icccm.ManagerSelection) {
+			m.undockAll()
+			if m.handlers.Replaced != nil {
+				m.handlers.Replaced(m)
+			}
+		})
+	if m.Selection == nil {
+		return nil, err
+	}
+	if err != nil {
+		xgbutil.Logger.Printf("NewManager: %s", err)
+	}
+
+	win := m.Selection.Win
+	if err := OrientationSet(xu, win, OrientationHorz); err != nil {
+		m.Selection.Release()
+		return nil, err
+	}
+	if err := VisualSet(xu, win, xu.Screen().RootVisual); err != nil {
+		m.Selection.Release()
+		return nil, err
+	}
+
+	// Dock requests are sent to the selection window, and balloon messages
+	// to each icon's window (see dock).
+	xevent.ClientMessageFun(
+		func(xu *xgbutil.XUtil, ev xevent.ClientMessageEvent) {
+			m.dockRequest(ev)
+		}).Connect(xu, win)
+	return m, nil
+}
+
+// OrientationSet sets the orientation of the tray, which tells icons how
+// they're going to be laid out. It should be OrientationHorz or
+// OrientationVert.
+func (m *Manager) OrientationSet(orientation uint) error {
+	return OrientationSet(m.X, m.Selection.Win, orientation)
+}
+
+// Icons returns every docked icon.
+func (m *Manager) Icons() []*Icon {
+	icons := make([]*Icon, 0, len(m.icons))
+	for _, icon := range m.icons {
+		icons = append(icons, icon)
+	}
+	return icons
+}
+
+// Destroy undocks every icon (by unmapping it and reparenting it back to the
+// root window) and gives up the system tray selection. Icons will then dock
+// with the next tray manager that starts.
+func (m *Manager) Destroy() {
+	m.undockAll()
+	m.Selection.Release()
+}
+
+// dockRequest handles a SYSTEM_TRAY_REQUEST_DOCK message sent to the
+// selection window.
+func (m *Manager) dockRequest(ev xevent.ClientMessageEvent) {
+	if ev.Format != 32 || ev.Type != m.opcodeAtom {
+		return
+	}
+	d := ev.Data.Data32
+	if d[1] != RequestDock {
+		return
+	}
+	if err := m.dock(xproto.Window(d[2])); err != nil {
+		xgbutil.Logger.Println(err)
+	}
+}
+
+// iconMessage handles the _NET_SYSTEM_TRAY_OPCODE and
+// _NET_SYSTEM_TRAY_MESSAGE_DATA messages about balloon messages, which are
+// sent with the window of the icon.
+func (m *Manager) iconMessage(icon *Icon, ev xevent.ClientMessageEvent) {
+	switch {
+	case ev.Type == m.opcodeAtom && ev.Format == 32:
+		d := ev.Data.Data32
+		switch d[1] {
+		case BeginMessage:
+			m.beginMessage(icon, d[2], d[3], d[4])
+		case CancelMessage:
+			if icon.balloon != nil && icon.balloon.Id == d[2] {
+				icon.balloon = nil
+			}
+			if m.handlers.Cancel != nil {
+				m.handlers.Cancel(m, icon, d[2])
+			}
+		}
+	case ev.Type == m.dataAtom && ev.Format == 8:
+		m.messageData(icon, ev.Data.Data8)
+	}
+}
+
+// dock embeds the icon window 'win' into a new socket in the manager's
+// parent window.
+func (m *Manager) dock(win xproto.Window) error {
+	if _, ok := m.icons[win]; ok {
+		return nil
+	}
+
+	socket, err := xwindow.Generate(m.X)
+	if err != nil {
+		return err
+	}
+	err = socket.CreateChecked(m.Parent.Id, 0, 0, 1, 1,
+		xproto.CwBackPixmap, xproto.BackPixmapParentRelative)
+	if err != nil {
+		return err
+	}
+
+	icon := &Icon{Win: xwindow.New(m.X, win), Socket: socket}
+	icon.embedder, err = xembed.Embed(socket, win, xembed.EmbedderHandlers{
+		Map: func(e *xembed.Embedder, mapped bool) {
+			icon.Mapped = mapped
+			if mapped {
+				icon.Socket.Map()
+			} else {
+				icon.Socket.Unmap()
+			}
+			if m.handlers.Map != nil {
+				m.handlers.Map(m, icon)
+			}
+		},
+		Gone: func(e *xembed.Embedder) {
+			m.forget(icon)
+		},
+	})
+	if err != nil {
+		socket.Destroy()
+		return fmt.Errorf("dock: Could not dock icon %x: %s", win, err)
+	}
+	icon.Mapped = icon.embedder.Mapped
+	icon.msgFun = func(xu *xgbutil.XUtil, ev xevent.ClientMessageEvent) {
+		m.iconMessage(icon, ev)
+	}
+	xevent.Attach(m.X, xevent.ClientMessage, win, &icon.msgFun)
+
+	m.icons[win] = icon
+	if m.handlers.Dock != nil {
+		m.handlers.Dock(m, icon)
+	}
+	if icon.Mapped {
+		icon.Socket.Map()
+	}
+	return nil
+}
+
+// forget removes 'icon' from the tray after it has gone away.
+func (m *Manager) forget(icon *Icon) {
+	if _, ok := m.icons[icon.Win.Id]; !ok {
+		return
+	}
+	xevent.Disconnect(m.X, xevent.ClientMessage, icon.Win.Id, &icon.msgFun)
+	delete(m.icons, icon.Win.Id)
+	icon.Socket.Destroy()
+	if m.handlers.Undock != nil {
+		m.handlers.Undock(m, icon)
+	}
+}
+
+// undockAll gives every icon back to the root window.
+func (m *Manager) undockAll() {
+	for _, icon := range m.icons {
+		icon.embedder.Unembed()
+		xevent.Disconnect(m.X, xevent.ClientMessage, icon.Win.Id,
+			&icon.msgFun)
+		delete(m.icons, icon.Win.Id)
+		icon.Socket.Destroy()
+		if m.handlers.Undock != nil {
+			m.handlers.Undock(m, icon)
+		}
+	}
+}
+
+// beginMessage starts receiving a balloon message from 'icon'.
+// Messages longer than maxMessageLength are dropped.
+func (m *Manager) beginMessage(icon *Icon, timeout, length, id uint32) {
+	if length > maxMessageLength {
+		icon.balloon = nil
+		return
+	}
+	icon.balloon = &balloon{
+		Balloon: Balloon{
+			Id:      id,
+			Timeout: time.Duration(timeout) * time.Millisecond,
+		},
+		length: int(length),
+		data:   make([]byte, 0, length),
+	}
+	if length == 0 {
+		m.messageData(icon, nil)
+	}
+}
+
+// messageData adds a piece of a balloon message from 'icon'. Once the
+// message is complete, the Message handler is called.
+func (m *Manager) messageData(icon *Icon, piece []byte) {
+	b := icon.balloon
+	if b == nil {
+		return
+	}
+	if need := b.length - len(b.data); len(piece) > need {
+		piece = piece[:need]
+	}
+	b.data = append(b.data, piece...)
+	if len(b.data) < b.length {
+		return
+	}
+
+	icon.balloon = nil
+	b.Text = string(bytes.TrimRight(b.data, "\x00"))
+	if m.handlers.Message != nil {
+		m.handlers.Message(m, icon, b.Balloon)
+	}
+}
@@ -0,0 +1,66 @@
+package systray
+
+import (
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/icccm"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// Opcodes of _NET_SYSTEM_TRAY_OPCODE messages, sent by tray icons to the tray
+// manager.
+const (
+	RequestDock = iota
+	BeginMessage
+	CancelMessage
+)
+
+// Values of _NET_SYSTEM_TRAY_ORIENTATION.
+const (
+	OrientationHorz = iota
+	OrientationVert
+)
+
+// SelectionName returns the name of the system tray selection on the default
+// screen. e.g., "_NET_SYSTEM_TRAY_S0".
+func SelectionName(xu *xgbutil.XUtil) string {
+	return icccm.ManagerSelectionName(xu, "_NET_SYSTEM_TRAY_S")
+}
+
+// ManagerGet returns the selection owner window of the tray manager on the
+// default screen, or 0 if there is no tray manager running.
+func ManagerGet(xu *xgbutil.XUtil) (xproto.Window, error) {
+	return icccm.ManagerSelectionOwner(xu, SelectionName(xu))
+}
+
+// _NET_SYSTEM_TRAY_ORIENTATION get
+func OrientationGet(xu *xgbutil.XUtil, manager xproto.Window) (uint, error) {
+	return xprop.PropValNum(xprop.GetProperty(xu, manager,
+		"_NET_SYSTEM_TRAY_ORIENTATION"))
+}
+
+// _NET_SYSTEM_TRAY_ORIENTATION set
+func OrientationSet(xu *xgbutil.XUtil, manager xproto.Window,
+	orientation uint) error {
+
+	return xprop.ChangeProp32(xu, manager, "_NET_SYSTEM_TRAY_ORIENTATION",
+		"CARDINAL", orientation)
+}
+
+// _NET_SYSTEM_TRAY_VISUAL get
+func VisualGet(xu *xgbutil.XUtil,
+	manager xproto.Window) (xproto.Visualid, error) {
+
+	visual, err := xprop.PropValNum(xprop.GetProperty(xu, manager,
+		"_NET_SYSTEM_TRAY_VISUAL"))
+	return xproto.Visualid(visual), err
+}
+
+// _NET_SYSTEM_TRAY_VISUAL set
+func VisualSet(xu *xgbutil.XUtil, manager xproto.Window,
+	visual xproto.Visualid) error {
+
+	return xprop.ChangeProp32(xu, manager, "_NET_SYSTEM_TRAY_VISUAL",
+		"VISUALID", uint(visual))
+}
@@ -0,0 +1,34 @@
+/*
+Package urgency keeps track of which windows want the user's attention.
+
+There are two ways for a client to say that one of its windows is urgent:
+setting the urgency flag in WM_HINTS (from the ICCCM), or adding
+_NET_WM_STATE_DEMANDS_ATTENTION to _NET_WM_STATE (from the EWMH). Clients use
+either or both, so a window is considered urgent if either is set.
+
+A Tracker watches both properties on every window it tracks, and calls a
+function whenever a window becomes urgent or stops being urgent:
+
+	tracker := urgency.New(XUtilValue,
+		func(X *xgbutil.XUtil, win xproto.Window, urgent bool) {
+			if urgent {
+				// Flash the window's task bar entry.
+			} else {
+				// Stop flashing.
+			}
+		})
+	tracker.ClearOnFocus = true // for window managers
+	tracker.Track(client)
+
+Setting ClearOnFocus makes the tracker clear both properties whenever a
+tracked window gets the input focus, since the user's attention has then been
+obtained. Window managers can also call Clear directly when a client is
+activated.
+
+Windows are untracked when they are destroyed, so a Tracker can be given every
+client without having to call Untrack for each of them.
+
+Note that a Tracker relies on the main event loop (xevent.Main) to see
+property changes, and is not safe for concurrent use.
+*/
+package urgency
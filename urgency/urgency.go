@@ -0,0 +1,264 @@
+package urgency
+
+import (
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/icccm"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// UrgencyFun is called whenever a tracked window becomes urgent ('urgent'
+// is true) or stops being urgent ('urgent' is false).
+type UrgencyFun func(xu *xgbutil.XUtil, win xproto.Window, urgent bool)
+
+// status is the urgency of a single window, from each source.
+type status struct {
+	hint      bool // the urgency flag in WM_HINTS
+	attention bool // _NET_WM_STATE_DEMANDS_ATTENTION in _NET_WM_STATE
+}
+
+func (s status) urgent() bool {
+	return s.hint || s.attention
+}
+
+// Tracker keeps track of the urgency of a set of windows. A window is urgent
+// if either the urgency flag is set in its WM_HINTS or
+// _NET_WM_STATE_DEMANDS_ATTENTION is in its _NET_WM_STATE.
+//
+// If ClearOnFocus is true, the urgency of a tracked window is cleared (with
+// Clear) whenever it receives focus. This is what window managers usually
+// want. It must be set before the window is tracked.
+//
+// The zero value is not usable; use New to create a Tracker.
+type Tracker struct {
+	X            *xgbutil.XUtil
+	ClearOnFocus bool
+
+	fun  UrgencyFun
+	wins map[xproto.Window]*tracked
+
+	hintsAtom, stateAtom xproto.Atom
+}
+
+// tracked is a tracked window, along with its callbacks attached with
+// xevent.Attach.
+type tracked struct {
+	status     status
+	propFun    xevent.PropertyNotifyFun
+	focusFun   xevent.FocusInFun
+	destroyFun xevent.DestroyNotifyFun
+}
+
+// New creates a Tracker that calls 'fun' whenever the urgency of a tracked
+// window changes. No windows are tracked until Track is called.
+func New(xu *xgbutil.XUtil, fun UrgencyFun) *Tracker {
+	t := &Tracker{
+		X:    xu,
+		fun:  fun,
+		wins: make(map[xproto.Window]*tracked),
+	}
+	return t
+}
+
+// Track starts tracking the urgency of 'win'. PropertyChange and
+// StructureNotify events (and FocusChange events if ClearOnFocus is set) are
+// selected on 'win', in addition to whatever events are already selected.
+// If 'win' is already urgent, the UrgencyFun is called immediately.
+// Tracking a window that is already tracked does nothing.
+//
+// 'win' is untracked automatically when it is destroyed. If it was urgent,
+// the UrgencyFun is called with 'urgent' set to false.
+func (t *Tracker) Track(win xproto.Window) error {
+	if _, ok := t.wins[win]; ok {
+		return nil
+	}
+	if err := t.atoms(); err != nil {
+		return err
+	}
+
+	mask := xproto.EventMaskPropertyChange | xproto.EventMaskStructureNotify
+	if t.ClearOnFocus {
+		mask |= xproto.EventMaskFocusChange
+	}
+	if err := xwindow.New(t.X, win).ListenAdd(mask); err != nil {
+		return err
+	}
+
+	tr := &tracked{}
+	tr.propFun = func(xu *xgbutil.XUtil, ev xevent.PropertyNotifyEvent) {
+		t.property(ev)
+	}
+	xevent.Attach(t.X, xevent.PropertyNotify, win, &tr.propFun)
+	if t.ClearOnFocus {
+		tr.focusFun = func(xu *xgbutil.XUtil, ev xevent.FocusInEvent) {
+			t.focusIn(ev)
+		}
+		xevent.Attach(t.X, xevent.FocusIn, win, &tr.focusFun)
+	}
+	tr.destroyFun = func(xu *xgbutil.XUtil, ev xevent.DestroyNotifyEvent) {
+		t.destroyed(ev.Window)
+	}
+	xevent.Attach(t.X, xevent.DestroyNotify, win, &tr.destroyFun)
+	t.wins[win] = tr
+
+	t.update(win, status{hint: hintUrgent(t.X, win),
+		attention: demandsAttention(t.X, win)})
+	return nil
+}
+
+// Untrack stops tracking the urgency of 'win', and removes the callbacks
+// that Track attached to it. The UrgencyFun isn't called, even if 'win' was
+// urgent.
+func (t *Tracker) Untrack(win xproto.Window) {
+	tr, ok := t.wins[win]
+	if !ok {
+		return
+	}
+	xevent.Disconnect(t.X, xevent.PropertyNotify, win, &tr.propFun)
+	xevent.Disconnect(t.X, xevent.FocusIn, win, &tr.focusFun)
+	xevent.Disconnect(t.X, xevent.DestroyNotify, win, &tr.destroyFun)
+	delete(t.wins, win)
+}
+
+// Urgent returns whether 'win' is urgent. It always returns false for windows
+// that aren't tracked.
+func (t *Tracker) Urgent(win xproto.Window) bool {
+	tr, ok := t.wins[win]
+	return ok && tr.status.urgent()
+}
+
+// UrgentWindows returns all tracked windows that are urgent.
+func (t *Tracker) UrgentWindows() []xproto.Window {
+	wins := make([]xproto.Window, 0)
+	for win, tr := range t.wins {
+		if tr.status.urgent() {
+			wins = append(wins, win)
+		}
+	}
+	return wins
+}
+
+// Clear clears the urgency of 'win' by removing the urgency flag from its
+// WM_HINTS and removing _NET_WM_STATE_DEMANDS_ATTENTION from its
+// _NET_WM_STATE. Properties are only written if they need to change.
+// The UrgencyFun is called when the resulting PropertyNotify events arrive.
+func (t *Tracker) Clear(win xproto.Window) error {
+	hints, err := icccm.WmHintsGet(t.X, win)
+	if err == nil && hints.Flags&icccm.HintUrgency > 0 {
+		hints.Flags &^= icccm.HintUrgency
+		if err := icccm.WmHintsSet(t.X, win, hints); err != nil {
+			return err
+		}
+	}
+
+	states, err := ewmh.WmStateGet(t.X, win)
+	if err != nil {
+		return nil
+	}
+	kept := make([]string, 0, len(states))
+	for _, state := range states {
+		if state != "_NET_WM_STATE_DEMANDS_ATTENTION" {
+			kept = append(kept, state)
+		}
+	}
+	if len(kept) == len(states) {
+		return nil
+	}
+	return ewmh.WmStateSet(t.X, win, kept)
+}
+
+// atoms looks up the atoms of the properties that affect urgency, the first
+// time a window is tracked.
+func (t *Tracker) atoms() error {
+	if t.hintsAtom != 0 {
+		return nil
+	}
+	stateAtom, err := xprop.Atm(t.X, "_NET_WM_STATE")
+	if err != nil {
+		return err
+	}
+	hintsAtom, err := xprop.Atm(t.X, "WM_HINTS")
+	if err != nil {
+		return err
+	}
+	t.hintsAtom, t.stateAtom = hintsAtom, stateAtom
+	return nil
+}
+
+// property updates a tracked window when WM_HINTS or _NET_WM_STATE changes.
+func (t *Tracker) property(ev xevent.PropertyNotifyEvent) {
+	tr, ok := t.wins[ev.Window]
+	if !ok {
+		return
+	}
+	s := tr.status
+	switch ev.Atom {
+	case t.hintsAtom:
+		s.hint = hintUrgent(t.X, ev.Window)
+	case t.stateAtom:
+		s.attention = demandsAttention(t.X, ev.Window)
+	default:
+		return
+	}
+	t.update(ev.Window, s)
+}
+
+// destroyed forgets a tracked window that has been destroyed.
+func (t *Tracker) destroyed(win xproto.Window) {
+	tr, ok := t.wins[win]
+	if !ok {
+		return
+	}
+	urgent := tr.status.urgent()
+	t.Untrack(win)
+	if urgent && t.fun != nil {
+		t.fun(t.X, win, false)
+	}
+}
+
+// focusIn clears the urgency of a tracked window when it receives focus.
+func (t *Tracker) focusIn(ev xevent.FocusInEvent) {
+	if !t.Urgent(ev.Event) {
+		return
+	}
+	if ev.Mode == xproto.NotifyModeGrab ||
+		ev.Detail == xproto.NotifyDetailPointer {
+		return
+	}
+	if err := t.Clear(ev.Event); err != nil {
+		xgbutil.Logger.Println(err)
+	}
+}
+
+// update records the new status of 'win' and calls the UrgencyFun if its
+// urgency changed.
+func (t *Tracker) update(win xproto.Window, s status) {
+	tr := t.wins[win]
+	old := tr.status
+	tr.status = s
+	if old.urgent() != s.urgent() && t.fun != nil {
+		t.fun(t.X, win, s.urgent())
+	}
+}
+
+// hintUrgent returns whether the urgency flag is set in WM_HINTS.
+func hintUrgent(xu *xgbutil.XUtil, win xproto.Window) bool {
+	hints, err := icccm.WmHintsGet(xu, win)
+	return err == nil && hints.Flags&icccm.HintUrgency > 0
+}
+
+// demandsAttention returns whether _NET_WM_STATE contains
+// _NET_WM_STATE_DEMANDS_ATTENTION.
+func demandsAttention(xu *xgbutil.XUtil, win xproto.Window) bool {
+	states, _ := ewmh.WmStateGet(xu, win)
+	for _, state := range states {
+		if state == "_NET_WM_STATE_DEMANDS_ATTENTION" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+/*
+Package wmgroup keeps track of which client windows belong together, which
+is something every window manager needs to know to stack, focus, iconify and
+close windows sensibly. There are two kinds of relationships:
+
+Transients. A window whose WM_TRANSIENT_FOR property is set to another window
+(a dialog, for example) is a transient for that window. It should be stacked
+above it, and usually iconified along with it.
+
+Window groups. Windows whose WM_HINTS contain the same WindowGroup belong to
+the same group, which is usually a single application. If WindowGroup isn't
+set, WM_CLIENT_LEADER is used instead.
+
+Following the EWMH, a window whose WM_TRANSIENT_FOR is the root window (or
+None), and a dialog (_NET_WM_WINDOW_TYPE_DIALOG) without a WM_TRANSIENT_FOR,
+is transient for every window in its group that isn't a transient itself.
+
+Usage
+
+A Forest is built up by calling Update for each client window as it is
+managed, and Remove when it is unmanaged. The properties involved can change
+at any time, so PropertyNotify should be called from the window manager's
+PropertyNotify handlers:
+
+	forest := wmgroup.New(XUtilValue)
+	if err := forest.Update(client); err != nil {
+		// WM_TRANSIENT_FOR would have created a cycle and was ignored.
+		log.Println(err)
+	}
+
+	// When 'client' is activated, focus its top-most transient instead.
+	focus := forest.TopTransient(client)
+
+	// Iconify the whole application.
+	for _, win := range forest.GroupMembers(client) {
+		...
+	}
+
+A Forest is not safe for concurrent use.
+*/
+package wmgroup
@@ -0,0 +1,331 @@
+package wmgroup
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/icccm"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// node is everything the forest knows about a single window.
+type node struct {
+	win xproto.Window
+
+	// transientFor is the window in WM_TRANSIENT_FOR, or 0 if there is none
+	// (or if it was ignored because it would create a cycle).
+	transientFor xproto.Window
+
+	// groupTransient is true when the window is transient for its whole
+	// group rather than a single window. This is the case when
+	// WM_TRANSIENT_FOR is the root window, or when a dialog has no
+	// WM_TRANSIENT_FOR at all.
+	groupTransient bool
+
+	// group is the window group the window belongs to.
+	group xproto.Window
+
+	// order is used to find the most recently updated transient.
+	order uint64
+}
+
+// Forest is the forest of transient relationships and window groups of a set
+// of client windows. Windows are added and updated with Update and removed
+// with Remove. Windows that are referred to (e.g., in WM_TRANSIENT_FOR) but
+// haven't been added are ignored in the results of every method.
+type Forest struct {
+	X     *xgbutil.XUtil
+	nodes map[xproto.Window]*node
+	order uint64
+}
+
+// New returns an empty Forest.
+func New(xu *xgbutil.XUtil) *Forest {
+	return &Forest{
+		X:     xu,
+		nodes: make(map[xproto.Window]*node),
+	}
+}
+
+// Update adds 'win' to the forest, or refreshes what the forest knows about
+// it, by reading its WM_TRANSIENT_FOR, WM_HINTS, WM_CLIENT_LEADER and
+// _NET_WM_WINDOW_TYPE properties.
+//
+// If WM_TRANSIENT_FOR would create a cycle (e.g., a window that is transient
+// for one of its own transients), it is ignored and an error is returned.
+// The window is still added to the forest in that case.
+func (f *Forest) Update(win xproto.Window) error {
+	n, ok := f.nodes[win]
+	if !ok {
+		n = &node{win: win}
+		f.nodes[win] = n
+	}
+	f.order++
+	n.order = f.order
+
+	// The group is WM_HINTS.WindowGroup, falling back to WM_CLIENT_LEADER.
+	// A window without either is in a group by itself.
+	n.group = win
+	if hints, err := icccm.WmHintsGet(f.X, win); err == nil &&
+		hints.Flags&icccm.HintWindowGroup > 0 && hints.WindowGroup != 0 {
+
+		n.group = hints.WindowGroup
+	} else if leader, err := icccm.WmClientLeaderGet(f.X, win); err == nil &&
+		leader != 0 {
+
+		n.group = leader
+	}
+
+	n.transientFor, n.groupTransient = 0, false
+	trans, err := icccm.WmTransientForGet(f.X, win)
+	switch {
+	case err == nil && (trans == f.X.RootWin() || trans == 0):
+		n.groupTransient = true
+	case err == nil:
+		if f.isAncestor(win, trans) {
+			return fmt.Errorf("Update: Ignoring WM_TRANSIENT_FOR of window "+
+				"%x since it would create a cycle with window %x.", win, trans)
+		}
+		n.transientFor = trans
+	default:
+		n.groupTransient = isDialog(f.X, win)
+	}
+
+	// A window can't be transient for a group it leads by itself.
+	if n.groupTransient && n.group == win {
+		n.groupTransient = false
+	}
+	return nil
+}
+
+// Remove removes 'win' from the forest. Its transients become transient for
+// nothing until 'win' is added again.
+func (f *Forest) Remove(win xproto.Window) {
+	delete(f.nodes, win)
+}
+
+// Has returns whether 'win' has been added to the forest.
+func (f *Forest) Has(win xproto.Window) bool {
+	_, ok := f.nodes[win]
+	return ok
+}
+
+// PropertyNotify updates the forest if 'ev' is a change to one of the
+// properties that the forest depends on, and reports whether it was. It
+// should be called by a window manager's PropertyNotify handler for each
+// client window in the forest.
+func (f *Forest) PropertyNotify(ev xevent.PropertyNotifyEvent) (bool, error) {
+	if !f.Has(ev.Window) {
+		return false, nil
+	}
+	name, err := xprop.AtomName(f.X, ev.Atom)
+	if err != nil {
+		return false, err
+	}
+	switch name {
+	case "WM_TRANSIENT_FOR", "WM_HINTS", "WM_CLIENT_LEADER",
+		"_NET_WM_WINDOW_TYPE":
+		return true, f.Update(ev.Window)
+	}
+	return false, nil
+}
+
+// Group returns the window group of 'win', i.e., the group leader. This is
+// 'win' itself if it doesn't belong to a group. 0 is returned if 'win' isn't
+// in the forest.
+func (f *Forest) Group(win xproto.Window) xproto.Window {
+	if n, ok := f.nodes[win]; ok {
+		return n.group
+	}
+	return 0
+}
+
+// GroupMembers returns all windows in the same group as 'win', including
+// 'win' itself.
+func (f *Forest) GroupMembers(win xproto.Window) []xproto.Window {
+	n, ok := f.nodes[win]
+	if !ok {
+		return nil
+	}
+
+	wins := make([]xproto.Window, 0)
+	for _, other := range f.nodes {
+		if other.group == n.group {
+			wins = append(wins, other.win)
+		}
+	}
+	return wins
+}
+
+// TransientFor returns the windows that 'win' is transient for. This is
+// either the single window in its WM_TRANSIENT_FOR, or, for group transients,
+// every window in its group that isn't itself a transient of any kind.
+func (f *Forest) TransientFor(win xproto.Window) []xproto.Window {
+	n, ok := f.nodes[win]
+	if !ok {
+		return nil
+	}
+	if n.transientFor != 0 {
+		if _, ok := f.nodes[n.transientFor]; ok {
+			return []xproto.Window{n.transientFor}
+		}
+		return nil
+	}
+	if !n.groupTransient {
+		return nil
+	}
+
+	wins := make([]xproto.Window, 0)
+	for _, other := range f.nodes {
+		if other.group == n.group && isPlain(other) {
+			wins = append(wins, other.win)
+		}
+	}
+	return wins
+}
+
+// IsTransient returns whether 'win' is transient for some other window in
+// the forest.
+func (f *Forest) IsTransient(win xproto.Window) bool {
+	n, ok := f.nodes[win]
+	return ok && f.isTransient(n)
+}
+
+// Transients returns the windows that are directly transient for 'win'.
+func (f *Forest) Transients(win xproto.Window) []xproto.Window {
+	n, ok := f.nodes[win]
+	if !ok {
+		return nil
+	}
+
+	wins := make([]xproto.Window, 0)
+	for _, other := range f.nodes {
+		if f.transientOf(other, n) {
+			wins = append(wins, other.win)
+		}
+	}
+	return wins
+}
+
+// TopTransient returns the transient of 'win' that should be on top of it,
+// and is therefore usually the window that should get focus when 'win' is
+// activated. Namely, starting from 'win', the most recently updated
+// transient is followed until a window without transients is found.
+// 'win' itself is returned if it has no transients.
+func (f *Forest) TopTransient(win xproto.Window) xproto.Window {
+	n, ok := f.nodes[win]
+	if !ok {
+		return win
+	}
+
+	seen := map[*node]bool{n: true}
+	for {
+		var top *node
+		for _, other := range f.nodes {
+			if !seen[other] && f.transientOf(other, n) &&
+				(top == nil || other.order > top.order) {
+
+				top = other
+			}
+		}
+		if top == nil {
+			return n.win
+		}
+		seen[top] = true
+		n = top
+	}
+}
+
+// Root returns the window at the root of the transient tree containing
+// 'win'. This is 'win' itself if it isn't a transient. Group transients stop
+// at their group leader if it's in the forest, and at themselves otherwise.
+func (f *Forest) Root(win xproto.Window) xproto.Window {
+	n, ok := f.nodes[win]
+	if !ok {
+		return win
+	}
+	for n.transientFor != 0 {
+		parent, ok := f.nodes[n.transientFor]
+		if !ok {
+			break
+		}
+		n = parent
+	}
+	if n.groupTransient {
+		if leader, ok := f.nodes[n.group]; ok && isPlain(leader) {
+			return leader.win
+		}
+	}
+	return n.win
+}
+
+// isTransient returns whether 'n' is transient for a window in the forest.
+func (f *Forest) isTransient(n *node) bool {
+	if n.transientFor != 0 {
+		_, ok := f.nodes[n.transientFor]
+		return ok
+	}
+	if !n.groupTransient {
+		return false
+	}
+	for _, other := range f.nodes {
+		if other != n && other.group == n.group && isPlain(other) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPlain returns whether 'n' is neither transient for a single window nor
+// for its group. Group transients are transient for the plain windows in
+// their group.
+func isPlain(n *node) bool {
+	return n.transientFor == 0 && !n.groupTransient
+}
+
+// transientOf returns whether 'n' is directly transient for 'parent'.
+func (f *Forest) transientOf(n, parent *node) bool {
+	if n == parent {
+		return false
+	}
+	if n.transientFor != 0 {
+		return n.transientFor == parent.win
+	}
+	return n.groupTransient && n.group == parent.group && isPlain(parent)
+}
+
+// isAncestor returns whether making 'win' transient for 'trans' would create
+// a cycle. That is, whether 'win' is 'trans' or is reached by following
+// WM_TRANSIENT_FOR from 'trans'. Group transients can't create cycles, since
+// they are only ever transient for windows that aren't transients.
+func (f *Forest) isAncestor(win, trans xproto.Window) bool {
+	seen := make(map[xproto.Window]bool)
+	for trans != 0 && !seen[trans] {
+		if trans == win {
+			return true
+		}
+		seen[trans] = true
+
+		n, ok := f.nodes[trans]
+		if !ok {
+			return false
+		}
+		trans = n.transientFor
+	}
+	return false
+}
+
+// isDialog returns whether 'win' has the _NET_WM_WINDOW_TYPE_DIALOG type.
+func isDialog(xu *xgbutil.XUtil, win xproto.Window) bool {
+	types, _ := ewmh.WmWindowTypeGet(xu, win)
+	for _, typ := range types {
+		if typ == "_NET_WM_WINDOW_TYPE_DIALOG" {
+			return true
+		}
+	}
+	return false
+}
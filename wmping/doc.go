@@ -0,0 +1,44 @@
+/*
+Package wmping lets a window manager find out which clients have stopped
+responding, using the _NET_WM_PING protocol from the EWMH.
+
+A window manager pings a client window that supports _NET_WM_PING (i.e., it
+is listed in the window's WM_PROTOCOLS) by sending it a client message, which
+the client is supposed to send straight back to the root window. A client
+that doesn't reply within a reasonable time is probably hung, and the window
+manager may then offer to kill it (e.g., by drawing its title as "Not
+responding").
+
+Usage
+
+A Tracker sends pings, matches the replies and calls a function whenever a
+client is found to be hung, or starts responding again:
+
+	tracker := wmping.New(XUtilValue, 0,
+		func(X *xgbutil.XUtil, win xproto.Window, hung bool) {
+			if hung {
+				// Show "Not responding" and offer to kill the client.
+			} else {
+				// Back to normal.
+			}
+		})
+
+A good time to ping a client is when the user tries to close it, or when the
+user interacts with its window:
+
+	if wmping.Supported(XUtilValue, client) {
+		tracker.Ping(client)
+	}
+
+Timeouts are checked with xevent.AfterFunc, so the HungFun is always called
+from the main event loop, just like event handlers.
+
+Killing clients
+
+Tracker.Kill closes a client for good. If the client has set _NET_WM_PID and
+WM_CLIENT_MACHINE matches the host name of this machine, its process is
+killed. In all cases, its connection to the X server is closed with
+KillClient. ProcessGet can be used to show the user which process would be
+killed beforehand.
+*/
+package wmping
@@ -0,0 +1,273 @@
+package wmping
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/icccm"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// DefaultTimeout is the time a client has to reply to a ping when New is
+// given a zero timeout.
+const DefaultTimeout = 5 * time.Second
+
+// HungFun is called when a client window doesn't reply to a ping in time
+// ('hung' is true), and again when a hung client window replies to a later
+// ping ('hung' is false).
+type HungFun func(xu *xgbutil.XUtil, win xproto.Window, hung bool)
+
+// ping is a ping that is waiting for its reply.
+type ping struct {
+	tstamp xproto.Timestamp
+	timer  *xevent.Timer
+}
+
+// Tracker sends _NET_WM_PING to client windows and keeps track of which
+// clients haven't replied in time. Use New to create one.
+type Tracker struct {
+	X       *xgbutil.XUtil
+	Timeout time.Duration
+
+	fun   HungFun
+	lck   *sync.Mutex
+	pings map[xproto.Window]*ping
+	hung  map[xproto.Window]bool
+
+	// replyFun is the callback on the root window that receives replies.
+	replyFun xevent.ClientMessageFun
+}
+
+// New creates a Tracker that calls 'fun' whenever a client window is found
+// to be hung or starts responding again. Clients have 'timeout' to reply to
+// each ping; if 'timeout' is zero, DefaultTimeout is used.
+//
+// Replies are sent to the root window, so the window manager must have
+// SubstructureRedirect selected on it and be running xgbutil's main event
+// loop (see xevent.Main). 'fun' is called by the main event loop, both for
+// replies and for timeouts.
+func New(xu *xgbutil.XUtil, timeout time.Duration, fun HungFun) *Tracker {
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	t := &Tracker{
+		X:       xu,
+		Timeout: timeout,
+		fun:     fun,
+		lck:     &sync.Mutex{},
+		pings:   make(map[xproto.Window]*ping),
+		hung:    make(map[xproto.Window]bool),
+	}
+	t.replyFun = func(xu *xgbutil.XUtil, ev xevent.ClientMessageEvent) {
+		t.reply(ev)
+	}
+	xevent.Attach(xu, xevent.ClientMessage, xu.RootWin(), &t.replyFun)
+	return t
+}
+
+// Detach stops listening for replies and cancels every ping that is waiting
+// for its reply. The tracker can't be used afterwards.
+func (t *Tracker) Detach() {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	xevent.Disconnect(t.X, xevent.ClientMessage, t.X.RootWin(), &t.replyFun)
+	for win, p := range t.pings {
+		p.timer.Stop()
+		delete(t.pings, win)
+	}
+}
+
+// Supported returns whether the client window 'win' has _NET_WM_PING in its
+// WM_PROTOCOLS. Clients that don't can't be pinged.
+func Supported(xu *xgbutil.XUtil, win xproto.Window) bool {
+	prots, err := icccm.WmProtocolsGet(xu, win)
+	if err != nil {
+		return false
+	}
+	for _, prot := range prots {
+		if prot == "_NET_WM_PING" {
+			return true
+		}
+	}
+	return false
+}
+
+// Ping sends _NET_WM_PING to the client window 'win', timestamped with the
+// time of the last event. If the client doesn't reply within the tracker's
+// timeout, it is considered hung. If a ping to 'win' is already waiting for
+// its reply, no new ping is sent.
+//
+// An error is returned if 'win' doesn't support _NET_WM_PING.
+func (t *Tracker) Ping(win xproto.Window) error {
+	if !Supported(t.X, win) {
+		return fmt.Errorf("Ping: Window %x does not support _NET_WM_PING.",
+			win)
+	}
+
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	if _, ok := t.pings[win]; ok {
+		return nil
+	}
+
+	tstamp := t.X.TimeGet()
+	if err := pingReq(t.X, win, tstamp); err != nil {
+		return err
+	}
+	p := &ping{tstamp: tstamp}
+	p.timer = xevent.AfterFunc(t.X, t.Timeout, func() { t.timeout(win, p) })
+	t.pings[win] = p
+	return nil
+}
+
+// Hung returns whether 'win' failed to reply to its last ping (and hasn't
+// replied since).
+func (t *Tracker) Hung(win xproto.Window) bool {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	return t.hung[win]
+}
+
+// Waiting returns whether a ping sent to 'win' is waiting for its reply.
+func (t *Tracker) Waiting(win xproto.Window) bool {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	_, ok := t.pings[win]
+	return ok
+}
+
+// Forget stops tracking 'win'. Any ping waiting for a reply is cancelled.
+// It should be called when a client window is unmanaged.
+func (t *Tracker) Forget(win xproto.Window) {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	if p, ok := t.pings[win]; ok {
+		p.timer.Stop()
+		delete(t.pings, win)
+	}
+	delete(t.hung, win)
+}
+
+// Kill forcefully closes a (presumably hung) client window. If the client
+// runs on this machine (see ProcessGet), its process is killed. The client's
+// connection to the X server is then closed with KillClient, which also
+// works for remote clients and clients that didn't set _NET_WM_PID.
+func (t *Tracker) Kill(win xproto.Window) error {
+	t.Forget(win)
+
+	if proc, err := ProcessGet(t.X, win); err == nil && proc.Local {
+		if p, err := os.FindProcess(proc.Pid); err == nil {
+			if err := p.Kill(); err != nil {
+				xgbutil.Logger.Printf("Kill: Could not kill process %d: %s",
+					proc.Pid, err)
+			}
+		}
+	}
+	return xproto.KillClientChecked(t.X.Conn(), uint32(win)).Check()
+}
+
+// timeout is called when the ping 'p' to 'win' hasn't been answered in time.
+func (t *Tracker) timeout(win xproto.Window, p *ping) {
+	t.lck.Lock()
+	if t.pings[win] != p {
+		t.lck.Unlock()
+		return
+	}
+	delete(t.pings, win)
+	wasHung := t.hung[win]
+	t.hung[win] = true
+	t.lck.Unlock()
+
+	if !wasHung && t.fun != nil {
+		t.fun(t.X, win, true)
+	}
+}
+
+// reply checks whether 'ev' is the reply to a ping that is waiting, and if
+// so, records that the client responded.
+func (t *Tracker) reply(ev xevent.ClientMessageEvent) {
+	if ev.Format != 32 {
+		return
+	}
+	protsAtom, err := xprop.Atm(t.X, "WM_PROTOCOLS")
+	if err != nil || ev.Type != protsAtom {
+		return
+	}
+	d := ev.Data.Data32
+	pingAtom, err := xprop.Atm(t.X, "_NET_WM_PING")
+	if err != nil || xproto.Atom(d[0]) != pingAtom {
+		return
+	}
+
+	win, tstamp := xproto.Window(d[2]), xproto.Timestamp(d[1])
+	t.lck.Lock()
+	p, ok := t.pings[win]
+	if !ok || p.tstamp != tstamp {
+		t.lck.Unlock()
+		return
+	}
+	p.timer.Stop()
+	delete(t.pings, win)
+	wasHung := t.hung[win]
+	delete(t.hung, win)
+	t.lck.Unlock()
+
+	if wasHung && t.fun != nil {
+		t.fun(t.X, win, false)
+	}
+}
+
+// Process describes the process that owns a client window, as reported by
+// the client itself in _NET_WM_PID and WM_CLIENT_MACHINE.
+type Process struct {
+	Pid     int
+	Machine string
+
+	// Local is true when Machine is the name of this machine, in which case
+	// Pid may be used to signal the process.
+	Local bool
+}
+
+// ProcessGet returns the process that owns 'win'. An error is returned if
+// 'win' doesn't have _NET_WM_PID. Since the PID is meaningless without
+// knowing where the client runs, Local is only true if WM_CLIENT_MACHINE is
+// set and matches the host name of this machine.
+func ProcessGet(xu *xgbutil.XUtil, win xproto.Window) (*Process, error) {
+	pid, err := ewmh.WmPidGet(xu, win)
+	if err != nil {
+		return nil, err
+	}
+
+	proc := &Process{Pid: int(pid)}
+	proc.Machine, _ = icccm.WmClientMachineGet(xu, win)
+	if host, err := os.Hostname(); err == nil && len(proc.Machine) > 0 {
+		proc.Local = proc.Machine == host
+	}
+	return proc, nil
+}
+
+// pingReq sends _NET_WM_PING to the client window 'win'. Unlike other
+// WM_PROTOCOLS messages, the window is also included in the message, since
+// the client sends the message back to the root window as its reply.
+func pingReq(xu *xgbutil.XUtil, win xproto.Window,
+	tstamp xproto.Timestamp) error {
+
+	pingAtom, err := xprop.Atm(xu, "_NET_WM_PING")
+	if err != nil {
+		return err
+	}
+	return xevent.SendClientMessage(xu, win, win, "WM_PROTOCOLS",
+		int(pingAtom), int(tstamp), int(win))
+}
@@ -0,0 +1,50 @@
+/*
+Package xdnd implements version 5 of the XDND drag and drop protocol, both as
+a drop target and as a drag source.
+
+The protocol is described here:
+http://www.freedesktop.org/wiki/Specifications/XDND
+
+In short, windows that accept drops set the XdndAware property. While the
+user drags, the source sends XdndEnter, XdndPosition and XdndLeave messages
+to the aware window under the pointer, which answers each XdndPosition with
+an XdndStatus saying whether it would accept a drop, and with which action
+(copy, move, link, ...). When the user drops, the source sends XdndDrop, the
+target fetches the data by converting the XdndSelection selection to one of
+the types offered by the source, and finally sends XdndFinished.
+
+Targets
+
+A window becomes a drop target with NewTarget. Accepting files dropped from
+a file manager looks like this:
+
+	xdnd.NewTarget(win, xdnd.TargetHandlers{
+		Position: func(t *xdnd.Target, o *xdnd.Offer) string {
+			if !o.HasType("text/uri-list") {
+				return ""
+			}
+			return xdnd.ActionCopy
+		},
+		Drop: func(t *xdnd.Target, o *xdnd.Offer) string {
+			return "text/uri-list"
+		},
+		Data: func(t *xdnd.Target, o *xdnd.Offer, typ string,
+			data []byte) bool {
+
+			for _, file := range xdnd.Files(data) {
+				open(file)
+			}
+			return true
+		},
+	})
+
+Sources
+
+A window becomes a drag source with NewSource. Drags are then driven by the
+pointer; see the documentation of Source for how to use it with
+mousebind.Drag. Data that doesn't fit in a single request to the X server is
+sent incrementally, with the INCR mechanism of the ICCCM.
+
+Both roles rely on xgbutil's main event loop (see xevent.Main).
+*/
+package xdnd
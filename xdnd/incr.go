@@ -0,0 +1,164 @@
+package xdnd
+
+/*
+xdnd/incr.go contains the sending side of the INCR mechanism from section
+2.7.2 of the ICCCM, which a Source uses for dropped data that is too big to
+write to a property with a single request.
+
+The source writes a property of type INCR, and then writes the data one
+piece at a time: each time the requestor deletes the property, the next
+piece is written. An empty piece marks the end of the data.
+*/
+
+import (
+	"time"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// incrTimeout is how long a Source waits for the requestor to take a piece
+// of data sent with INCR, before giving up on the transfer.
+const incrTimeout = 10 * time.Second
+
+// transfer is data being sent in pieces to the requestor of XdndSelection.
+type transfer struct {
+	s         *Source
+	requestor xproto.Window
+	prop, typ xproto.Atom
+	data      []byte
+
+	// addedMask is whether we selected PropertyChange on the requestor, and
+	// so have to deselect it when the transfer is over.
+	addedMask bool
+
+	timer   *xevent.Timer
+	propFun xevent.PropertyNotifyFun
+}
+
+// incrStart starts sending 'data' of type 'typ' to the property 'prop' of
+// 'requestor' with INCR. The SelectionNotify event is left to the caller.
+// A transfer to the same requestor that is still in progress is abandoned.
+func (s *Source) incrStart(requestor xproto.Window, prop, typ xproto.Atom,
+	data []byte) error {
+
+	X := s.Win.X
+	incrAtom, err := xprop.Atm(X, "INCR")
+	if err != nil {
+		return err
+	}
+	if old, ok := s.transfers[requestor]; ok {
+		old.stop()
+	}
+
+	// The requestor's PropertyNotify events tell us when to send the next
+	// piece.
+	attrs, err := xproto.GetWindowAttributes(X.Conn(), requestor).Reply()
+	if err != nil {
+		return err
+	}
+	t := &transfer{s: s, requestor: requestor, prop: prop, typ: typ,
+		data: data}
+	if attrs.YourEventMask&xproto.EventMaskPropertyChange == 0 {
+		err = xproto.ChangeWindowAttributesChecked(X.Conn(), requestor,
+			xproto.CwEventMask, []uint32{attrs.YourEventMask |
+				xproto.EventMaskPropertyChange}).Check()
+		if err != nil {
+			return err
+		}
+		t.addedMask = true
+	}
+	t.propFun = func(X *xgbutil.XUtil, ev xevent.PropertyNotifyEvent) {
+		if ev.Atom == t.prop && ev.State == xproto.PropertyDelete {
+			t.next()
+		}
+	}
+	xevent.Attach(X, xevent.PropertyNotify, requestor, &t.propFun)
+	s.transfers[requestor] = t
+
+	// The value of the INCR property is a lower bound on the size of the
+	// data.
+	buf := make([]byte, 4)
+	xgb.Put32(buf, uint32(len(data)))
+	err = xproto.ChangePropertyChecked(X.Conn(), xproto.PropModeReplace,
+		requestor, prop, incrAtom, 32, 1, buf).Check()
+	if err != nil {
+		t.stop()
+		return err
+	}
+	t.timer = xevent.AfterFunc(X, incrTimeout, t.timedOut)
+	return nil
+}
+
+// stopTransfers abandons every transfer in progress.
+func (s *Source) stopTransfers() {
+	for _, t := range s.transfers {
+		t.stop()
+	}
+}
+
+// next writes the next piece of data, once the requestor has deleted the
+// previous one. The transfer is over once the empty piece is written.
+func (t *transfer) next() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+
+	X := t.s.Win.X
+	n := len(t.data)
+	if limit := maxPropertyLength(X); n > limit {
+		n = limit
+	}
+	piece := t.data[:n]
+	t.data = t.data[n:]
+
+	err := xproto.ChangePropertyChecked(X.Conn(), xproto.PropModeReplace,
+		t.requestor, t.prop, t.typ, 8, uint32(n), piece).Check()
+	if err != nil {
+		xgbutil.Logger.Printf("Could not send dropped data to %x: %s",
+			t.requestor, err)
+		t.stop()
+		return
+	}
+	if n == 0 {
+		t.stop()
+		return
+	}
+	t.timer = xevent.AfterFunc(X, incrTimeout, t.timedOut)
+}
+
+// timedOut gives up on a requestor that stopped taking pieces.
+func (t *transfer) timedOut() {
+	xgbutil.Logger.Printf("Gave up sending dropped data to %x: it took "+
+		"longer than %s to take a piece.", t.requestor, incrTimeout)
+	t.stop()
+}
+
+// stop ends the transfer, and deselects PropertyChange on the requestor if
+// we selected it.
+func (t *transfer) stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	X := t.s.Win.X
+	xevent.Disconnect(X, xevent.PropertyNotify, t.requestor, &t.propFun)
+	if t.s.transfers[t.requestor] == t {
+		delete(t.s.transfers, t.requestor)
+	}
+	if !t.addedMask {
+		return
+	}
+
+	// The requestor may be gone already, in which case there's nothing to
+	// deselect.
+	attrs, err := xproto.GetWindowAttributes(X.Conn(), t.requestor).Reply()
+	if err != nil {
+		return
+	}
+	xproto.ChangeWindowAttributes(X.Conn(), t.requestor, xproto.CwEventMask,
+		[]uint32{attrs.YourEventMask &^ xproto.EventMaskPropertyChange})
+}
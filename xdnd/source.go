@@ -0,0 +1,515 @@
+package xdnd
+
+/*
+xdnd/source.go contains the drag source side of XDND. While the user drags,
+the source finds the XDND aware window under the pointer, tells it about the
+drag and, when the data is dropped, hands the data over through the
+XdndSelection selection.
+*/
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// dropTimeout is how long a Source waits for the target to finish a drop,
+// before giving up on it.
+const dropTimeout = 10 * time.Second
+
+// SourceHandlers are the functions called by a Source. Data must be set, and
+// the others may be nil.
+//
+// Data is called when the target asks for the dropped data, and returns the
+// data as the type 'typ' (which is one of the types given to Begin), or
+// false if it can't be converted.
+//
+// Status is called when the target under the pointer says whether it would
+// accept a drop, with the action it would perform ("" if it wouldn't accept
+// the drop). It can be used to change the cursor.
+//
+// Finished is called when a drag ended by Drop is over, with the action the
+// target performed ("" if the drop was refused or failed). If the action is
+// ActionMove, the source should delete the data.
+type SourceHandlers struct {
+	Data     func(s *Source, typ string) ([]byte, bool)
+	Status   func(s *Source, target xproto.Window, action string)
+	Finished func(s *Source, target xproto.Window, action string)
+}
+
+// Source is a window that data can be dragged from. Use NewSource to create
+// one. A Source can be used for any number of drags, one at a time.
+//
+// A drag is started with Begin, followed by Move every time the pointer
+// moves, and ended with Drop or Cancel. The pointer should be grabbed in the
+// meantime, which is most easily done with mousebind.Drag:
+//
+//	mousebind.Drag(X, win.Id, win.Id, "1", true,
+//		func(X *xgbutil.XUtil, rx, ry, ex, ey int) (bool, xproto.Cursor) {
+//			return source.Begin([]string{"text/plain"},
+//				xdnd.ActionCopy) == nil, cursor
+//		},
+//		func(X *xgbutil.XUtil, rx, ry, ex, ey int) {
+//			source.Move(rx, ry)
+//		},
+//		func(X *xgbutil.XUtil, rx, ry, ex, ey int) {
+//			source.Drop()
+//		})
+type Source struct {
+	Win *xwindow.Window
+
+	handlers SourceHandlers
+	types    []string
+	action   string
+	active   bool
+
+	// The target under the pointer, the window to send messages to (see
+	// FindTarget), the XDND version used with the target and the action it
+	// accepted in its last XdndStatus.
+	target   xproto.Window
+	proxy    xproto.Window
+	version  uint
+	accepted string
+
+	// 'waiting' is true between an XdndPosition and its XdndStatus. Moves in
+	// the meantime are remembered in 'pending', and a Drop in 'dropping'.
+	waiting  bool
+	pending  bool
+	px, py   int
+	dropping bool
+	dropped  bool
+
+	// timer gives up on a drop that the target never finishes.
+	timer *xevent.Timer
+
+	// transfers are the INCR transfers of dropped data in progress, by
+	// requestor.
+	transfers map[xproto.Window]*transfer
+
+	// msgFun receives messages from targets on the source window. hook
+	// sees requests for the dropped data, since xevent dispatches
+	// SelectionRequest events on the requestor window, which isn't ours.
+	msgFun xevent.ClientMessageFun
+	hook   xevent.HookFun
+}
+
+// NewSource makes 'win' able to start drags. 'win' is used to own the
+// XdndSelection selection during drags, and doesn't need to be mapped. Use
+// Stop once the Source is no longer needed.
+//
+// A Source relies on xgbutil's main event loop (see xevent.Main).
+func NewSource(win *xwindow.Window, handlers SourceHandlers) *Source {
+	s := &Source{
+		Win:       win,
+		handlers:  handlers,
+		transfers: make(map[xproto.Window]*transfer),
+	}
+	s.connect()
+	return s
+}
+
+// Active returns whether a drag is in progress (including a drop that the
+// target hasn't finished yet).
+func (s *Source) Active() bool {
+	return s.active
+}
+
+// Target returns the XDND aware window under the pointer, or 0.
+func (s *Source) Target() xproto.Window {
+	return s.target
+}
+
+// Accepted returns the action that the target under the pointer would
+// perform, or "" if it wouldn't accept a drop.
+func (s *Source) Accepted() string {
+	return s.accepted
+}
+
+// Begin starts a drag of data that can be converted to 'types' (usually
+// MIME types), asking targets to perform 'action'. If 'action' is
+// ActionAsk, the possible actions should be set with ActionListSet on the
+// source window beforehand.
+func (s *Source) Begin(types []string, action string) error {
+	if s.active {
+		return fmt.Errorf("Begin: A drag is already in progress.")
+	}
+	X := s.Win.X
+
+	if len(types) > 3 {
+		if err := TypeListSet(X, s.Win.Id, types); err != nil {
+			return err
+		}
+	}
+	selAtom, err := xprop.Atm(X, "XdndSelection")
+	if err != nil {
+		return err
+	}
+	xproto.SetSelectionOwner(X.Conn(), s.Win.Id, selAtom, X.TimeGet())
+	reply, err := xproto.GetSelectionOwner(X.Conn(), selAtom).Reply()
+	if err != nil {
+		return err
+	}
+	if reply.Owner != s.Win.Id {
+		return fmt.Errorf("Begin: Could not acquire ownership of " +
+			"XdndSelection.")
+	}
+
+	s.types, s.action = types, action
+	s.active, s.dropping, s.dropped = true, false, false
+	s.reset()
+	return nil
+}
+
+// ActionSet changes the action requested from targets during a drag (e.g.,
+// when the user presses a modifier key). It takes effect with the next Move.
+func (s *Source) ActionSet(action string) {
+	s.action = action
+}
+
+// Move tells the source that the pointer is at the root coordinates (x, y).
+func (s *Source) Move(x, y int) error {
+	if !s.active || s.dropping || s.dropped {
+		return nil
+	}
+	X := s.Win.X
+
+	target, proxy, version, err := FindTarget(X, x, y)
+	if err != nil {
+		return err
+	}
+	if version < MinVersion {
+		target = 0
+	}
+	if target != s.target {
+		if s.target != 0 {
+			s.leave()
+		}
+		if target != 0 {
+			if version > Version {
+				version = Version
+			}
+			s.target, s.proxy, s.version = target, proxy, version
+			if err := s.enter(); err != nil {
+				s.reset()
+				return err
+			}
+		}
+	}
+	if s.target == 0 {
+		return nil
+	}
+
+	if s.waiting {
+		s.pending, s.px, s.py = true, x, y
+		return nil
+	}
+	return s.position(x, y)
+}
+
+// Drop drops the data on the target under the pointer. Finished is called
+// once the target is done with it (or right away, if there is no target or
+// it refused the drop). If the target doesn't finish the drop within
+// dropTimeout, Finished is called with the action "".
+func (s *Source) Drop() error {
+	if !s.active || s.dropping || s.dropped {
+		return nil
+	}
+	s.timer = xevent.AfterFunc(s.Win.X, dropTimeout, s.dropTimedOut)
+	if s.waiting {
+		// Wait for the target to answer the last XdndPosition first.
+		s.dropping = true
+		return nil
+	}
+	return s.drop()
+}
+
+// Cancel aborts the drag.
+func (s *Source) Cancel() {
+	if !s.active {
+		return
+	}
+	if s.target != 0 {
+		s.leave()
+	}
+	s.end()
+}
+
+// Stop cancels the drag in progress, if any, and removes the event handlers
+// of the source. The Source can't be used afterwards.
+func (s *Source) Stop() {
+	s.Cancel()
+	s.stopTransfers()
+	xevent.Disconnect(s.Win.X, xevent.ClientMessage, s.Win.Id, &s.msgFun)
+	xevent.DisconnectHook(s.Win.X, &s.hook)
+}
+
+// reset forgets the target.
+func (s *Source) reset() {
+	s.target, s.proxy, s.version, s.accepted = 0, 0, 0, ""
+	s.waiting, s.pending = false, false
+}
+
+// enter sends XdndEnter to the target.
+func (s *Source) enter() error {
+	X := s.Win.X
+	flags := int(s.version << 24)
+	if len(s.types) > 3 {
+		flags |= enterMoreTypes
+	}
+
+	typAtoms := make([]int, 3)
+	for i := 0; i < len(typAtoms) && i < len(s.types); i++ {
+		a, err := atom(X, s.types[i])
+		if err != nil {
+			return err
+		}
+		typAtoms[i] = a
+	}
+	return xevent.SendClientMessage(X, s.proxy, s.target, "XdndEnter",
+		int(s.Win.Id), flags, typAtoms[0], typAtoms[1], typAtoms[2])
+}
+
+// position sends XdndPosition to the target.
+func (s *Source) position(x, y int) error {
+	X := s.Win.X
+	action, err := atom(X, s.action)
+	if err != nil {
+		return err
+	}
+	s.waiting = true
+	return xevent.SendClientMessage(X, s.proxy, s.target, "XdndPosition",
+		int(s.Win.Id), 0, packPoint(x, y), int(X.TimeGet()), action)
+}
+
+// leave sends XdndLeave to the target and forgets it.
+func (s *Source) leave() {
+	err := xevent.SendClientMessage(s.Win.X, s.proxy, s.target, "XdndLeave",
+		int(s.Win.Id))
+	if err != nil {
+		xgbutil.Logger.Printf("Could not send XdndLeave to %x: %s",
+			s.target, err)
+	}
+	if s.handlers.Status != nil {
+		s.handlers.Status(s, 0, "")
+	}
+	s.reset()
+}
+
+// drop sends XdndDrop to the target if it accepted the drop, and otherwise
+// ends the drag.
+func (s *Source) drop() error {
+	s.dropping = false
+	if s.target == 0 || len(s.accepted) == 0 {
+		target := s.target
+		if target != 0 {
+			s.leave()
+		}
+		s.finish(target, "")
+		return nil
+	}
+
+	s.dropped = true
+	return xevent.SendClientMessage(s.Win.X, s.proxy, s.target, "XdndDrop",
+		int(s.Win.Id), 0, int(s.Win.X.TimeGet()))
+}
+
+// finish ends the drag and calls Finished.
+func (s *Source) finish(target xproto.Window, action string) {
+	s.end()
+	s.reset()
+	if s.handlers.Finished != nil {
+		s.handlers.Finished(s, target, action)
+	}
+}
+
+// dropTimedOut gives up on a drop that the target didn't finish in time.
+func (s *Source) dropTimedOut() {
+	target := s.target
+	if target != 0 && !s.dropped {
+		// The target never answered, and hasn't been told about the drop.
+		s.leave()
+	}
+	s.finish(target, "")
+}
+
+// end marks the drag as over, abandons any unfinished transfers of the data
+// and gives up the XdndSelection selection if we still own it.
+func (s *Source) end() {
+	s.active, s.dropping, s.dropped = false, false, false
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.stopTransfers()
+
+	X := s.Win.X
+	selAtom, err := xprop.Atm(X, "XdndSelection")
+	if err != nil {
+		xgbutil.Logger.Println(err)
+		return
+	}
+	reply, err := xproto.GetSelectionOwner(X.Conn(), selAtom).Reply()
+	if err != nil || reply.Owner != s.Win.Id {
+		return
+	}
+	xproto.SetSelectionOwner(X.Conn(), 0, selAtom, X.TimeGet())
+}
+
+// connect attaches the event handlers for messages from targets and for
+// requests for the dropped data.
+func (s *Source) connect() {
+	X := s.Win.X
+
+	s.msgFun = func(X *xgbutil.XUtil, ev xevent.ClientMessageEvent) {
+		if name := messageName(X, ev); len(name) > 0 {
+			s.message(name, ev.Data.Data32)
+		}
+	}
+	xevent.Attach(X, xevent.ClientMessage, s.Win.Id, &s.msgFun)
+
+	s.hook = func(X *xgbutil.XUtil, event interface{}) bool {
+		ev, ok := event.(xproto.SelectionRequestEvent)
+		if ok && ev.Owner == s.Win.Id &&
+			atomName(X, uint32(ev.Selection)) == "XdndSelection" {
+
+			s.selectionRequest(ev)
+		}
+		return true
+	}
+	xevent.AttachHook(X, &s.hook)
+}
+
+// message handles the XDND message 'name' sent by a target.
+func (s *Source) message(name string, d []uint32) {
+	if !s.active || s.target == 0 || xproto.Window(d[0]) != s.target {
+		return
+	}
+
+	switch name {
+	case "XdndStatus":
+		s.waiting = false
+		s.accepted = ""
+		if d[1]&statusAccept > 0 {
+			s.accepted = atomName(s.Win.X, d[4])
+			if len(s.accepted) == 0 {
+				s.accepted = ActionCopy
+			}
+		}
+		if s.handlers.Status != nil {
+			s.handlers.Status(s, s.target, s.accepted)
+		}
+
+		var err error
+		switch {
+		case s.dropping:
+			err = s.drop()
+		case s.pending:
+			s.pending = false
+			err = s.position(s.px, s.py)
+		}
+		if err != nil {
+			xgbutil.Logger.Println(err)
+		}
+	case "XdndFinished":
+		if !s.dropped {
+			return
+		}
+		action := s.accepted
+		if s.version >= 5 {
+			action = ""
+			if d[1]&finishedAccept > 0 {
+				action = atomName(s.Win.X, d[2])
+			}
+		}
+		s.finish(s.target, action)
+	}
+}
+
+// selectionRequest answers a request for the dropped data, following
+// section 2.2 of the ICCCM. Besides the types of the drag, the TARGETS type
+// is supported. Data that doesn't fit in a single request is sent with INCR.
+func (s *Source) selectionRequest(ev xproto.SelectionRequestEvent) {
+	X := s.Win.X
+	prop := ev.Property
+	if prop == 0 {
+		prop = ev.Target
+	}
+
+	typ := atomName(X, uint32(ev.Target))
+	err := fmt.Errorf("selectionRequest: Cannot convert to '%s'.", typ)
+	switch {
+	case typ == "TARGETS":
+		err = s.targetsSet(ev.Requestor, prop)
+	case s.hasType(typ) && s.handlers.Data != nil:
+		data, ok := s.handlers.Data(s, typ)
+		switch {
+		case !ok:
+		case len(data) > maxPropertyLength(X):
+			err = s.incrStart(ev.Requestor, prop, ev.Target, data)
+		default:
+			err = xproto.ChangePropertyChecked(X.Conn(),
+				xproto.PropModeReplace, ev.Requestor, prop, ev.Target, 8,
+				uint32(len(data)), data).Check()
+		}
+	}
+	if err != nil {
+		xgbutil.Logger.Println(err)
+		prop = 0
+	}
+
+	notify := xproto.SelectionNotifyEvent{
+		Time:      ev.Time,
+		Requestor: ev.Requestor,
+		Selection: ev.Selection,
+		Target:    ev.Target,
+		Property:  prop,
+	}
+	xproto.SendEvent(X.Conn(), false, ev.Requestor, xproto.EventMaskNoEvent,
+		string(notify.Bytes()))
+}
+
+// targetsSet writes the TARGETS of the drag to the property 'prop' of
+// 'win'.
+func (s *Source) targetsSet(win xproto.Window, prop xproto.Atom) error {
+	X := s.Win.X
+	atoms, err := xprop.StrToAtoms(X, append([]string{"TARGETS"},
+		s.types...))
+	if err != nil {
+		return err
+	}
+	atomAtom, err := xprop.Atm(X, "ATOM")
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, len(atoms)*4)
+	for i, a := range atoms {
+		xgb.Put32(buf[i*4:], uint32(a))
+	}
+	return xproto.ChangePropertyChecked(X.Conn(), xproto.PropModeReplace,
+		win, prop, atomAtom, 32, uint32(len(atoms)), buf).Check()
+}
+
+// hasType returns whether 'typ' is one of the types of the drag.
+func (s *Source) hasType(typ string) bool {
+	for _, t := range s.types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// maxPropertyLength returns the number of bytes that can be written to a
+// property with a single ChangeProperty request. The maximum request length
+// is in units of 4 bytes, and the request has a 24 byte header.
+func maxPropertyLength(xu *xgbutil.XUtil) int {
+	return int(xu.Setup().MaximumRequestLength)*4 - 24
+}
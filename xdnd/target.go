@@ -0,0 +1,363 @@
+package xdnd
+
+/*
+xdnd/target.go contains the drop target side of XDND. A target window
+advertises XdndAware, tells the source whether it would accept a drop at the
+pointer's position, and fetches the dropped data through the XdndSelection
+selection.
+*/
+
+import (
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// Offer is a drag that is over a Target.
+type Offer struct {
+	// Source is the window of the drag source.
+	Source xproto.Window
+
+	// Version is the XDND version used with the source.
+	Version uint
+
+	// Types are the data types offered by the source, as atom names
+	// (usually MIME types, like "text/uri-list").
+	Types []string
+
+	// X and Y are the root coordinates of the pointer, and Time is the
+	// timestamp of the last position (or of the drop).
+	X, Y int
+	Time xproto.Timestamp
+
+	// Action is the action requested by the source, and Accepted is the
+	// action that the target last accepted ("" if it refused the drop).
+	Action   string
+	Accepted string
+}
+
+// HasType returns whether the source offers the data type 'typ'.
+func (o *Offer) HasType(typ string) bool {
+	for _, t := range o.Types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// TargetHandlers are the functions called by a Target. Any of them may be
+// nil.
+//
+// Enter is called when a drag enters the target window, and Leave when it
+// leaves it without dropping.
+//
+// Position is called whenever the pointer moves over the target window, and
+// returns the action that would be performed if the data were dropped there,
+// or "" to refuse the drop. Usually, this is o.Action. If Position is nil,
+// the action requested by the source is always accepted.
+//
+// Drop is called when the data is dropped, and returns the type of data to
+// fetch from the source, or "" to refuse the drop. If Drop is nil, the first
+// type in o.Types is fetched. Data is then called with the data, and returns
+// whether the drop succeeded. The source is told about the result when Data
+// returns (or immediately, if the data couldn't be fetched, in which case
+// Data isn't called).
+type TargetHandlers struct {
+	Enter    func(t *Target, o *Offer)
+	Position func(t *Target, o *Offer) string
+	Leave    func(t *Target, o *Offer)
+	Drop     func(t *Target, o *Offer) string
+	Data     func(t *Target, o *Offer, typ string, data []byte) bool
+}
+
+// Target is a window that accepts drops. Use NewTarget to create one.
+type Target struct {
+	Win *xwindow.Window
+
+	handlers TargetHandlers
+	offer    *Offer
+	done     bool
+
+	// The transfer of the dropped data. 'fetching' is the type being
+	// fetched, and 'incr' is true while receiving the data in pieces.
+	fetching string
+	incr     bool
+	data     []byte
+
+	// callbacks attached with xevent.Attach to the target window
+	msgFun  xevent.ClientMessageFun
+	selFun  xevent.SelectionNotifyFun
+	propFun xevent.PropertyNotifyFun
+}
+
+// NewTarget makes 'win' a drop target by setting XdndAware on it. 'win'
+// should be a top-level window: sources only look for XdndAware on the
+// windows directly below frames of the window manager.
+//
+// A Target relies on xgbutil's main event loop (see xevent.Main).
+func NewTarget(win *xwindow.Window, handlers TargetHandlers) (*Target, error) {
+	t := &Target{Win: win, handlers: handlers}
+	if err := AwareSet(win.X, win.Id, Version); err != nil {
+		return nil, err
+	}
+
+	// PropertyNotify events are needed to receive large drops in pieces.
+	if err := win.ListenAdd(xproto.EventMaskPropertyChange); err != nil {
+		return nil, err
+	}
+	t.connect()
+	return t, nil
+}
+
+// Offer returns the drag that is currently over the target, or nil.
+func (t *Target) Offer() *Offer {
+	return t.offer
+}
+
+// Stop removes XdndAware from the target window and stops responding to
+// sources.
+func (t *Target) Stop() {
+	if t.done {
+		return
+	}
+	t.done = true
+	t.offer = nil
+
+	X, win := t.Win.X, t.Win.Id
+	xevent.Disconnect(X, xevent.ClientMessage, win, &t.msgFun)
+	xevent.Disconnect(X, xevent.SelectionNotify, win, &t.selFun)
+	xevent.Disconnect(X, xevent.PropertyNotify, win, &t.propFun)
+	if awareAtom, err := xprop.Atm(t.Win.X, "XdndAware"); err == nil {
+		xproto.DeleteProperty(t.Win.X.Conn(), t.Win.Id, awareAtom)
+	}
+}
+
+// connect attaches the event handlers for XDND messages and for receiving
+// the dropped data.
+func (t *Target) connect() {
+	X := t.Win.X
+
+	t.msgFun = func(X *xgbutil.XUtil, ev xevent.ClientMessageEvent) {
+		if t.done {
+			return
+		}
+		if name := messageName(X, ev); len(name) > 0 {
+			t.message(name, ev.Data.Data32)
+		}
+	}
+	xevent.Attach(X, xevent.ClientMessage, t.Win.Id, &t.msgFun)
+
+	t.selFun = func(X *xgbutil.XUtil, ev xevent.SelectionNotifyEvent) {
+		if t.done || len(t.fetching) == 0 {
+			return
+		}
+		if atomName(X, uint32(ev.Selection)) != "XdndSelection" {
+			return
+		}
+		if ev.Property == 0 {
+			t.finish(false)
+			return
+		}
+		t.receive(ev.Property)
+	}
+	xevent.Attach(X, xevent.SelectionNotify, t.Win.Id, &t.selFun)
+
+	t.propFun = func(X *xgbutil.XUtil, ev xevent.PropertyNotifyEvent) {
+		if t.done || !t.incr || ev.State != xproto.PropertyNewValue {
+			return
+		}
+		if atomName(X, uint32(ev.Atom)) != "XdndSelection" {
+			return
+		}
+		t.receive(ev.Atom)
+	}
+	xevent.Attach(X, xevent.PropertyNotify, t.Win.Id, &t.propFun)
+}
+
+// message handles the XDND message 'name' sent by a source.
+func (t *Target) message(name string, d []uint32) {
+	X := t.Win.X
+	source := xproto.Window(d[0])
+	o := t.offer
+	if name != "XdndEnter" && (o == nil || o.Source != source) {
+		return
+	}
+
+	switch name {
+	case "XdndEnter":
+		version := uint(d[1] >> 24)
+		if version < MinVersion {
+			return
+		}
+		if version > Version {
+			version = Version
+		}
+		if o != nil {
+			t.leave()
+		}
+
+		o = &Offer{Source: source, Version: version}
+		if d[1]&enterMoreTypes > 0 {
+			types, err := TypeListGet(X, source)
+			if err != nil {
+				xgbutil.Logger.Printf("Could not get XdndTypeList of %x: %s",
+					source, err)
+			}
+			o.Types = types
+		} else {
+			for _, a := range d[2:5] {
+				if typ := atomName(X, a); len(typ) > 0 {
+					o.Types = append(o.Types, typ)
+				}
+			}
+		}
+		t.offer = o
+		if t.handlers.Enter != nil {
+			t.handlers.Enter(t, o)
+		}
+	case "XdndPosition":
+		o.X, o.Y = unpackPoint(d[2])
+		o.Time = xproto.Timestamp(d[3])
+		o.Action = atomName(X, d[4])
+		if len(o.Action) == 0 {
+			o.Action = ActionCopy
+		}
+		if t.handlers.Position != nil {
+			o.Accepted = t.handlers.Position(t, o)
+		} else {
+			o.Accepted = o.Action
+		}
+		if err := t.status(); err != nil {
+			xgbutil.Logger.Printf("Could not send XdndStatus to %x: %s",
+				source, err)
+		}
+	case "XdndLeave":
+		t.leave()
+	case "XdndDrop":
+		o.Time = xproto.Timestamp(d[2])
+		t.drop()
+	}
+}
+
+// status tells the source whether the drop would be accepted. We always ask
+// for more XdndPosition messages, so that Position sees every move.
+func (t *Target) status() error {
+	o := t.offer
+	flags := statusWantPositions
+	if len(o.Accepted) > 0 {
+		flags |= statusAccept
+	}
+	action, err := atom(t.Win.X, o.Accepted)
+	if err != nil {
+		return err
+	}
+	return xevent.SendClientMessage(t.Win.X, o.Source, o.Source,
+		"XdndStatus", int(t.Win.Id), flags, 0, 0, action)
+}
+
+// leave forgets the current offer.
+func (t *Target) leave() {
+	o := t.offer
+	t.offer = nil
+	t.fetching = ""
+	if t.handlers.Leave != nil {
+		t.handlers.Leave(t, o)
+	}
+}
+
+// drop asks the source for the dropped data, if the drop is accepted.
+func (t *Target) drop() {
+	X, o := t.Win.X, t.offer
+	if len(o.Accepted) == 0 {
+		t.finish(false)
+		return
+	}
+
+	typ := ""
+	if t.handlers.Drop != nil {
+		typ = t.handlers.Drop(t, o)
+	} else if len(o.Types) > 0 {
+		typ = o.Types[0]
+	}
+	if len(typ) == 0 {
+		t.finish(false)
+		return
+	}
+
+	selAtom, err := xprop.Atm(X, "XdndSelection")
+	if err != nil {
+		t.finish(false)
+		return
+	}
+	typAtom, err := xprop.Atm(X, typ)
+	if err != nil {
+		t.finish(false)
+		return
+	}
+	t.fetching, t.incr, t.data = typ, false, nil
+	xproto.ConvertSelection(X.Conn(), t.Win.Id, selAtom, typAtom, selAtom,
+		o.Time)
+}
+
+// receive reads (and deletes) the property 'prop' holding the dropped data.
+// If the source sends the data in pieces with INCR, each piece is added to
+// t.data until an empty piece marks the end.
+func (t *Target) receive(prop xproto.Atom) {
+	X := t.Win.X
+	reply, err := xproto.GetProperty(X.Conn(), true, t.Win.Id, prop,
+		xproto.GetPropertyTypeAny, 0, (1<<32)-1).Reply()
+	if err != nil {
+		xgbutil.Logger.Printf("Could not get the dropped data: %s", err)
+		t.finish(false)
+		return
+	}
+
+	switch {
+	case !t.incr && atomName(X, uint32(reply.Type)) == "INCR":
+		// Deleting the property (done above) asks for the first piece.
+		t.incr = true
+	case t.incr && len(reply.Value) > 0:
+		t.data = append(t.data, reply.Value...)
+	default:
+		if !t.incr {
+			t.data = reply.Value
+		}
+		t.deliver()
+	}
+}
+
+// deliver gives the dropped data to the Data handler.
+func (t *Target) deliver() {
+	ok := false
+	if t.handlers.Data != nil && t.offer != nil {
+		ok = t.handlers.Data(t, t.offer, t.fetching, t.data)
+	}
+	t.finish(ok)
+}
+
+// finish ends the drop by sending XdndFinished, with the action performed
+// if the drop succeeded.
+func (t *Target) finish(ok bool) {
+	o := t.offer
+	t.offer = nil
+	t.fetching, t.incr, t.data = "", false, nil
+	if o == nil {
+		return
+	}
+
+	flags, action := 0, 0
+	if ok {
+		flags = finishedAccept
+		action, _ = atom(t.Win.X, o.Accepted)
+	}
+	err := xevent.SendClientMessage(t.Win.X, o.Source, o.Source,
+		"XdndFinished", int(t.Win.Id), flags, action)
+	if err != nil {
+		xgbutil.Logger.Printf("Could not send XdndFinished to %x: %s",
+			o.Source, err)
+	}
+}
@@ -0,0 +1,206 @@
+package xdnd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// Version is the version of XDND implemented by this package.
+const Version = 5
+
+// MinVersion is the oldest version of XDND that this package will talk to.
+const MinVersion = 3
+
+// Standard XDND actions.
+const (
+	ActionCopy    = "XdndActionCopy"
+	ActionMove    = "XdndActionMove"
+	ActionLink    = "XdndActionLink"
+	ActionAsk     = "XdndActionAsk"
+	ActionPrivate = "XdndActionPrivate"
+)
+
+// Flags of XdndEnter, XdndStatus and XdndFinished messages.
+const (
+	enterMoreTypes      = 1 << 0
+	statusAccept        = 1 << 0
+	statusWantPositions = 1 << 1
+	finishedAccept      = 1 << 0
+)
+
+// XdndAware get
+// The version returned is the XDND version supported by 'win'.
+func AwareGet(xu *xgbutil.XUtil, win xproto.Window) (uint, error) {
+	return xprop.PropValNum(xprop.GetProperty(xu, win, "XdndAware"))
+}
+
+// XdndAware set
+func AwareSet(xu *xgbutil.XUtil, win xproto.Window, version uint) error {
+	return xprop.ChangeProp32(xu, win, "XdndAware", "ATOM", version)
+}
+
+// XdndProxy get
+func ProxyGet(xu *xgbutil.XUtil, win xproto.Window) (xproto.Window, error) {
+	return xprop.PropValWindow(xprop.GetProperty(xu, win, "XdndProxy"))
+}
+
+// XdndProxy set
+func ProxySet(xu *xgbutil.XUtil, win, proxy xproto.Window) error {
+	return xprop.ChangeProp32(xu, win, "XdndProxy", "WINDOW", uint(proxy))
+}
+
+// XdndTypeList get
+func TypeListGet(xu *xgbutil.XUtil, win xproto.Window) ([]string, error) {
+	raw, err := xprop.GetProperty(xu, win, "XdndTypeList")
+	return xprop.PropValAtoms(xu, raw, err)
+}
+
+// XdndTypeList set
+func TypeListSet(xu *xgbutil.XUtil, win xproto.Window, types []string) error {
+	atoms, err := xprop.StrToAtoms(xu, types)
+	if err != nil {
+		return err
+	}
+	return xprop.ChangeProp32(xu, win, "XdndTypeList", "ATOM", atoms...)
+}
+
+// XdndActionList get
+func ActionListGet(xu *xgbutil.XUtil, win xproto.Window) ([]string, error) {
+	raw, err := xprop.GetProperty(xu, win, "XdndActionList")
+	return xprop.PropValAtoms(xu, raw, err)
+}
+
+// XdndActionList set
+// This should be set on the source window when the source offers ActionAsk,
+// and lists the actions that the user can choose from.
+func ActionListSet(xu *xgbutil.XUtil, win xproto.Window,
+	actions []string) error {
+
+	atoms, err := xprop.StrToAtoms(xu, actions)
+	if err != nil {
+		return err
+	}
+	return xprop.ChangeProp32(xu, win, "XdndActionList", "ATOM", atoms...)
+}
+
+// URIList parses data of the type "text/uri-list", which is how file
+// managers drop files. Comments and empty lines are skipped.
+func URIList(data []byte) []string {
+	uris := make([]string, 0)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		uris = append(uris, line)
+	}
+	return uris
+}
+
+// Files returns the local file names in data of the type "text/uri-list".
+// URIs that don't refer to local files are skipped.
+func Files(data []byte) []string {
+	files := make([]string, 0)
+	for _, uri := range URIList(data) {
+		u, err := url.Parse(uri)
+		if err != nil || u.Scheme != "file" {
+			continue
+		}
+		if len(u.Host) > 0 && u.Host != "localhost" {
+			continue
+		}
+		files = append(files, u.Path)
+	}
+	return files
+}
+
+// FindTarget returns the XDND aware window at the root coordinates (x, y),
+// along with the window that messages to it should be sent to (which differs
+// from the target if it has a valid XdndProxy) and the XDND version it
+// supports. If there is no aware window at (x, y), 'target' is 0.
+//
+// The search goes down from the root window to the deepest window at (x, y),
+// and stops at the first window with XdndAware. Usually, that is a client
+// window inside of a window manager frame.
+func FindTarget(xu *xgbutil.XUtil, x, y int) (target, proxy xproto.Window,
+	version uint, err error) {
+
+	root := xu.RootWin()
+	win := root
+	for {
+		reply, err := xproto.TranslateCoordinates(xu.Conn(), root, win,
+			int16(x), int16(y)).Reply()
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		if reply.Child == 0 {
+			return 0, 0, 0, nil
+		}
+		win = reply.Child
+
+		proxy = win
+		if p, err := ProxyGet(xu, win); err == nil && p != 0 {
+			// XdndProxy is only valid if the proxy points to itself.
+			if pp, err := ProxyGet(xu, p); err == nil && pp == p {
+				proxy = p
+			}
+		}
+		if version, err := AwareGet(xu, proxy); err == nil {
+			return win, proxy, version, nil
+		}
+	}
+}
+
+// messageName returns the type of 'ev' if it is an XDND message, and "" if
+// it isn't.
+func messageName(xu *xgbutil.XUtil, ev xevent.ClientMessageEvent) string {
+	if ev.Format != 32 {
+		return ""
+	}
+	name, err := xprop.AtomName(xu, ev.Type)
+	if err != nil || len(name) < 4 || name[:4] != "Xdnd" {
+		return ""
+	}
+	return name
+}
+
+// atomName returns the name of 'atom', or "" if 'atom' is 0 or invalid.
+func atomName(xu *xgbutil.XUtil, atom uint32) string {
+	if atom == 0 {
+		return ""
+	}
+	name, err := xprop.AtomName(xu, xproto.Atom(atom))
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// atom returns the atom for 'name', or 0 if 'name' is "".
+func atom(xu *xgbutil.XUtil, name string) (int, error) {
+	if len(name) == 0 {
+		return 0, nil
+	}
+	a, err := xprop.Atm(xu, name)
+	if err != nil {
+		return 0, fmt.Errorf("atom: Could not get atom '%s': %s", name, err)
+	}
+	return int(a), nil
+}
+
+// packPoint packs a pair of 16 bit coordinates as XDND does.
+func packPoint(x, y int) int {
+	return int(uint32(uint16(x))<<16 | uint32(uint16(y)))
+}
+
+// unpackPoint is the inverse of packPoint.
+func unpackPoint(v uint32) (x, y int) {
+	return int(int16(v >> 16)), int(int16(v & 0xffff))
+}
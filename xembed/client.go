@@ -0,0 +1,229 @@
+package xembed
+
+/*
+xembed/client.go contains the client side of XEmbed. The client is a window
+that gets embedded into a socket owned by another application, and is told
+by its embedder when it is activated, focused and so on.
+*/
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// ClientHandlers are the functions called by a Client in response to its
+// embedder. Any of them may be nil.
+//
+// Embedded is called when the client has been embedded, and Unembedded when
+// it has been reparented out of its embedder (or its embedder was
+// destroyed).
+//
+// Activate is called when the top-level window of the embedder is activated
+// ('active' is true) or deactivated, and Focus when the client gets ('in' is
+// true) or loses the focus. 'detail' is FocusCurrent, FocusFirst or FocusLast
+// when the client gets the focus. Modality is called when the embedder shows
+// ('on' is true) or hides a modal dialog. Accelerator is called when one of
+// the client's accelerators was pressed in the embedder.
+type ClientHandlers struct {
+	Embedded    func(c *Client)
+	Unembedded  func(c *Client)
+	Activate    func(c *Client, active bool)
+	Focus       func(c *Client, in bool, detail uint32)
+	Modality    func(c *Client, on bool)
+	Accelerator func(c *Client, id uint32, overloaded bool)
+}
+
+// Client is a window that can be embedded with XEmbed. Use NewClient to
+// create one.
+type Client struct {
+	Win *xwindow.Window
+
+	// Embedder is the socket window the client is embedded in, or 0.
+	Embedder xproto.Window
+
+	// Version is the XEmbed version used with the embedder.
+	Version uint
+
+	handlers ClientHandlers
+	mapped   bool
+	done     bool
+
+	// callbacks attached with xevent.Attach to the client window
+	msgFun      xevent.ClientMessageFun
+	reparentFun xevent.ReparentNotifyFun
+}
+
+// NewClient sets _XEMBED_INFO on 'win' (with the mapped flag set if 'mapped'
+// is true) and starts listening for messages from an embedder. The embedder
+// usually learns about 'win' through some other protocol (e.g., the system
+// tray protocol, or by passing the window id on a command line), but the
+// client can also embed itself with EmbedInto.
+//
+// A Client relies on xgbutil's main event loop (see xevent.Main).
+func NewClient(win *xwindow.Window, mapped bool,
+	handlers ClientHandlers) (*Client, error) {
+
+	c := &Client{Win: win, handlers: handlers, mapped: mapped}
+	if err := c.infoSet(); err != nil {
+		return nil, err
+	}
+	if err := win.ListenAdd(xproto.EventMaskStructureNotify); err != nil {
+		return nil, err
+	}
+	c.connect()
+	return c, nil
+}
+
+// Embedded returns whether the client is embedded.
+func (c *Client) Embedded() bool {
+	return c.Embedder != 0
+}
+
+// EmbedInto reparents the client into the socket 'socket' of an embedder
+// that expects it. The embedder then sends XEMBED_EMBEDDED_NOTIFY.
+func (c *Client) EmbedInto(socket xproto.Window) error {
+	return xproto.ReparentWindowChecked(c.Win.X.Conn(), c.Win.Id, socket,
+		0, 0).Check()
+}
+
+// Show asks the embedder to map the client.
+func (c *Client) Show() error {
+	c.mapped = true
+	return c.infoSet()
+}
+
+// Hide asks the embedder to unmap the client.
+func (c *Client) Hide() error {
+	c.mapped = false
+	return c.infoSet()
+}
+
+// RequestFocus asks the embedder for the focus.
+func (c *Client) RequestFocus() error {
+	return c.send(RequestFocus, 0, 0, 0)
+}
+
+// FocusNext asks the embedder to move the focus to whatever comes after the
+// client (e.g., when the user tabs out of the last widget in the client).
+func (c *Client) FocusNext() error {
+	return c.send(FocusNext, 0, 0, 0)
+}
+
+// FocusPrev asks the embedder to move the focus to whatever comes before the
+// client.
+func (c *Client) FocusPrev() error {
+	return c.send(FocusPrev, 0, 0, 0)
+}
+
+// RegisterAccelerator asks the embedder to tell the client when the key
+// combination 'keysym' with 'mods' is pressed, using 'id' to identify it.
+func (c *Client) RegisterAccelerator(id uint32, keysym xproto.Keysym,
+	mods uint16) error {
+
+	return c.send(RegisterAccelerator, id, uint32(keysym), uint32(mods))
+}
+
+// UnregisterAccelerator removes the accelerator 'id'.
+func (c *Client) UnregisterAccelerator(id uint32) error {
+	return c.send(UnregisterAccelerator, id, 0, 0)
+}
+
+// Stop stops listening for messages from the embedder.
+func (c *Client) Stop() {
+	if c.done {
+		return
+	}
+	c.done = true
+
+	X := c.Win.X
+	xevent.Disconnect(X, xevent.ClientMessage, c.Win.Id, &c.msgFun)
+	xevent.Disconnect(X, xevent.ReparentNotify, c.Win.Id, &c.reparentFun)
+}
+
+// infoSet writes _XEMBED_INFO.
+func (c *Client) infoSet() error {
+	info := &Info{Version: Version}
+	if c.mapped {
+		info.Flags |= FlagMapped
+	}
+	return InfoSet(c.Win.X, c.Win.Id, info)
+}
+
+// send sends an _XEMBED message to the embedder.
+func (c *Client) send(message, detail, data1, data2 uint32) error {
+	if c.Embedder == 0 {
+		return fmt.Errorf("send: The client %x is not embedded.", c.Win.Id)
+	}
+	return Send(c.Win.X, c.Embedder, message, detail, data1, data2)
+}
+
+// connect attaches the event handlers for messages from the embedder and
+// for the client being reparented.
+func (c *Client) connect() {
+	X := c.Win.X
+
+	c.msgFun = func(X *xgbutil.XUtil, ev xevent.ClientMessageEvent) {
+		if c.done {
+			return
+		}
+		if msg, ok := Decode(X, ev); ok {
+			c.message(msg)
+		}
+	}
+	xevent.Attach(X, xevent.ClientMessage, c.Win.Id, &c.msgFun)
+
+	c.reparentFun = func(X *xgbutil.XUtil, ev xevent.ReparentNotifyEvent) {
+		if c.done || ev.Window != c.Win.Id || c.Embedder == 0 ||
+			ev.Parent == c.Embedder {
+
+			return
+		}
+		c.unembedded()
+	}
+	xevent.Attach(X, xevent.ReparentNotify, c.Win.Id, &c.reparentFun)
+}
+
+// unembedded records that the client is no longer embedded.
+func (c *Client) unembedded() {
+	c.Embedder = 0
+	if c.handlers.Unembedded != nil {
+		c.handlers.Unembedded(c)
+	}
+}
+
+// message handles an _XEMBED message from the embedder.
+func (c *Client) message(msg Message) {
+	h := c.handlers
+	switch msg.Message {
+	case EmbeddedNotify:
+		c.Embedder = xproto.Window(msg.Data1)
+		c.Version = uint(msg.Data2)
+		if c.Version > Version {
+			c.Version = Version
+		}
+		if h.Embedded != nil {
+			h.Embedded(c)
+		}
+	case WindowActivate, WindowDeactivate:
+		if h.Activate != nil {
+			h.Activate(c, msg.Message == WindowActivate)
+		}
+	case FocusIn, FocusOut:
+		if h.Focus != nil {
+			h.Focus(c, msg.Message == FocusIn, msg.Detail)
+		}
+	case ModalityOn, ModalityOff:
+		if h.Modality != nil {
+			h.Modality(c, msg.Message == ModalityOn)
+		}
+	case ActivateAccelerator:
+		if h.Accelerator != nil {
+			h.Accelerator(c, msg.Detail, msg.Data1&AcceleratorOverloaded > 0)
+		}
+	}
+}
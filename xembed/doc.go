@@ -0,0 +1,60 @@
+/*
+Package xembed implements the XEmbed protocol, which is used to embed a window
+of one application (the client) into a window of another (the embedder). It
+is what tray icons, plugs and sockets in toolkits, and "swallowing" panels
+use.
+
+The protocol is described here:
+http://standards.freedesktop.org/xembed-spec/xembed-spec-latest.html
+
+In short, the embedder reparents the client into one of its own windows (the
+socket) and sends it an XEMBED_EMBEDDED_NOTIFY message. The client says
+whether it wants to be mapped with its _XEMBED_INFO property. After that,
+the embedder tells the client when its top-level window is activated and when
+it has the focus, and forwards key events to it, since the client never has
+the X input focus itself. The client can ask for the focus and register
+accelerators (key combinations it wants to be told about).
+
+Embedders
+
+An embedder creates a socket window and embeds a client window in it:
+
+	socket, _ := xwindow.Generate(X)
+	socket.Create(parent.Id, 0, 0, 200, 100, 0)
+	socket.Map()
+
+	e, err := xembed.Embed(socket, clientWin, xembed.EmbedderHandlers{
+		RequestFocus: func(e *xembed.Embedder) {
+			e.FocusIn(xembed.FocusCurrent)
+		},
+		Gone: func(e *xembed.Embedder) {
+			socket.Destroy()
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+Key events received by the embedder while the client has the focus should be
+passed on with ForwardKeyPress and ForwardKeyRelease.
+
+Clients
+
+A client sets _XEMBED_INFO on its window and waits to be embedded:
+
+	c, err := xembed.NewClient(win, true, xembed.ClientHandlers{
+		Embedded: func(c *xembed.Client) {
+			log.Printf("Embedded into %x", c.Embedder)
+		},
+		Focus: func(c *xembed.Client, in bool, detail uint32) {
+			focused = in
+		},
+	})
+
+The embedder usually learns about the client window through another protocol
+(the system tray protocol, or a window id passed on the command line). A
+client can also reparent itself into a known socket with EmbedInto.
+
+Both roles rely on xgbutil's main event loop (see xevent.Main).
+*/
+package xembed
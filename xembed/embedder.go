@@ -0,0 +1,342 @@
+package xembed
+
+/*
+xembed/embedder.go contains the embedder side of XEmbed. The embedder owns a
+window (the socket) that the client window is reparented into, tells the
+client about activation and focus, and forwards key events to it.
+*/
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// Accelerator is a key combination registered by a client. When the embedder
+// sees it, it should call Embedder.ActivateAccelerator.
+type Accelerator struct {
+	Keysym    xproto.Keysym
+	Modifiers uint16
+}
+
+// EmbedderHandlers are the functions called by an Embedder in response to
+// its client. Any of them may be nil.
+//
+// Map is called when the client maps or unmaps itself by changing
+// _XEMBED_INFO, and Gone when the client window is destroyed or reparented
+// elsewhere. The Embedder is no longer usable after Gone.
+//
+// RequestFocus, FocusNext and FocusPrev are called when the client asks for
+// the focus, or wants the focus to move to the next or previous widget in
+// the embedder. Accelerator is called when the client registers ('add' is
+// true) or unregisters an accelerator.
+type EmbedderHandlers struct {
+	Map          func(e *Embedder, mapped bool)
+	Gone         func(e *Embedder)
+	RequestFocus func(e *Embedder)
+	FocusNext    func(e *Embedder)
+	FocusPrev    func(e *Embedder)
+	Accelerator  func(e *Embedder, id uint32, acc Accelerator, add bool)
+}
+
+// Embedder is a client window embedded into a socket window. Use Embed to
+// create one.
+type Embedder struct {
+	Socket *xwindow.Window
+	Client *xwindow.Window
+
+	// Version is the XEmbed version used with the client.
+	Version uint
+
+	// Mapped is whether the client wants to be mapped.
+	Mapped bool
+
+	handlers     EmbedderHandlers
+	accelerators map[uint32]Accelerator
+	done         bool
+
+	// callbacks attached with xevent.Attach to the socket and client window
+	msgFun      xevent.ClientMessageFun
+	destroyFun  xevent.DestroyNotifyFun
+	reparentFun xevent.ReparentNotifyFun
+	propFun     xevent.PropertyNotifyFun
+
+	// oldMask is the event mask we had selected on the client window before
+	// it was embedded, which is restored by Unembed.
+	oldMask uint32
+}
+
+// Embed reparents the window 'client' into 'socket' at (0, 0), tells the
+// client that it has been embedded and maps it if its _XEMBED_INFO says so.
+// (A window without _XEMBED_INFO is treated as a mapped XEmbed client.)
+// The client is added to the save-set, so that it survives if the embedder
+// crashes.
+//
+// Since messages from the client are sent to the socket, there should be
+// only one client for each socket. An Embedder relies on xgbutil's main event
+// loop (see xevent.Main).
+func Embed(socket *xwindow.Window, client xproto.Window,
+	handlers EmbedderHandlers) (*Embedder, error) {
+
+	X := socket.X
+	e := &Embedder{
+		Socket:       socket,
+		Client:       xwindow.New(X, client),
+		Mapped:       true,
+		handlers:     handlers,
+		accelerators: make(map[uint32]Accelerator),
+	}
+	if info, err := InfoGet(X, client); err == nil {
+		e.Mapped = info.Mapped()
+		if info.Version < Version {
+			e.Version = info.Version
+		} else {
+			e.Version = Version
+		}
+	}
+
+	attrs, err := xproto.GetWindowAttributes(X.Conn(), client).Reply()
+	if err != nil {
+		return nil, fmt.Errorf("Embed: Could not embed %x: %s", client, err)
+	}
+	e.oldMask = attrs.YourEventMask
+	err = e.Client.ListenAdd(xproto.EventMaskStructureNotify,
+		xproto.EventMaskPropertyChange)
+	if err != nil {
+		return nil, fmt.Errorf("Embed: Could not embed %x: %s", client, err)
+	}
+	xproto.ChangeSaveSet(X.Conn(), xproto.SetModeInsert, client)
+	err = xproto.ReparentWindowChecked(X.Conn(), client, socket.Id,
+		0, 0).Check()
+	if err != nil {
+		return nil, fmt.Errorf("Embed: Could not embed %x: %s", client, err)
+	}
+
+	err = Send(X, client, EmbeddedNotify, 0, uint32(socket.Id),
+		uint32(e.Version))
+	if err != nil {
+		xgbutil.Logger.Printf("Embed: Could not send "+
+			"XEMBED_EMBEDDED_NOTIFY to %x: %s", client, err)
+	}
+
+	e.connect()
+	if e.Mapped {
+		e.Client.Map()
+	}
+	return e, nil
+}
+
+// Accelerators returns the accelerators registered by the client, keyed by
+// their ids.
+func (e *Embedder) Accelerators() map[uint32]Accelerator {
+	return e.accelerators
+}
+
+// Activate tells the client that the top-level window containing the socket
+// has been activated ('active' is true) or deactivated.
+func (e *Embedder) Activate(active bool) error {
+	if active {
+		return e.send(WindowActivate, 0, 0, 0)
+	}
+	return e.send(WindowDeactivate, 0, 0, 0)
+}
+
+// FocusIn tells the client that it has the focus. 'detail' is FocusCurrent,
+// FocusFirst or FocusLast, and says which widget in the client should get
+// it. The embedder keeps the X input focus, and should forward key events to
+// the client with ForwardKeyPress and ForwardKeyRelease.
+func (e *Embedder) FocusIn(detail uint32) error {
+	return e.send(FocusIn, detail, 0, 0)
+}
+
+// FocusOut tells the client that it has lost the focus.
+func (e *Embedder) FocusOut() error {
+	return e.send(FocusOut, 0, 0, 0)
+}
+
+// Modality tells the client that a modal dialog has been shown ('on' is
+// true) or hidden by the embedder.
+func (e *Embedder) Modality(on bool) error {
+	if on {
+		return e.send(ModalityOn, 0, 0, 0)
+	}
+	return e.send(ModalityOff, 0, 0, 0)
+}
+
+// ActivateAccelerator tells the client that its accelerator 'id' was
+// pressed. 'overloaded' should be true if the key combination is also used
+// by the embedder.
+func (e *Embedder) ActivateAccelerator(id uint32, overloaded bool) error {
+	flags := uint32(0)
+	if overloaded {
+		flags = AcceleratorOverloaded
+	}
+	return e.send(ActivateAccelerator, id, flags, 0)
+}
+
+// ForwardKeyPress sends a key press received by the embedder to the client.
+func (e *Embedder) ForwardKeyPress(ev xproto.KeyPressEvent) error {
+	ev.Event = e.Client.Id
+	ev.Child = 0
+	return e.forward(ev.Bytes())
+}
+
+// ForwardKeyRelease sends a key release received by the embedder to the
+// client.
+func (e *Embedder) ForwardKeyRelease(ev xproto.KeyReleaseEvent) error {
+	ev.Event = e.Client.Id
+	ev.Child = 0
+	return e.forward(ev.Bytes())
+}
+
+// Unembed gives the client window back to the root window: it is unmapped,
+// reparented to the root window and removed from the save-set, and the events
+// we select on it are put back to what they were before Embed. The Embedder
+// is no longer usable afterwards.
+func (e *Embedder) Unembed() {
+	if e.done {
+		return
+	}
+	e.stop()
+
+	X, client := e.Socket.X, e.Client.Id
+	xproto.ChangeWindowAttributes(X.Conn(), client, xproto.CwEventMask,
+		[]uint32{e.oldMask})
+	e.Client.Unmap()
+	xproto.ReparentWindow(X.Conn(), client, X.RootWin(), 0, 0)
+	xproto.ChangeSaveSet(X.Conn(), xproto.SetModeDelete, client)
+}
+
+// send sends an _XEMBED message to the client.
+func (e *Embedder) send(message, detail, data1, data2 uint32) error {
+	if e.done {
+		return fmt.Errorf("send: The client %x is no longer embedded.",
+			e.Client.Id)
+	}
+	return Send(e.Socket.X, e.Client.Id, message, detail, data1, data2)
+}
+
+// forward sends the raw event 'ev' to the client.
+func (e *Embedder) forward(ev []byte) error {
+	if e.done {
+		return fmt.Errorf("forward: The client %x is no longer embedded.",
+			e.Client.Id)
+	}
+	return xproto.SendEventChecked(e.Socket.X.Conn(), false, e.Client.Id,
+		xproto.EventMaskNoEvent, string(ev)).Check()
+}
+
+// stop removes the embedder's event handlers from the socket and the client
+// window.
+func (e *Embedder) stop() {
+	e.done = true
+
+	X, client := e.Socket.X, e.Client.Id
+	xevent.Disconnect(X, xevent.ClientMessage, e.Socket.Id, &e.msgFun)
+	xevent.Disconnect(X, xevent.DestroyNotify, client, &e.destroyFun)
+	xevent.Disconnect(X, xevent.ReparentNotify, client, &e.reparentFun)
+	xevent.Disconnect(X, xevent.PropertyNotify, client, &e.propFun)
+}
+
+// gone is called when the client window goes away.
+func (e *Embedder) gone() {
+	if e.done {
+		return
+	}
+	e.stop()
+	if e.handlers.Gone != nil {
+		e.handlers.Gone(e)
+	}
+}
+
+// connect attaches the event handlers for messages from the client and for
+// changes to the client window.
+func (e *Embedder) connect() {
+	X, client := e.Socket.X, e.Client.Id
+
+	e.msgFun = func(X *xgbutil.XUtil, ev xevent.ClientMessageEvent) {
+		if e.done {
+			return
+		}
+		if msg, ok := Decode(X, ev); ok {
+			e.message(msg)
+		}
+	}
+	xevent.Attach(X, xevent.ClientMessage, e.Socket.Id, &e.msgFun)
+
+	e.destroyFun = func(X *xgbutil.XUtil, ev xevent.DestroyNotifyEvent) {
+		e.gone()
+	}
+	xevent.Attach(X, xevent.DestroyNotify, client, &e.destroyFun)
+
+	e.reparentFun = func(X *xgbutil.XUtil, ev xevent.ReparentNotifyEvent) {
+		if ev.Window == client && ev.Parent != e.Socket.Id {
+			e.gone()
+		}
+	}
+	xevent.Attach(X, xevent.ReparentNotify, client, &e.reparentFun)
+
+	e.propFun = func(X *xgbutil.XUtil, ev xevent.PropertyNotifyEvent) {
+		name, err := xprop.AtomName(X, ev.Atom)
+		if err != nil || name != "_XEMBED_INFO" {
+			return
+		}
+		info, err := InfoGet(X, client)
+		mapped := err == nil && info.Mapped()
+		if mapped == e.Mapped {
+			return
+		}
+		e.Mapped = mapped
+		if mapped {
+			e.Client.Map()
+		} else {
+			e.Client.Unmap()
+		}
+		if e.handlers.Map != nil {
+			e.handlers.Map(e, mapped)
+		}
+	}
+	xevent.Attach(X, xevent.PropertyNotify, client, &e.propFun)
+}
+
+// message handles an _XEMBED message from the client.
+func (e *Embedder) message(msg Message) {
+	h := e.handlers
+	switch msg.Message {
+	case RequestFocus:
+		if h.RequestFocus != nil {
+			h.RequestFocus(e)
+		}
+	case FocusNext:
+		if h.FocusNext != nil {
+			h.FocusNext(e)
+		}
+	case FocusPrev:
+		if h.FocusPrev != nil {
+			h.FocusPrev(e)
+		}
+	case RegisterAccelerator:
+		acc := Accelerator{
+			Keysym:    xproto.Keysym(msg.Data1),
+			Modifiers: uint16(msg.Data2),
+		}
+		e.accelerators[msg.Detail] = acc
+		if h.Accelerator != nil {
+			h.Accelerator(e, msg.Detail, acc, true)
+		}
+	case UnregisterAccelerator:
+		acc, ok := e.accelerators[msg.Detail]
+		if !ok {
+			return
+		}
+		delete(e.accelerators, msg.Detail)
+		if h.Accelerator != nil {
+			h.Accelerator(e, msg.Detail, acc, false)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package xembed
+
+import (
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// Version is the version of the XEmbed protocol implemented by this package.
+const Version = 0
+
+// Flags in _XEMBED_INFO.
+const (
+	FlagMapped = 1 << 0
+)
+
+// _XEMBED messages.
+const (
+	EmbeddedNotify        = 0
+	WindowActivate        = 1
+	WindowDeactivate      = 2
+	RequestFocus          = 3
+	FocusIn               = 4
+	FocusOut              = 5
+	FocusNext             = 6
+	FocusPrev             = 7
+	ModalityOn            = 10
+	ModalityOff           = 11
+	RegisterAccelerator   = 12
+	UnregisterAccelerator = 13
+	ActivateAccelerator   = 14
+)
+
+// Details of FocusIn messages.
+const (
+	FocusCurrent = 0
+	FocusFirst   = 1
+	FocusLast    = 2
+)
+
+// Flags of ActivateAccelerator messages.
+const (
+	AcceleratorOverloaded = 1 << 0
+)
+
+// Info is the value of _XEMBED_INFO: the XEmbed version supported by a client
+// and its flags.
+type Info struct {
+	Version uint
+	Flags   uint
+}
+
+// Mapped returns whether the client wants to be mapped.
+func (info *Info) Mapped() bool {
+	return info.Flags&FlagMapped > 0
+}
+
+// _XEMBED_INFO get
+func InfoGet(xu *xgbutil.XUtil, win xproto.Window) (*Info, error) {
+	raw, err := xprop.PropValNums(xprop.GetProperty(xu, win, "_XEMBED_INFO"))
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{}
+	if len(raw) > 0 {
+		info.Version = raw[0]
+	}
+	if len(raw) > 1 {
+		info.Flags = raw[1]
+	}
+	return info, nil
+}
+
+// _XEMBED_INFO set
+func InfoSet(xu *xgbutil.XUtil, win xproto.Window, info *Info) error {
+	return xprop.ChangeProp32(xu, win, "_XEMBED_INFO", "_XEMBED_INFO",
+		info.Version, info.Flags)
+}
+
+// Message is a decoded _XEMBED client message.
+type Message struct {
+	Time         xproto.Timestamp
+	Message      uint32
+	Detail       uint32
+	Data1, Data2 uint32
+}
+
+// Decode returns the _XEMBED message in 'ev', if it is one.
+func Decode(xu *xgbutil.XUtil, ev xevent.ClientMessageEvent) (Message, bool) {
+	if ev.Format != 32 {
+		return Message{}, false
+	}
+	name, err := xprop.AtomName(xu, ev.Type)
+	if err != nil || name != "_XEMBED" {
+		return Message{}, false
+	}
+
+	d := ev.Data.Data32
+	return Message{
+		Time:    xproto.Timestamp(d[0]),
+		Message: d[1],
+		Detail:  d[2],
+		Data1:   d[3],
+		Data2:   d[4],
+	}, true
+}
+
+// Send sends an _XEMBED message to 'win', timestamped with the time of the
+// last event. As required by XEmbed, no event mask is used, so only the
+// client that created 'win' receives it.
+func Send(xu *xgbutil.XUtil, win xproto.Window,
+	message, detail, data1, data2 uint32) error {
+
+	return xevent.SendClientMessage(xu, win, win, "_XEMBED",
+		int(xu.TimeGet()), int(message), int(detail), int(data1), int(data2))
+}
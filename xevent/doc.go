@@ -21,6 +21,10 @@ in the documentation for the MainPing function. A complete example called
 multiple-source-event-loop can also be found in the examples directory of the
 xgbutil package.
 
+Timeouts can be run by the main event loop too, with xevent.AfterFunc. Unlike
+the function given to time.AfterFunc, the function given to xevent.AfterFunc
+runs in between event handlers, so it may share their state without locking.
+
 To quit the main event loop, you may use xevent.Quit, but there is nothing
 inherently wrong with stopping dead using os.Exit. xevent.Quit is provided for
 your convenience should you need to run any clean-up code after the main event
@@ -58,6 +62,10 @@ from all possible event handlers in xgbutil, use something like:
 	keybind.Detach(XUtilValue, your-window-id)
 	mousebind.Detach(XUtilValue, your-window-id)
 
+When several parts of a program attach callbacks to the same window (for
+example, a window owned by another client), a single callback can be removed
+with xevent.Disconnect instead, if it was attached with xevent.Attach.
+
 Quick example
 
 A small example that shows how to respond to ConfigureNotify events sent to
@@ -0,0 +1,93 @@
+package xevent
+
+/*
+xevent/timer.go contains timers whose functions are run by the main event
+loop, rather than in a goroutine of their own like the functions given to
+time.AfterFunc. This means that they may touch the same state as event
+handlers without any locking.
+
+When a timer expires, a client message is sent to the dummy window of the
+XUtil value, which wakes up the main event loop. The message is then
+dispatched to the timer like any other event.
+*/
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// timerAtom is the type of the client messages sent when a timer expires.
+const timerAtom = "_XGBUTIL_TIMER"
+
+// timerIds is the id of the last timer created. Each timer's messages carry
+// its id, so that timers can tell their messages apart.
+var timerIds uint32
+
+// Timer is a single timeout created with AfterFunc.
+type Timer struct {
+	xu  *xgbutil.XUtil
+	id  uint32
+	fun ClientMessageFun
+
+	// timer is set after the callback is attached, so it's guarded by lck in
+	// case AfterFunc is called off the main event loop and the callback
+	// runs first.
+	lck   *sync.Mutex
+	timer *time.Timer
+}
+
+// AfterFunc waits for the duration 'd' to elapse and then has the main event
+// loop of 'xu' (see Main) call 'f', in between event handlers. It returns a
+// Timer that can be used to cancel the call with its Stop method.
+func AfterFunc(xu *xgbutil.XUtil, d time.Duration, f func()) *Timer {
+	t := &Timer{
+		xu:  xu,
+		id:  atomic.AddUint32(&timerIds, 1),
+		lck: &sync.Mutex{},
+	}
+	t.fun = func(xu *xgbutil.XUtil, ev ClientMessageEvent) {
+		if ev.Format != 32 || ev.Data.Data32[0] != t.id {
+			return
+		}
+		if atom, err := xprop.Atm(xu, timerAtom); err != nil ||
+			ev.Type != atom {
+
+			return
+		}
+		t.Stop()
+		f()
+	}
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	Attach(xu, ClientMessage, xu.Dummy(), &t.fun)
+	t.timer = time.AfterFunc(d, t.expire)
+	return t
+}
+
+// Stop cancels the timer. When called from the main event loop (i.e., from
+// an event handler or another timer), it is guaranteed that the timer's
+// function won't be called, even if the timer has already expired.
+// Stopping a timer more than once does nothing.
+func (t *Timer) Stop() {
+	t.lck.Lock()
+	t.timer.Stop()
+	t.lck.Unlock()
+
+	Disconnect(t.xu, ClientMessage, t.xu.Dummy(), &t.fun)
+}
+
+// expire runs in the goroutine of the time.Timer, and wakes up the main
+// event loop by sending a client message to the dummy window.
+func (t *Timer) expire() {
+	err := SendClientMessage(t.xu, t.xu.Dummy(), t.xu.Dummy(), timerAtom,
+		int(t.id))
+	if err != nil {
+		xgbutil.Logger.Printf("Could not wake up the event loop for a "+
+			"timer: %s", err)
+	}
+}
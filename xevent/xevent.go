@@ -1,10 +1,13 @@
 package xevent
 
 import (
+	"reflect"
+
 	"github.com/BurntSushi/xgb"
 	"github.com/BurntSushi/xgb/xproto"
 
 	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xprop"
 )
 
 // Sometimes we need to specify NO WINDOW when a window is typically
@@ -110,15 +113,7 @@ func ErrorHandlerGet(xu *xgbutil.XUtil) xgbutil.ErrorHandlerFun {
 type HookFun func(xu *xgbutil.XUtil, event interface{}) bool
 
 func (callback HookFun) Connect(xu *xgbutil.XUtil) {
-	xu.HooksLck.Lock()
-	defer xu.HooksLck.Unlock()
-
-	// COW
-	newHooks := make([]xgbutil.CallbackHook, len(xu.Hooks))
-	copy(newHooks, xu.Hooks)
-	newHooks = append(newHooks, callback)
-
-	xu.Hooks = newHooks
+	AttachHook(xu, callback)
 }
 
 func (callback HookFun) Run(xu *xgbutil.XUtil, event interface{}) bool {
@@ -132,6 +127,39 @@ func getHooks(xu *xgbutil.XUtil) []xgbutil.CallbackHook {
 	return xu.Hooks
 }
 
+// AttachHook connects 'hook' to the main event loop, just like the Connect
+// method of HookFun does. Like Attach, it exists so that the hook can later be
+// removed with DisconnectHook, in which case 'hook' should be a pointer (e.g.,
+// to a HookFun).
+func AttachHook(xu *xgbutil.XUtil, hook xgbutil.CallbackHook) {
+	xu.HooksLck.Lock()
+	defer xu.HooksLck.Unlock()
+
+	// COW
+	newHooks := make([]xgbutil.CallbackHook, len(xu.Hooks))
+	copy(newHooks, xu.Hooks)
+	newHooks = append(newHooks, hook)
+
+	xu.Hooks = newHooks
+}
+
+// DisconnectHook removes 'hook', attached with AttachHook, from the main
+// event loop. A 'hook' that isn't a pointer (or some other comparable value)
+// matches nothing, and nothing is removed.
+func DisconnectHook(xu *xgbutil.XUtil, hook xgbutil.CallbackHook) {
+	xu.HooksLck.Lock()
+	defer xu.HooksLck.Unlock()
+
+	// COW
+	newHooks := make([]xgbutil.CallbackHook, 0, len(xu.Hooks))
+	for _, h := range xu.Hooks {
+		if !sameValue(h, hook) {
+			newHooks = append(newHooks, h)
+		}
+	}
+	xu.Hooks = newHooks
+}
+
 // RedirectKeyEvents, when set to a window id (greater than 0), will force
 // *all* Key{Press,Release} to callbacks attached to the specified window.
 // This is close to emulating a Keyboard grab without the racing.
@@ -224,6 +252,59 @@ func Detach(xu *xgbutil.XUtil, win xproto.Window) {
 	}
 }
 
+// Attach associates a callback with events of the type 'evtype' (e.g.,
+// xevent.PropertyNotify) on 'win', just like the Connect method of the
+// callback types does. It exists so that a callback can later be removed on
+// its own with Disconnect, without removing every other callback on 'win'.
+// Since functions can't be compared in Go, 'fun' should be a pointer to a
+// callback value whose type matches 'evtype':
+//
+//	propFun := xevent.PropertyNotifyFun(...)
+//	xevent.Attach(XUtilValue, xevent.PropertyNotify, win, &propFun)
+//	...
+//	xevent.Disconnect(XUtilValue, xevent.PropertyNotify, win, &propFun)
+func Attach(xu *xgbutil.XUtil, evtype int, win xproto.Window,
+	fun xgbutil.Callback) {
+
+	attachCallback(xu, evtype, win, fun)
+}
+
+// Disconnect removes the callback 'fun', attached with Attach, from the
+// callbacks for events of the type 'evtype' on 'win'. Other callbacks on
+// 'win' are left alone. A 'fun' that isn't a pointer (or some other
+// comparable value) matches nothing, and nothing is removed.
+func Disconnect(xu *xgbutil.XUtil, evtype int, win xproto.Window,
+	fun xgbutil.Callback) {
+
+	xu.CallbacksLck.Lock()
+	defer xu.CallbacksLck.Unlock()
+
+	// COW, as in attachCallback.
+	cbs := xu.Callbacks[evtype][win]
+	newCallbacks := make([]xgbutil.Callback, 0, len(cbs))
+	for _, cb := range cbs {
+		if !sameValue(cb, fun) {
+			newCallbacks = append(newCallbacks, cb)
+		}
+	}
+	if len(newCallbacks) == 0 {
+		delete(xu.Callbacks[evtype], win)
+	} else {
+		xu.Callbacks[evtype][win] = newCallbacks
+	}
+}
+
+// sameValue returns whether 'a' and 'b' are the same callback. Comparing two
+// interface values with the same uncomparable dynamic type (like a function
+// type) panics, so such values are never the same.
+func sameValue(a, b interface{}) bool {
+	t := reflect.TypeOf(a)
+	if t != reflect.TypeOf(b) || t == nil || !t.Comparable() {
+		return false
+	}
+	return a == b
+}
+
 // SendRootEvent takes a type implementing the xgb.Event interface, converts it
 // to raw X bytes, and sends it to the root window using the SendEvent request.
 func SendRootEvent(xu *xgbutil.XUtil, ev xgb.Event, evMask uint32) error {
@@ -231,6 +312,26 @@ func SendRootEvent(xu *xgbutil.XUtil, ev xgb.Event, evMask uint32) error {
 		string(ev.Bytes())).Check()
 }
 
+// SendClientMessage sends a 32 bit client message of the type 'typ' (an atom
+// name) about the window 'win' to the window 'dest'. Unlike SendRootEvent, no
+// event mask is used, so only the client that created 'dest' receives it.
+// This is how most protocols between clients (and the WM_PROTOCOLS messages
+// of the ICCCM) are sent.
+func SendClientMessage(xu *xgbutil.XUtil, dest, win xproto.Window, typ string,
+	data ...interface{}) error {
+
+	typAtom, err := xprop.Atm(xu, typ)
+	if err != nil {
+		return err
+	}
+	cm, err := NewClientMessage(32, win, typAtom, data...)
+	if err != nil {
+		return err
+	}
+	return xproto.SendEventChecked(xu.Conn(), false, dest,
+		xproto.EventMaskNoEvent, string(cm.Bytes())).Check()
+}
+
 // ReplayPointer is a quick alias to AllowEvents with 'ReplayPointer' mode.
 func ReplayPointer(xu *xgbutil.XUtil) {
 	xproto.AllowEvents(xu.Conn(), xproto.AllowReplayPointer, 0)
@@ -0,0 +1,70 @@
+/*
+Package xresource provides a parser and query interface for the X resource
+manager database. This is the database populated by programs like 'xrdb' and
+stored in the RESOURCE_MANAGER property of the root window. It is where
+settings like 'Xft.dpi' and '*background' live.
+
+Loading the database
+
+The simplest way to get at the resources currently loaded into the X server
+is with Get:
+
+	db, err := xresource.Get(XUtilValue)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+Get reads the RESOURCE_MANAGER property (and the SCREEN_RESOURCES property,
+if it exists) from the root window and parses it. A database can also be
+built from any string or file using the syntax described in the Xlib manual,
+with Parse and ParseFile. Databases can be combined with Merge.
+
+Querying
+
+Resources are looked up by providing a fully qualified name and class:
+
+	if dpi, ok := db.Query("Xft.dpi", "Xft.Dpi"); ok {
+		fmt.Println("DPI:", dpi)
+	}
+	bg, ok := db.Query("myapp.panel.background", "MyApp.Panel.Background")
+
+The name and class must have the same number of components. When several
+entries in the database match, the one that is most specific wins, according
+to the precedence rules in section 15.1 of the Xlib manual. Namely, comparing
+one level at a time from left to right:
+
+	1. An entry that matches a level with a component (a name, class or '?')
+	takes precedence over an entry that skips the level with a '*'.
+	2. A matching name takes precedence over a matching class, and a matching
+	class takes precedence over '?'.
+	3. A component preceded by a '.' takes precedence over a component
+	preceded by a '*'.
+
+Syntax
+
+Each line in a resource file is a comment (starting with '!'), an include
+directive ('#include "file"'), a resource specification or blank. A resource
+specification looks like
+
+	name.or*name*with.?.wildcards: value
+
+Values may be continued on the next line with a trailing backslash. The
+escapes '\n', '\\', '\ ' (a literal space), '\t' (a literal tab) and octal
+escapes like '\033' are recognized in values. Lines that cannot be parsed
+are ignored, just like Xlib does.
+
+Watching for changes
+
+The resource database can change while a program is running (e.g., after
+running 'xrdb -merge'). Watch will re-read the database every time the
+RESOURCE_MANAGER property changes and pass the new database to a callback:
+
+	watcher, err := xresource.Watch(XUtilValue,
+		func(X *xgbutil.XUtil, db *xresource.Database) {
+			// re-apply settings
+		})
+
+Call watcher.Detach() to stop watching. Note that Watch relies on xgbutil's
+main event loop (see xevent.Main).
+*/
+package xresource
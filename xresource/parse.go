@@ -0,0 +1,228 @@
+package xresource
+
+/*
+xresource/parse.go contains the parser for the resource file syntax described
+in section 15.1 of the Xlib manual. It is the same syntax that 'xrdb' accepts
+and that is stored in the RESOURCE_MANAGER property.
+*/
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// maxIncludeDepth bounds the nesting of '#include' directives so that a file
+// that includes itself doesn't send us into an infinite loop.
+const maxIncludeDepth = 32
+
+// Parse parses the contents of a resource file and returns a new database.
+// Lines that cannot be parsed are skipped. An error is only returned if a
+// file named in an '#include' directive cannot be read. Relative include
+// paths are resolved against the current working directory.
+func Parse(data string) (*Database, error) {
+	db := New()
+	if err := db.parse(data, "", 0); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// ParseFile reads and parses the resource file at 'path'. Relative include
+// paths inside the file are resolved against the directory containing it.
+func ParseFile(path string) (*Database, error) {
+	db := New()
+	if err := db.parseFile(path, 0); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// parseFile reads a file and adds its entries to the database.
+func (db *Database) parseFile(path string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("xresource: Includes nested too deeply at '%s'.",
+			path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("xresource: Could not read '%s': %s", path, err)
+	}
+	return db.parse(string(data), filepath.Dir(path), depth)
+}
+
+// parse adds every entry in 'data' to the database. 'dir' is the directory
+// used to resolve relative include paths, and 'depth' is the current level of
+// include nesting.
+func (db *Database) parse(data, dir string, depth int) error {
+	for len(data) > 0 {
+		var line string
+		line, data = nextLine(data)
+
+		line = strings.TrimLeft(line, " \t")
+		switch {
+		case len(line) == 0:
+		case line[0] == '!':
+		case line[0] == '#':
+			if err := db.include(line, dir, depth); err != nil {
+				return err
+			}
+		default:
+			db.parseResource(line)
+		}
+	}
+	return nil
+}
+
+// include handles a line starting with '#'. Only '#include "file"' is
+// meaningful; anything else is a left over preprocessor directive and is
+// ignored.
+func (db *Database) include(line, dir string, depth int) error {
+	line = strings.TrimLeft(line[1:], " \t")
+	if !strings.HasPrefix(line, "include") {
+		return nil
+	}
+	line = strings.TrimSpace(line[len("include"):])
+	if len(line) < 2 || line[0] != '"' {
+		return nil
+	}
+	end := strings.IndexByte(line[1:], '"')
+	if end < 0 {
+		return nil
+	}
+
+	path := line[1 : end+1]
+	if !filepath.IsAbs(path) && len(dir) > 0 {
+		path = filepath.Join(dir, path)
+	}
+	return db.parseFile(path, depth+1)
+}
+
+// nextLine splits off the next logical line from 'data'. A logical line ends
+// at a newline that isn't escaped by a backslash. The escaped newlines are
+// left in place for parseValue to remove.
+func nextLine(data string) (string, string) {
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++ // skip whatever is escaped, including a newline
+		case '\n':
+			return data[:i], data[i+1:]
+		}
+	}
+	return data, ""
+}
+
+// parseResource parses a single resource specification and adds it to the
+// database. Malformed specifications are silently dropped.
+func (db *Database) parseResource(line string) {
+	colon := strings.IndexByte(line, ':')
+	if colon < 0 {
+		return
+	}
+
+	comps, ok := parseName(strings.TrimRight(line[:colon], " \t"))
+	if !ok {
+		return
+	}
+	db.put(comps, parseValue(line[colon+1:]))
+}
+
+// parseName splits a resource name like 'xterm*vt100.background' into its
+// components and bindings.
+func parseName(name string) ([]component, bool) {
+	comps := make([]component, 0, 4)
+	loose := false
+	start := -1
+	for i := 0; i <= len(name); i++ {
+		var c byte
+		if i < len(name) {
+			c = name[i]
+		}
+
+		if i < len(name) && isNameChar(c) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+
+		// We've hit the end of a component (or the end of the name).
+		if start >= 0 {
+			comps = append(comps, component{name[start:i], loose})
+			start = -1
+			loose = false
+		}
+
+		switch {
+		case i == len(name):
+		case c == '*':
+			loose = true
+		case c == '.':
+		case c == '?':
+			comps = append(comps, component{"?", loose})
+			loose = false
+
+			// A '?' must be followed by a binding.
+			if i+1 < len(name) && name[i+1] != '.' && name[i+1] != '*' {
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+	}
+
+	// A name must have at least one component and cannot end with a
+	// binding or a '?'.
+	last := len(comps) - 1
+	if last < 0 || loose || comps[last].name == "?" {
+		return nil, false
+	}
+	return comps, true
+}
+
+// isNameChar returns whether 'c' may appear in a component name.
+func isNameChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9') || c == '_' || c == '-'
+}
+
+// parseValue removes leading whitespace from a value and interprets all
+// escape sequences in it.
+func parseValue(raw string) string {
+	raw = strings.TrimLeft(raw, " \t")
+
+	val := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c != '\\' || i+1 == len(raw) {
+			val = append(val, c)
+			continue
+		}
+
+		i++
+		switch e := raw[i]; {
+		case e == '\n':
+			// line continuation
+		case e == 'n':
+			val = append(val, '\n')
+		case e == '\\' || e == ' ' || e == '\t':
+			val = append(val, e)
+		case isOctal(e) && i+2 < len(raw) &&
+			isOctal(raw[i+1]) && isOctal(raw[i+2]):
+
+			val = append(val, (e-'0')<<6|(raw[i+1]-'0')<<3|(raw[i+2]-'0'))
+			i += 2
+		default:
+			val = append(val, '\\', e)
+		}
+	}
+	return string(val)
+}
+
+// isOctal returns whether 'c' is an octal digit.
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
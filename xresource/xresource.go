@@ -0,0 +1,312 @@
+package xresource
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// component is a single piece of a resource specification. 'loose' is true
+// when the component is preceded by a '*' binding.
+type component struct {
+	name  string
+	loose bool
+}
+
+// entry is a single resource specification and its value.
+type entry struct {
+	comps []component
+	value string
+}
+
+// Database is a set of resource specifications. The zero value is not usable;
+// use New, Parse, ParseFile or Get to create a Database.
+type Database struct {
+	entries []*entry
+
+	// index maps a normalized specification to its position in 'entries',
+	// so that a specification given twice overwrites the first value.
+	index map[string]int
+}
+
+// New returns an empty resource database.
+func New() *Database {
+	return &Database{
+		entries: make([]*entry, 0, 50),
+		index:   make(map[string]int, 50),
+	}
+}
+
+// Get reads the resource database stored in the RESOURCE_MANAGER property of
+// the root window. If the SCREEN_RESOURCES property is set on the root window,
+// its resources are merged on top. An empty database is returned if neither
+// property exists.
+func Get(xu *xgbutil.XUtil) (*Database, error) {
+	db := New()
+	for _, prop := range []string{"RESOURCE_MANAGER", "SCREEN_RESOURCES"} {
+		// A missing property just means there are no resources.
+		data, err := xprop.PropValStr(xprop.GetProperty(xu, xu.RootWin(),
+			prop))
+		if err != nil {
+			continue
+		}
+
+		other, err := Parse(data)
+		if err != nil {
+			return nil, err
+		}
+		db.Merge(other)
+	}
+	return db, nil
+}
+
+// Merge adds every entry in 'other' to this database. Entries in 'other'
+// overwrite entries in this database with the same specification.
+func (db *Database) Merge(other *Database) {
+	for _, e := range other.entries {
+		db.put(e.comps, e.value)
+	}
+}
+
+// Put adds a single resource specification (like 'xterm*background') with
+// the given value to the database. The value is used verbatim; no escape
+// sequences are interpreted. Put returns false if the specification is
+// malformed.
+func (db *Database) Put(spec, value string) bool {
+	comps, ok := parseName(strings.TrimSpace(spec))
+	if !ok {
+		return false
+	}
+	db.put(comps, value)
+	return true
+}
+
+// put adds an entry to the database, overwriting any entry with the same
+// specification.
+func (db *Database) put(comps []component, value string) {
+	key := specString(comps)
+	if i, ok := db.index[key]; ok {
+		db.entries[i] = &entry{comps, value}
+		return
+	}
+	db.index[key] = len(db.entries)
+	db.entries = append(db.entries, &entry{comps, value})
+}
+
+// Len returns the number of entries in the database.
+func (db *Database) Len() int {
+	return len(db.entries)
+}
+
+// String returns the database in resource file syntax. Parsing the result
+// yields an equivalent database.
+func (db *Database) String() string {
+	buf := bytes.NewBuffer(nil)
+	for _, e := range db.entries {
+		buf.WriteString(specString(e.comps))
+		buf.WriteString(":\t")
+		buf.WriteString(escapeValue(e.value))
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// Query looks up the value of the resource with the fully qualified 'name'
+// and 'class'. e.g., "xterm.vt100.background" and "XTerm.VT100.Background".
+// If more than one entry matches, the Xlib precedence rules are used to pick
+// the most specific one. (See the package documentation.)
+// The second return value is false if no entry matches, or if 'name' and
+// 'class' have a different number of components.
+func (db *Database) Query(name, class string) (string, bool) {
+	names, classes := strings.Split(name, "."), strings.Split(class, ".")
+	if len(names) != len(classes) {
+		return "", false
+	}
+
+	var best []int
+	var found *entry
+	for _, e := range db.entries {
+		score, ok := match(e.comps, names, classes)
+		if !ok {
+			continue
+		}
+
+		// Later entries win ties, so use >= here.
+		if best == nil || compareScores(score, best) >= 0 {
+			best = score
+			found = e
+		}
+	}
+	if found == nil {
+		return "", false
+	}
+	return found.value, true
+}
+
+// Watcher reloads the resource database when it changes. Use Watch to create
+// one, and Detach to stop it.
+type Watcher struct {
+	X   *xgbutil.XUtil
+	fun func(xu *xgbutil.XUtil, db *Database)
+
+	resAtom xproto.Atom
+	propFun xevent.PropertyNotifyFun
+}
+
+// Watch calls 'fun' with a freshly loaded database every time the
+// RESOURCE_MANAGER property on the root window changes. PropertyChange
+// events are selected on the root window without disturbing any other
+// events already selected on it.
+func Watch(xu *xgbutil.XUtil,
+	fun func(xu *xgbutil.XUtil, db *Database)) (*Watcher, error) {
+
+	resAtom, err := xprop.Atm(xu, "RESOURCE_MANAGER")
+	if err != nil {
+		return nil, err
+	}
+
+	root := xwindow.New(xu, xu.RootWin())
+	if err := root.ListenAdd(xproto.EventMaskPropertyChange); err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		X:       xu,
+		fun:     fun,
+		resAtom: resAtom,
+	}
+	w.propFun = xevent.PropertyNotifyFun(w.propertyNotify)
+	xevent.Attach(xu, xevent.PropertyNotify, xu.RootWin(), &w.propFun)
+	return w, nil
+}
+
+// Detach stops the watcher.
+func (w *Watcher) Detach() {
+	xevent.Disconnect(w.X, xevent.PropertyNotify, w.X.RootWin(), &w.propFun)
+}
+
+// propertyNotify reloads the database when RESOURCE_MANAGER changes.
+func (w *Watcher) propertyNotify(xu *xgbutil.XUtil,
+	ev xevent.PropertyNotifyEvent) {
+
+	if ev.Atom != w.resAtom {
+		return
+	}
+
+	db, err := Get(xu)
+	if err != nil {
+		xgbutil.Logger.Printf("Could not reload resources: %s", err)
+		return
+	}
+	w.fun(xu, db)
+}
+
+// Match levels are scored with the following values, added together.
+// Higher scores take precedence. Levels that are skipped by a loose binding
+// score zero.
+const (
+	scoreMatched = 100
+	scoreName    = 30
+	scoreClass   = 20
+	scoreAny     = 10
+	scoreTight   = 1
+)
+
+// match determines whether the components of an entry match the given names
+// and classes. If they do, the score of the best possible match is returned.
+// The score has one value for each level.
+func match(comps []component, names, classes []string) ([]int, bool) {
+	if len(comps) == 0 {
+		return nil, len(names) == 0
+	}
+
+	var best []int
+	c := comps[0]
+	for lvl := 0; lvl < len(names); lvl++ {
+		// A tight binding must match the very next level.
+		if lvl > 0 && !c.loose {
+			break
+		}
+
+		var kind int
+		switch c.name {
+		case names[lvl]:
+			kind = scoreName
+		case classes[lvl]:
+			kind = scoreClass
+		case "?":
+			kind = scoreAny
+		default:
+			continue
+		}
+
+		rest, ok := match(comps[1:], names[lvl+1:], classes[lvl+1:])
+		if !ok {
+			continue
+		}
+
+		score := make([]int, len(names))
+		score[lvl] = scoreMatched + kind
+		if !c.loose {
+			score[lvl] += scoreTight
+		}
+		copy(score[lvl+1:], rest)
+
+		if best == nil || compareScores(score, best) > 0 {
+			best = score
+		}
+	}
+	return best, best != nil
+}
+
+// compareScores compares two scores level by level, and returns a positive
+// number if s1 takes precedence over s2, a negative number if s2 takes
+// precedence over s1 and 0 if they are equal.
+func compareScores(s1, s2 []int) int {
+	for i := range s1 {
+		if s1[i] != s2[i] {
+			return s1[i] - s2[i]
+		}
+	}
+	return 0
+}
+
+// specString turns a list of components back into a resource specification.
+func specString(comps []component) string {
+	buf := bytes.NewBuffer(nil)
+	for i, c := range comps {
+		switch {
+		case c.loose:
+			buf.WriteByte('*')
+		case i > 0:
+			buf.WriteByte('.')
+		}
+		buf.WriteString(c.name)
+	}
+	return buf.String()
+}
+
+// escapeValue is the inverse of parseValue.
+func escapeValue(val string) string {
+	buf := bytes.NewBuffer(nil)
+	for i := 0; i < len(val); i++ {
+		switch c := val[i]; {
+		case c == '\\':
+			buf.WriteString("\\\\")
+		case c == '\n':
+			buf.WriteString("\\n")
+		case i == 0 && (c == ' ' || c == '\t'):
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
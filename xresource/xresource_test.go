@@ -0,0 +1,187 @@
+package xresource
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	testName  = "xterm.vt100.background"
+	testClass = "XTerm.VT100.Background"
+)
+
+func TestQueryPrecedence(t *testing.T) {
+	tests := []struct {
+		desc string
+		data string
+		want string // empty if nothing should match
+	}{
+		{"tight beats loose", `
+xterm*background: loose
+xterm.vt100.background: tight`, "tight"},
+		{"tight beats loose at the same level", `
+xterm.vt100.background: tight
+xterm*vt100.background: loose`, "tight"},
+		{"earlier levels decide first", `
+*vt100.background: vt100
+xterm*background: xterm`, "xterm"},
+		{"name beats class", `
+xterm.vt100.background: name
+XTerm.vt100.background: class`, "name"},
+		{"class beats ?", `
+?.vt100.background: any
+XTerm.vt100.background: class`, "class"},
+		{"? beats a skipped level", `
+*vt100.background: skipped
+?.vt100.background: any`, "any"},
+		{"? matches exactly one level", `
+?.background: any`, ""},
+		{"name beats class at the last level", `
+XTerm.VT100.background: name
+XTerm.VT100.Background: class`, "name"},
+		{"same specification overwrites", `
+*background: first
+*background: second`, "second"},
+		{"tight binding can't skip levels", `
+xterm.background: tight`, ""},
+		{"loose binding at the start", `
+*background: loose`, "loose"},
+		{"too many components", `
+xterm.vt100.background.extra: long`, ""},
+		{"last component must match", `
+xterm.vt100*foreground: fg`, ""},
+	}
+	for _, test := range tests {
+		db, err := Parse(test.data)
+		if err != nil {
+			t.Errorf("%s: %s", test.desc, err)
+			continue
+		}
+		got, ok := db.Query(testName, testClass)
+		if ok != (test.want != "") || got != test.want {
+			t.Errorf("%s: got (%q, %t), want %q", test.desc, got, ok,
+				test.want)
+		}
+	}
+}
+
+func TestQueryMismatchedClass(t *testing.T) {
+	db := New()
+	db.Put("*background", "black")
+	if v, ok := db.Query(testName, "XTerm.Background"); ok {
+		t.Errorf("got %q for a class of the wrong length", v)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	tests := []struct {
+		desc string
+		data string
+		want string
+	}{
+		{"leading whitespace", "a: \t value", "value"},
+		{"trailing whitespace", "a: value  ", "value  "},
+		{"escaped space", `a: \ value`, " value"},
+		{"escaped tab", "a: \\\tvalue", "\tvalue"},
+		{"newline", `a: one\ntwo`, "one\ntwo"},
+		{"backslash", `a: one\\two`, `one\two`},
+		{"octal", `a: \101\033`, "A\033"},
+		{"short octal", `a: \10`, `\10`},
+		{"unknown escape", `a: \q`, `\q`},
+		{"trailing backslash", `a: value\`, `value\`},
+		{"continuation", "a: one\\\ntwo", "onetwo"},
+		{"comment", "! a: value", ""},
+		{"preprocessor line", "#define a b\na: value", "value"},
+		{"bad name", "a b: value", ""},
+		{"? without binding", "?a: value", ""},
+		{"name ending in binding", "a*: value", ""},
+		{"no colon", "a value", ""},
+	}
+	for _, test := range tests {
+		db, err := Parse(test.data)
+		if err != nil {
+			t.Errorf("%s: %s", test.desc, err)
+			continue
+		}
+		got, ok := db.Query("a", "A")
+		if ok != (test.want != "") || got != test.want {
+			t.Errorf("%s: got (%q, %t), want %q", test.desc, got, ok,
+				test.want)
+		}
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	db := New()
+	db.Put("xterm*vt100.background", " black\\\n")
+	db.Put("?.foreground", "white")
+
+	other, err := Parse(db.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Len() != db.Len() {
+		t.Fatalf("got %d entries from:\n%s", other.Len(), db.String())
+	}
+	if v, _ := other.Query(testName, testClass); v != " black\\\n" {
+		t.Errorf("background: got %q", v)
+	}
+	v, _ := other.Query("xterm.foreground", "XTerm.Foreground")
+	if v != "white" {
+		t.Errorf("foreground: got %q", v)
+	}
+}
+
+func TestInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xresource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, data string) string {
+		fpath := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(fpath, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return fpath
+	}
+
+	write("colors", "*background: black\n*foreground: white\n")
+	main := write("main",
+		"*foreground: red\n#  include \"colors\"\n*background: blue\n")
+	db, err := ParseFile(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Entries after the include overwrite the included ones, and the
+	// included ones overwrite those before it.
+	for _, test := range []struct{ name, want string }{
+		{"background", "blue"},
+		{"foreground", "white"},
+	} {
+		if v, _ := db.Query(test.name, "Class"); v != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, v, test.want)
+		}
+	}
+
+	// Anything that isn't a quoted file name is ignored.
+	ignored := write("ignored", "#include <colors>\n#includes\n#include \"\n")
+	if db, err = ParseFile(ignored); err != nil {
+		t.Errorf("malformed includes: %s", err)
+	} else if db.Len() != 0 {
+		t.Errorf("malformed includes: got %d entries", db.Len())
+	}
+
+	missing := write("missing", "#include \"nonexistent\"\n")
+	if _, err := ParseFile(missing); err == nil {
+		t.Error("no error for a missing include file")
+	}
+
+	self := write("self", "#include \"self\"\n")
+	if _, err := ParseFile(self); err == nil {
+		t.Error("no error for a file that includes itself")
+	}
+}
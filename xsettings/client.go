@@ -0,0 +1,211 @@
+package xsettings
+
+/*
+xsettings/client.go contains the client side of the XSETTINGS protocol.
+Namely, finding the current settings manager, reading its settings and
+keeping them up to date.
+*/
+
+import (
+	"sort"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// ChangeFun is the type of function called by a Client whenever the
+// settings change. 'old' and 'cur' are never nil; when no settings manager is
+// running, they are empty.
+type ChangeFun func(xu *xgbutil.XUtil, old, cur *Settings)
+
+// Client keeps an up-to-date copy of the settings published by the current
+// settings manager. It follows the settings manager as it comes and goes.
+type Client struct {
+	X        *xgbutil.XUtil
+	owner    xproto.Window
+	settings *Settings
+	fun      ChangeFun
+
+	// callbacks attached with xevent.Attach to the root and owner windows
+	managerFun xevent.ClientMessageFun
+	propFun    xevent.PropertyNotifyFun
+	destroyFun xevent.DestroyNotifyFun
+
+	managerAtom, selAtom, settingsAtom xproto.Atom
+}
+
+// NewClient finds the current settings manager (if there is one), reads its
+// settings and starts listening for changes. 'fun' is called every time the
+// settings change, and may be nil.
+// NewClient relies on xgbutil's main event loop to track changes.
+func NewClient(xu *xgbutil.XUtil, fun ChangeFun) (*Client, error) {
+	c := &Client{
+		X:        xu,
+		settings: NewSettings(),
+		fun:      fun,
+	}
+	c.propFun = func(xu *xgbutil.XUtil, ev xevent.PropertyNotifyEvent) {
+		if ev.Atom == c.settingsAtom {
+			c.reload()
+		}
+	}
+	c.destroyFun = func(xu *xgbutil.XUtil, ev xevent.DestroyNotifyEvent) {
+		c.unfollow()
+		c.update(NewSettings())
+	}
+	c.managerFun = xevent.ClientMessageFun(c.managerMessage)
+
+	var err error
+	if c.managerAtom, err = xprop.Atm(xu, "MANAGER"); err != nil {
+		return nil, err
+	}
+	if c.selAtom, err = xprop.Atm(xu, SelectionName(xu)); err != nil {
+		return nil, err
+	}
+	c.settingsAtom, err = xprop.Atm(xu, "_XSETTINGS_SETTINGS")
+	if err != nil {
+		return nil, err
+	}
+
+	// New settings managers announce themselves with a MANAGER client
+	// message sent to the root window.
+	root := xwindow.New(xu, xu.RootWin())
+	if err := root.ListenAdd(xproto.EventMaskStructureNotify); err != nil {
+		return nil, err
+	}
+	xevent.Attach(xu, xevent.ClientMessage, xu.RootWin(), &c.managerFun)
+
+	c.settings, err = c.follow()
+	if err != nil {
+		xevent.Disconnect(xu, xevent.ClientMessage, xu.RootWin(),
+			&c.managerFun)
+		return nil, err
+	}
+	return c, nil
+}
+
+// Stop stops following the settings manager. The settings returned by
+// Settings are no longer updated.
+func (c *Client) Stop() {
+	xevent.Disconnect(c.X, xevent.ClientMessage, c.X.RootWin(), &c.managerFun)
+	c.unfollow()
+}
+
+// Settings returns the most recently read settings. If no settings manager is
+// running, the settings are empty.
+func (c *Client) Settings() *Settings {
+	return c.settings
+}
+
+// Owner returns the window of the settings manager currently followed, or 0
+// if there is no settings manager.
+func (c *Client) Owner() xproto.Window {
+	return c.owner
+}
+
+// follow finds the current owner of the settings selection and listens to
+// changes on it. The settings of the new owner are returned.
+func (c *Client) follow() (*Settings, error) {
+	c.unfollow()
+
+	// The server is grabbed so that the owner can't disappear between
+	// finding it and selecting events on it.
+	c.X.Grab()
+	defer c.X.Ungrab()
+
+	owner, err := Owner(c.X)
+	if err != nil || owner == 0 {
+		return NewSettings(), err
+	}
+
+	ownerWin := xwindow.New(c.X, owner)
+	err = ownerWin.ListenAdd(xproto.EventMaskPropertyChange |
+		xproto.EventMaskStructureNotify)
+	if err != nil {
+		return NewSettings(), nil // the owner is already gone
+	}
+	c.owner = owner
+
+	xevent.Attach(c.X, xevent.PropertyNotify, owner, &c.propFun)
+	xevent.Attach(c.X, xevent.DestroyNotify, owner, &c.destroyFun)
+
+	settings, err := SettingsGet(c.X, owner)
+	if err != nil {
+		// The manager may not have set the property yet.
+		return NewSettings(), nil
+	}
+	return settings, nil
+}
+
+// unfollow stops listening to the current owner, if there is one.
+func (c *Client) unfollow() {
+	if c.owner == 0 {
+		return
+	}
+	xevent.Disconnect(c.X, xevent.PropertyNotify, c.owner, &c.propFun)
+	xevent.Disconnect(c.X, xevent.DestroyNotify, c.owner, &c.destroyFun)
+	c.owner = 0
+}
+
+// managerMessage responds to a MANAGER client message on the root window.
+func (c *Client) managerMessage(xu *xgbutil.XUtil,
+	ev xevent.ClientMessageEvent) {
+
+	if ev.Format != 32 || ev.Type != c.managerAtom {
+		return
+	}
+	if xproto.Atom(ev.Data.Data32[1]) != c.selAtom {
+		return
+	}
+
+	settings, err := c.follow()
+	if err != nil {
+		xgbutil.Logger.Printf("Could not follow new settings manager: %s",
+			err)
+		return
+	}
+	c.update(settings)
+}
+
+// reload reads the settings from the current owner.
+func (c *Client) reload() {
+	settings, err := SettingsGet(c.X, c.owner)
+	if err != nil {
+		xgbutil.Logger.Printf("Could not read settings from %x: %s",
+			c.owner, err)
+		return
+	}
+	c.update(settings)
+}
+
+// update replaces the current settings and calls the change function.
+func (c *Client) update(settings *Settings) {
+	old := c.settings
+	c.settings = settings
+	if c.fun != nil {
+		c.fun(c.X, old, settings)
+	}
+}
+
+// Changed returns the names of all settings that were added, removed or
+// changed between 'old' and 'cur', in sorted order.
+func Changed(old, cur *Settings) []string {
+	changed := make([]string, 0)
+	for _, name := range cur.Names() {
+		o, ok := old.Settings[name]
+		if !ok || *o != *cur.Settings[name] {
+			changed = append(changed, name)
+		}
+	}
+	for _, name := range old.Names() {
+		if _, ok := cur.Settings[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,55 @@
+/*
+Package xsettings implements both sides of the XSETTINGS protocol, which is
+used by desktop environments (and GTK in particular) to publish settings like
+the theme name, the screen DPI, the cursor size and the double click time.
+
+The protocol is described here:
+http://standards.freedesktop.org/xsettings-spec/xsettings-latest.html
+
+In short, a settings manager owns the _XSETTINGS_S[n] selection (where n is
+the screen number) and stores all settings in a binary format in the
+_XSETTINGS_SETTINGS property of its selection window.
+
+Reading settings
+
+To read the settings once, use Get:
+
+	settings, err := xsettings.Get(XUtilValue)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if theme, ok := settings.String("Net/ThemeName"); ok {
+		fmt.Println("Theme:", theme)
+	}
+
+To keep the settings up to date, use a Client instead. The function given to
+NewClient is called with the old and new settings every time a setting
+changes, or when the settings manager is replaced or exits. Changed reports
+which settings are different.
+
+	xsettings.NewClient(XUtilValue,
+		func(X *xgbutil.XUtil, old, cur *xsettings.Settings) {
+			for _, name := range xsettings.Changed(old, cur) {
+				fmt.Println(name, "changed")
+			}
+		})
+
+Stop stops the client. A Client relies on xgbutil's main event loop (see
+xevent.Main).
+
+Publishing settings
+
+A Manager owns the XSETTINGS selection and publishes settings:
+
+	m, err := xsettings.NewManager(XUtilValue, false, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	m.SetString("Net/ThemeName", "Adwaita")
+	m.SetInt("Xft/DPI", 96*1024)
+	m.Publish()
+
+The per-setting serial numbers required by the protocol are maintained
+automatically.
+*/
+package xsettings
@@ -0,0 +1,135 @@
+package xsettings
+
+/*
+xsettings/manager.go contains the manager side of the XSETTINGS protocol.
+Namely, owning the XSETTINGS selection and publishing settings on the
+selection owner window.
+*/
+
+import (
+	"time"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/icccm"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// Manager is a settings manager. It owns the XSETTINGS selection for the
+// default screen and publishes settings in the _XSETTINGS_SETTINGS property
+// of its selection window.
+//
+// Settings are changed with SetInt, SetString, SetColor and Delete, but
+// clients won't see the changes until Publish is called. This lets several
+// settings be changed at once.
+type Manager struct {
+	X        *xgbutil.XUtil
+	Win      *xwindow.Window
+	sel      *icccm.ManagerSelection
+	settings *Settings
+	changed  bool
+}
+
+// replaceTimeout is how long an old settings manager has to exit after
+// being replaced.
+const replaceTimeout = 3 * time.Second
+
+// NewManager creates a window and makes it the owner of the XSETTINGS
+// selection, following the manager selection conventions of the ICCCM.
+// If another settings manager is already running, an error is returned
+// unless 'replace' is true. In that case, the old settings manager is given a
+// few seconds to exit.
+// 'replaced' is called when another settings manager takes the selection
+// away from us, and may be nil. The Manager should not be used after that.
+func NewManager(xu *xgbutil.XUtil, replace bool,
+	replaced func(m *Manager)) (*Manager, error) {
+
+	m := &Manager{
+		X:        xu,
+		settings: NewSettings(),
+	}
+
+	ms, err := icccm.AcquireManagerSelection(xu, SelectionName(xu), replace,
+		replaceTimeout,
+		func(ms *icccm.ManagerSelection) {
+			if replaced != nil {
+				replaced(m)
+			}
+		})
+	if ms == nil {
+		return nil, err
+	}
+	if err != nil {
+		xgbutil.Logger.Println(err)
+	}
+	m.sel = ms
+	m.Win = xwindow.New(xu, ms.Win)
+
+	if err := SettingsSet(xu, ms.Win, m.settings); err != nil {
+		ms.Release()
+		return nil, err
+	}
+	return m, nil
+}
+
+// Settings returns the settings that the manager will publish. The value
+// returned should not be modified.
+func (m *Manager) Settings() *Settings {
+	return m.settings
+}
+
+// SetInt sets the integer setting 'name' to 'val'.
+func (m *Manager) SetInt(name string, val int32) {
+	m.set(&Setting{Name: name, Type: TypeInt, Int: val})
+}
+
+// SetString sets the string setting 'name' to 'val'.
+func (m *Manager) SetString(name string, val string) {
+	m.set(&Setting{Name: name, Type: TypeString, String: val})
+}
+
+// SetColor sets the color setting 'name' to 'val'.
+func (m *Manager) SetColor(name string, val Color) {
+	m.set(&Setting{Name: name, Type: TypeColor, Color: val})
+}
+
+// Delete removes the setting 'name'.
+func (m *Manager) Delete(name string) {
+	if _, ok := m.settings.Settings[name]; ok {
+		delete(m.settings.Settings, name)
+		m.changed = true
+	}
+}
+
+// set adds or replaces a setting, and records the serial it changed in.
+// Nothing happens if the setting already has the same value.
+func (m *Manager) set(set *Setting) {
+	if old, ok := m.settings.Settings[set.Name]; ok {
+		set.Serial = old.Serial
+		if *old == *set {
+			return
+		}
+	}
+
+	// The serial that the next call to Publish will use.
+	set.Serial = m.settings.Serial + 1
+	m.settings.Settings[set.Name] = set
+	m.changed = true
+}
+
+// Publish writes the current settings to the _XSETTINGS_SETTINGS property,
+// which tells every client about the changes made since the last call to
+// Publish. Nothing is written if nothing has changed.
+func (m *Manager) Publish() error {
+	if !m.changed {
+		return nil
+	}
+	m.settings.Serial++
+	m.changed = false
+	return SettingsSet(m.X, m.Win.Id, m.settings)
+}
+
+// Destroy gives up ownership of the XSETTINGS selection and destroys the
+// selection window.
+func (m *Manager) Destroy() {
+	m.sel.Release()
+}
@@ -0,0 +1,322 @@
+package xsettings
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// Setting types, as they appear in the _XSETTINGS_SETTINGS property.
+const (
+	TypeInt = iota
+	TypeString
+	TypeColor
+)
+
+// Color is the value of a color setting. Each channel is 16 bits.
+type Color struct {
+	Red, Green, Blue, Alpha uint16
+}
+
+// Setting is a single named setting. Type is one of TypeInt, TypeString or
+// TypeColor, and determines which of Int, String or Color holds the value.
+// Serial is the value of the settings serial when this setting last changed.
+type Setting struct {
+	Name   string
+	Type   int
+	Int    int32
+	String string
+	Color  Color
+	Serial uint32
+}
+
+// Settings is the full set of settings published by a settings manager.
+// Serial is incremented by the manager each time the settings change.
+type Settings struct {
+	Serial   uint32
+	Settings map[string]*Setting
+}
+
+// NewSettings returns an empty set of settings.
+func NewSettings() *Settings {
+	return &Settings{Settings: make(map[string]*Setting, 20)}
+}
+
+// Int returns the value of the integer setting 'name'. The second return
+// value is false if there is no such setting or if it isn't an integer.
+func (s *Settings) Int(name string) (int32, bool) {
+	set, ok := s.Settings[name]
+	if !ok || set.Type != TypeInt {
+		return 0, false
+	}
+	return set.Int, true
+}
+
+// String returns the value of the string setting 'name'. The second return
+// value is false if there is no such setting or if it isn't a string.
+func (s *Settings) String(name string) (string, bool) {
+	set, ok := s.Settings[name]
+	if !ok || set.Type != TypeString {
+		return "", false
+	}
+	return set.String, true
+}
+
+// Color returns the value of the color setting 'name'. The second return
+// value is false if there is no such setting or if it isn't a color.
+func (s *Settings) Color(name string) (Color, bool) {
+	set, ok := s.Settings[name]
+	if !ok || set.Type != TypeColor {
+		return Color{}, false
+	}
+	return set.Color, true
+}
+
+// Names returns the names of all settings in sorted order.
+func (s *Settings) Names() []string {
+	names := make([]string, 0, len(s.Settings))
+	for name := range s.Settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SelectionName returns the name of the XSETTINGS selection for the default
+// screen. e.g., "_XSETTINGS_S0".
+func SelectionName(xu *xgbutil.XUtil) string {
+	return fmt.Sprintf("_XSETTINGS_S%d", xu.Conn().DefaultScreen)
+}
+
+// Owner returns the window that currently owns the XSETTINGS selection.
+// If no settings manager is running, 0 is returned.
+func Owner(xu *xgbutil.XUtil) (xproto.Window, error) {
+	selAtom, err := xprop.Atm(xu, SelectionName(xu))
+	if err != nil {
+		return 0, err
+	}
+
+	reply, err := xproto.GetSelectionOwner(xu.Conn(), selAtom).Reply()
+	if err != nil {
+		return 0, err
+	}
+	return reply.Owner, nil
+}
+
+// Get finds the current settings manager and reads its settings. An error
+// is returned if there is no settings manager running.
+func Get(xu *xgbutil.XUtil) (*Settings, error) {
+	owner, err := Owner(xu)
+	if err != nil {
+		return nil, err
+	}
+	if owner == 0 {
+		return nil, fmt.Errorf("xsettings.Get: No settings manager owns "+
+			"the '%s' selection.", SelectionName(xu))
+	}
+	return SettingsGet(xu, owner)
+}
+
+// _XSETTINGS_SETTINGS get
+func SettingsGet(xu *xgbutil.XUtil, win xproto.Window) (*Settings, error) {
+	reply, err := xprop.GetProperty(xu, win, "_XSETTINGS_SETTINGS")
+	if err != nil {
+		return nil, err
+	}
+	if reply.Format != 8 {
+		return nil, fmt.Errorf("xsettings.SettingsGet: Expected format 8 "+
+			"but got %d", reply.Format)
+	}
+	return Decode(reply.Value)
+}
+
+// _XSETTINGS_SETTINGS set
+func SettingsSet(xu *xgbutil.XUtil, win xproto.Window, s *Settings) error {
+	return xprop.ChangeProp(xu, win, 8, "_XSETTINGS_SETTINGS",
+		"_XSETTINGS_SETTINGS", Encode(s))
+}
+
+// Decode parses the binary format of the _XSETTINGS_SETTINGS property.
+func Decode(data []byte) (*Settings, error) {
+	d := &decoder{data: data}
+	if len(data) < 12 {
+		return nil, fmt.Errorf("xsettings.Decode: Expected at least 12 "+
+			"bytes, but got %d.", len(data))
+	}
+
+	switch data[0] {
+	case 0:
+		d.order = binary.LittleEndian
+	case 1:
+		d.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("xsettings.Decode: Unknown byte order %d.",
+			data[0])
+	}
+	d.skip(4)
+
+	s := NewSettings()
+	s.Serial = d.card32()
+	n := d.card32()
+	for i := uint32(0); i < n && d.err == nil; i++ {
+		set := &Setting{}
+		set.Type = int(d.card8())
+		d.skip(1)
+		set.Name = d.str(int(d.card16()))
+		set.Serial = d.card32()
+
+		switch set.Type {
+		case TypeInt:
+			set.Int = int32(d.card32())
+		case TypeString:
+			set.String = d.str(int(d.card32()))
+		case TypeColor:
+			set.Color.Red = d.card16()
+			set.Color.Blue = d.card16()
+			set.Color.Green = d.card16()
+			set.Color.Alpha = d.card16()
+		default:
+			return nil, fmt.Errorf("xsettings.Decode: Unknown type %d for "+
+				"setting '%s'.", set.Type, set.Name)
+		}
+		s.Settings[set.Name] = set
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return s, nil
+}
+
+// Encode produces the binary format of the _XSETTINGS_SETTINGS property.
+// Settings are written in sorted order with a little endian byte order.
+func Encode(s *Settings) []byte {
+	e := &encoder{order: binary.LittleEndian}
+	e.card8(0)
+	e.pad()
+	e.card32(s.Serial)
+	e.card32(uint32(len(s.Settings)))
+	for _, name := range s.Names() {
+		set := s.Settings[name]
+		e.card8(uint8(set.Type))
+		e.card8(0)
+		e.card16(uint16(len(name)))
+		e.str(name)
+		e.card32(set.Serial)
+
+		switch set.Type {
+		case TypeInt:
+			e.card32(uint32(set.Int))
+		case TypeString:
+			e.card32(uint32(len(set.String)))
+			e.str(set.String)
+		case TypeColor:
+			e.card16(set.Color.Red)
+			e.card16(set.Color.Blue)
+			e.card16(set.Color.Green)
+			e.card16(set.Color.Alpha)
+		}
+	}
+	return e.buf
+}
+
+// decoder reads values from the _XSETTINGS_SETTINGS format. The first error
+// encountered is kept in 'err', and all reads after that return zero values.
+type decoder struct {
+	data  []byte
+	order binary.ByteOrder
+	err   error
+}
+
+func (d *decoder) need(n int) bool {
+	if d.err == nil && len(d.data) < n {
+		d.err = fmt.Errorf("xsettings.Decode: Unexpected end of data.")
+	}
+	return d.err == nil
+}
+
+func (d *decoder) skip(n int) {
+	if d.need(n) {
+		d.data = d.data[n:]
+	}
+}
+
+func (d *decoder) card8() uint8 {
+	if !d.need(1) {
+		return 0
+	}
+	v := d.data[0]
+	d.data = d.data[1:]
+	return v
+}
+
+func (d *decoder) card16() uint16 {
+	if !d.need(2) {
+		return 0
+	}
+	v := d.order.Uint16(d.data)
+	d.data = d.data[2:]
+	return v
+}
+
+func (d *decoder) card32() uint32 {
+	if !d.need(4) {
+		return 0
+	}
+	v := d.order.Uint32(d.data)
+	d.data = d.data[4:]
+	return v
+}
+
+// str reads a string of length n, along with its padding.
+func (d *decoder) str(n int) string {
+	padded := n + pad(n)
+	if !d.need(padded) {
+		return ""
+	}
+	s := string(d.data[:n])
+	d.data = d.data[padded:]
+	return s
+}
+
+// encoder writes values in the _XSETTINGS_SETTINGS format.
+type encoder struct {
+	buf   []byte
+	order binary.ByteOrder
+}
+
+func (e *encoder) card8(v uint8) {
+	e.buf = append(e.buf, v)
+}
+
+func (e *encoder) card16(v uint16) {
+	b := make([]byte, 2)
+	e.order.PutUint16(b, v)
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) card32(v uint32) {
+	b := make([]byte, 4)
+	e.order.PutUint32(b, v)
+	e.buf = append(e.buf, b...)
+}
+
+// str writes a string followed by enough padding to reach a multiple of 4.
+func (e *encoder) str(s string) {
+	e.buf = append(e.buf, s...)
+	e.buf = append(e.buf, make([]byte, pad(len(s)))...)
+}
+
+// pad writes enough padding to reach a multiple of 4.
+func (e *encoder) pad() {
+	e.buf = append(e.buf, make([]byte, pad(len(e.buf)))...)
+}
+
+// pad returns the number of bytes needed to pad n to a multiple of 4.
+func pad(n int) int {
+	return (4 - n%4) % 4
+}
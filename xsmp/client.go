@@ -0,0 +1,312 @@
+package xsmp
+
+/*
+xsmp/client.go contains the client side of the X Session Management Protocol.
+*/
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/xgbutil"
+)
+
+// XSMP minor opcodes.
+const (
+	smError = iota
+	smRegisterClient
+	smRegisterClientReply
+	smSaveYourself
+	smSaveYourselfRequest
+	smInteractRequest
+	smInteract
+	smInteractDone
+	smSaveYourselfDone
+	smDie
+	smShutdownCancelled
+	smCloseConnection
+	smSetProperties
+	smDeleteProperties
+	smGetProperties
+	smPropertiesReply
+	smSaveYourselfPhase2Request
+	smSaveYourselfPhase2
+	smSaveComplete
+)
+
+// The kinds of state a SaveYourself message asks a client to save.
+// SaveGlobal means data that other clients can see (e.g., files), and
+// SaveLocal means the state needed to restart the client as it is now.
+const (
+	SaveGlobal = iota
+	SaveLocal
+	SaveBoth
+)
+
+// The kinds of interaction with the user that a SaveYourself message allows.
+const (
+	InteractNone = iota
+	InteractErrors
+	InteractAny
+)
+
+// Names of the standard session management properties.
+const (
+	PropCloneCommand     = "CloneCommand"
+	PropCurrentDirectory = "CurrentDirectory"
+	PropDiscardCommand   = "DiscardCommand"
+	PropEnvironment      = "Environment"
+	PropProcessID        = "ProcessID"
+	PropProgram          = "Program"
+	PropRestartCommand   = "RestartCommand"
+	PropResignCommand    = "ResignCommand"
+	PropRestartStyleHint = "RestartStyleHint"
+	PropShutdownCommand  = "ShutdownCommand"
+	PropUserID           = "UserID"
+)
+
+// Values for the RestartStyleHint property.
+const (
+	RestartIfRunning = iota
+	RestartAnyway
+	RestartImmediately
+	RestartNever
+)
+
+// SaveYourself is a request from the session manager to save state.
+type SaveYourself struct {
+	Type          int
+	Shutdown      bool
+	InteractStyle int
+	Fast          bool
+}
+
+// Handlers are the functions called when the session manager sends us
+// messages. Any of them may be nil.
+//
+// SaveYourself should save whatever state 'sy' asks for (typically updating
+// the RestartCommand property with SetProperties) and return whether it
+// succeeded. If it is nil, success is reported without saving anything.
+//
+// Die is called when the session is ending. The connection to the session
+// manager is closed after Die returns, and the client should exit.
+//
+// SaveComplete is called when every client in the session has saved its
+// state, and ShutdownCancelled when a shutdown has been cancelled.
+type Handlers struct {
+	SaveYourself      func(c *Client, sy SaveYourself) bool
+	Die               func(c *Client)
+	SaveComplete      func(c *Client)
+	ShutdownCancelled func(c *Client)
+}
+
+// Client is a connection to a session manager.
+// Id is the client id assigned by the session manager. It should be stored
+// in the SM_CLIENT_ID property of the client leader window
+// (see icccm.SmClientIdSet), and passed to Connect when the client is
+// restarted.
+type Client struct {
+	Id       string
+	ice      *iceConn
+	handlers Handlers
+}
+
+// Connect connects to the session manager in the SESSION_MANAGER environment
+// variable and registers with it. 'previousId' is the client id from a
+// previous session (usually given to the client on its command line by the
+// restart command), and should be empty for a new client. If the session
+// manager doesn't recognize 'previousId', registration is retried as a new
+// client.
+//
+// Messages from the session manager are processed in their own goroutine,
+// which is where the handlers in 'handlers' are called.
+func Connect(previousId string, handlers Handlers) (*Client, error) {
+	ice, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{ice: ice, handlers: handlers}
+	if c.Id, err = c.register(previousId); err != nil && previousId != "" {
+		c.Id, err = c.register("")
+	}
+	if err != nil {
+		ice.conn.Close()
+		return nil, err
+	}
+
+	go c.listen()
+	return c, nil
+}
+
+// register sends RegisterClient and waits for the reply.
+func (c *Client) register(previousId string) (string, error) {
+	rc := newEncoder(xsmpOpcode, smRegisterClient)
+	rc.putArray8([]byte(previousId))
+	if err := c.ice.send(rc); err != nil {
+		return "", err
+	}
+
+	for {
+		msg, err := c.ice.read()
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case msg.major == 0 && msg.minor == icePing:
+			if err := c.ice.send(newEncoder(0, icePingReply)); err != nil {
+				return "", err
+			}
+		case msg.major == 0 && msg.minor == iceError:
+			return "", c.ice.error(msg)
+		case msg.major == c.ice.hisOpcode && msg.minor == smError:
+			return "", c.ice.error(msg)
+		case msg.major == c.ice.hisOpcode &&
+			msg.minor == smRegisterClientReply:
+
+			return string(c.ice.decoder(msg).getArray8()), nil
+		default:
+			return "", fmt.Errorf("register: Unexpected message with opcode "+
+				"%d/%d while registering.", msg.major, msg.minor)
+		}
+	}
+}
+
+// listen reads messages from the session manager until the connection is
+// closed.
+func (c *Client) listen() {
+	for {
+		msg, err := c.ice.read()
+		if err != nil {
+			return
+		}
+
+		if msg.major == 0 {
+			switch msg.minor {
+			case icePing:
+				c.ice.send(newEncoder(0, icePingReply))
+			case iceError:
+				xgbutil.Logger.Println(c.ice.error(msg))
+			}
+			continue
+		}
+		if msg.major != c.ice.hisOpcode {
+			continue
+		}
+
+		switch msg.minor {
+		case smSaveYourself:
+			if len(msg.buf) < 16 {
+				continue
+			}
+			sy := SaveYourself{
+				Type:          int(msg.buf[8]),
+				Shutdown:      msg.buf[9] != 0,
+				InteractStyle: int(msg.buf[10]),
+				Fast:          msg.buf[11] != 0,
+			}
+			success := true
+			if c.handlers.SaveYourself != nil {
+				success = c.handlers.SaveYourself(c, sy)
+			}
+			if err := c.saveYourselfDone(success); err != nil {
+				xgbutil.Logger.Println(err)
+			}
+		case smDie:
+			if c.handlers.Die != nil {
+				c.handlers.Die(c)
+			}
+			c.Close("Session ended.")
+			return
+		case smSaveComplete:
+			if c.handlers.SaveComplete != nil {
+				c.handlers.SaveComplete(c)
+			}
+		case smShutdownCancelled:
+			if c.handlers.ShutdownCancelled != nil {
+				c.handlers.ShutdownCancelled(c)
+			}
+		case smError:
+			xgbutil.Logger.Println(c.ice.error(msg))
+		}
+	}
+}
+
+// saveYourselfDone tells the session manager that we've finished saving.
+func (c *Client) saveYourselfDone(success bool) error {
+	sd := newEncoder(xsmpOpcode, smSaveYourselfDone)
+	if success {
+		sd.buf[2] = 1
+	}
+	return c.ice.send(sd)
+}
+
+// Property is a session management property. Type is one of "CARD8",
+// "ARRAY8" or "LISTofARRAY8". Use the StringProp, ListProp and Card8Prop
+// constructors to build properties.
+type Property struct {
+	Name   string
+	Type   string
+	Values [][]byte
+}
+
+// StringProp returns an ARRAY8 property, like Program or UserID.
+func StringProp(name, val string) Property {
+	return Property{name, "ARRAY8", [][]byte{[]byte(val)}}
+}
+
+// ListProp returns a LISTofARRAY8 property, like RestartCommand.
+func ListProp(name string, vals []string) Property {
+	bs := make([][]byte, len(vals))
+	for i, val := range vals {
+		bs[i] = []byte(val)
+	}
+	return Property{name, "LISTofARRAY8", bs}
+}
+
+// Card8Prop returns a CARD8 property, like RestartStyleHint.
+func Card8Prop(name string, val byte) Property {
+	return Property{name, "CARD8", [][]byte{{val}}}
+}
+
+// SetProperties sets session management properties. At the very least,
+// CloneCommand, Program, RestartCommand and UserID should be set for the
+// client to be restarted in the next session.
+func (c *Client) SetProperties(props ...Property) error {
+	sp := newEncoder(xsmpOpcode, smSetProperties)
+	sp.put32(uint32(len(props)))
+	sp.skip(4)
+	for _, prop := range props {
+		sp.putArray8([]byte(prop.Name))
+		sp.putArray8([]byte(prop.Type))
+		sp.putListOfArray8(prop.Values)
+	}
+	return c.ice.send(sp)
+}
+
+// DeleteProperties deletes the session management properties 'names'.
+func (c *Client) DeleteProperties(names ...string) error {
+	bs := make([][]byte, len(names))
+	for i, name := range names {
+		bs[i] = []byte(name)
+	}
+
+	dp := newEncoder(xsmpOpcode, smDeleteProperties)
+	dp.putListOfArray8(bs)
+	return c.ice.send(dp)
+}
+
+// Close tells the session manager that we're leaving the session, giving
+// 'reasons' (which may be empty), and closes the connection.
+func (c *Client) Close(reasons ...string) error {
+	bs := make([][]byte, len(reasons))
+	for i, reason := range reasons {
+		bs[i] = []byte(reason)
+	}
+
+	cc := newEncoder(xsmpOpcode, smCloseConnection)
+	cc.putListOfArray8(bs)
+	err := c.ice.send(cc)
+	c.ice.conn.Close()
+	return err
+}
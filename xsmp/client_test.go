@@ -0,0 +1,312 @@
+package xsmp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// managerOpcode is the major opcode that the fake session manager uses for
+// XSMP, which is deliberately different from ours.
+const managerOpcode = 5
+
+// fakeManager is an in-process session manager listening on a Unix socket.
+// SESSION_MANAGER and ICEAUTHORITY point to it while it is running.
+type fakeManager struct {
+	dir       string
+	ln        net.Listener
+	networkId string
+	cookies   map[string][]byte // by protocol, nil if there is no auth
+	errs      chan error
+	env       map[string]string
+}
+
+// newFakeManager starts a session manager that runs 'script' on the first
+// connection, after the ICE and XSMP setup. If 'auth' is true, cookies are
+// written to an ICEauthority file and required by the manager.
+func newFakeManager(t *testing.T, auth bool,
+	script func(ice *iceConn) error) *fakeManager {
+
+	dir, err := ioutil.TempDir("", "xsmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(dir, "sm")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	m := &fakeManager{
+		dir:       dir,
+		ln:        ln,
+		networkId: "local/test:" + sock,
+		errs:      make(chan error, 1),
+		env:       make(map[string]string),
+	}
+	if auth {
+		m.cookies = map[string][]byte{
+			"ICE":  []byte("0123456789abcdef"),
+			"XSMP": []byte("fedcba9876543210"),
+		}
+	}
+	data := make([]byte, 0)
+	for protocol, cookie := range m.cookies {
+		data = append(data,
+			authEntry(protocol, m.networkId, string(cookie))...)
+	}
+	fpath := filepath.Join(dir, "ICEauthority")
+	if err := ioutil.WriteFile(fpath, data, 0600); err != nil {
+		m.close()
+		t.Fatal(err)
+	}
+	m.setenv("SESSION_MANAGER", m.networkId)
+	m.setenv("ICEAUTHORITY", fpath)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			m.errs <- err
+			return
+		}
+		defer conn.Close()
+
+		ice := &iceConn{
+			conn:  conn,
+			order: binary.LittleEndian,
+			wlck:  &sync.Mutex{},
+		}
+		if err := m.setup(ice); err != nil {
+			m.errs <- err
+			return
+		}
+		m.errs <- script(ice)
+	}()
+	return m
+}
+
+// setenv sets an environment variable, remembering its old value for close.
+func (m *fakeManager) setenv(name, val string) {
+	m.env[name] = os.Getenv(name)
+	os.Setenv(name, val)
+}
+
+// close stops the manager and restores the environment.
+func (m *fakeManager) close() {
+	m.ln.Close()
+	os.RemoveAll(m.dir)
+	for name, val := range m.env {
+		os.Setenv(name, val)
+	}
+}
+
+// wait returns the result of the script.
+func (m *fakeManager) wait(t *testing.T) {
+	select {
+	case err := <-m.errs:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The session manager timed out.")
+	}
+}
+
+// setup is the session manager's side of the ICE connection setup and the
+// XSMP protocol setup.
+func (m *fakeManager) setup(ice *iceConn) error {
+	if _, err := expect(ice, 0, iceByteOrder); err != nil {
+		return err
+	}
+	if err := ice.send(newEncoder(0, iceByteOrder)); err != nil {
+		return err
+	}
+
+	msg, err := expect(ice, 0, iceConnectionSetup)
+	if err != nil {
+		return err
+	}
+	if err := m.auth(ice, msg.buf[3], "ICE"); err != nil {
+		return err
+	}
+	cr := newEncoder(0, iceConnectionReply)
+	cr.skip(8)
+	cr.putString("fake")
+	cr.putString("1.0")
+	if err := ice.send(cr); err != nil {
+		return err
+	}
+
+	msg, err = expect(ice, 0, iceProtocolSetup)
+	if err != nil {
+		return err
+	}
+	d := ice.decoder(msg)
+	d.off = 16
+	if name := d.getString(); name != "XSMP" || msg.buf[2] != xsmpOpcode {
+		return fmt.Errorf("Bad ProtocolSetup for %q with opcode %d.",
+			name, msg.buf[2])
+	}
+	if err := m.auth(ice, msg.buf[9], "XSMP"); err != nil {
+		return err
+	}
+	pr := newEncoder(0, iceProtocolReply)
+	pr.buf[3] = managerOpcode
+	pr.putString("fake")
+	pr.putString("1.0")
+	return ice.send(pr)
+}
+
+// auth requires the cookie for 'protocol', if the manager has one. 'count'
+// is the number of authentication methods offered by the client.
+func (m *fakeManager) auth(ice *iceConn, count byte, protocol string) error {
+	cookie := m.cookies[protocol]
+	if cookie == nil {
+		return nil
+	}
+	if count != 1 {
+		return fmt.Errorf("%s: The client offered %d auth methods.",
+			protocol, count)
+	}
+
+	if err := ice.send(newEncoder(0, iceAuthRequired)); err != nil {
+		return err
+	}
+	msg, err := expect(ice, 0, iceAuthReply)
+	if err != nil {
+		return err
+	}
+	size := int(ice.decoder(msg).get16At(8))
+	if got := msg.buf[16 : 16+size]; string(got) != string(cookie) {
+		return fmt.Errorf("%s: Got cookie %q, want %q.", protocol, got,
+			cookie)
+	}
+	return nil
+}
+
+// expect reads a message and checks its opcodes.
+func expect(ice *iceConn, major, minor byte) (*message, error) {
+	msg, err := ice.read()
+	if err != nil {
+		return nil, err
+	}
+	if msg.major != major || msg.minor != minor {
+		return nil, fmt.Errorf("Got opcode %d/%d, want %d/%d.",
+			msg.major, msg.minor, major, minor)
+	}
+	return msg, nil
+}
+
+func TestConnect(t *testing.T) {
+	m := newFakeManager(t, true, func(ice *iceConn) error {
+		msg, err := expect(ice, xsmpOpcode, smRegisterClient)
+		if err != nil {
+			return err
+		}
+		if id := ice.decoder(msg).getArray8(); string(id) != "old-id" {
+			return fmt.Errorf("Registered with %q.", id)
+		}
+		rr := newEncoder(managerOpcode, smRegisterClientReply)
+		rr.putArray8([]byte("old-id"))
+		if err := ice.send(rr); err != nil {
+			return err
+		}
+
+		sy := newEncoder(managerOpcode, smSaveYourself)
+		sy.put8(SaveLocal)
+		sy.put8(1)
+		sy.put8(InteractNone)
+		sy.put8(0)
+		if err := ice.send(sy); err != nil {
+			return err
+		}
+		msg, err = expect(ice, xsmpOpcode, smSaveYourselfDone)
+		if err != nil {
+			return err
+		}
+		if msg.buf[2] != 1 {
+			return fmt.Errorf("SaveYourselfDone reported failure.")
+		}
+
+		if err := ice.send(newEncoder(managerOpcode, smDie)); err != nil {
+			return err
+		}
+		_, err = expect(ice, xsmpOpcode, smCloseConnection)
+		return err
+	})
+	defer m.close()
+
+	saved := make(chan SaveYourself, 1)
+	died := make(chan bool, 1)
+	c, err := Connect("old-id", Handlers{
+		SaveYourself: func(c *Client, sy SaveYourself) bool {
+			saved <- sy
+			return true
+		},
+		Die: func(c *Client) {
+			died <- true
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Id != "old-id" {
+		t.Errorf("Got client id %q.", c.Id)
+	}
+	m.wait(t)
+
+	want := SaveYourself{Type: SaveLocal, Shutdown: true,
+		InteractStyle: InteractNone}
+	if sy := <-saved; sy != want {
+		t.Errorf("Got %+v, want %+v.", sy, want)
+	}
+	if !<-died {
+		t.Error("Die wasn't called.")
+	}
+}
+
+func TestConnectUnknownId(t *testing.T) {
+	m := newFakeManager(t, false, func(ice *iceConn) error {
+		// The previous id is refused, and the client should register
+		// again as a new client.
+		if _, err := expect(ice, xsmpOpcode, smRegisterClient); err != nil {
+			return err
+		}
+		bad := newEncoder(managerOpcode, smError)
+		bad.put16(0)
+		bad.skip(6)
+		if err := ice.send(bad); err != nil {
+			return err
+		}
+
+		msg, err := expect(ice, xsmpOpcode, smRegisterClient)
+		if err != nil {
+			return err
+		}
+		if id := ice.decoder(msg).getArray8(); len(id) > 0 {
+			return fmt.Errorf("Registered again with %q.", id)
+		}
+		rr := newEncoder(managerOpcode, smRegisterClientReply)
+		rr.putArray8([]byte("new-id"))
+		return ice.send(rr)
+	})
+	defer m.close()
+
+	c, err := Connect("stale-id", Handlers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	m.wait(t)
+
+	if c.Id != "new-id" {
+		t.Errorf("Got client id %q.", c.Id)
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Package xsmp provides a client for the X Session Management Protocol (XSMP),
+which is how applications take part in a desktop session: they are told when
+to save their state, they tell the session manager how to restart them, and
+they are told when the session is ending.
+
+XSMP runs on top of the Inter-Client Exchange (ICE) protocol rather than on
+the X connection. This package contains just enough of ICE to talk to a
+session manager over the Unix (or TCP) socket named in the SESSION_MANAGER
+environment variable, authenticating with the MIT-MAGIC-COOKIE-1 cookies in
+the ICEauthority file.
+
+Usage
+
+Connect registers with the session manager. The client id it returns should
+be stored in the SM_CLIENT_ID property of the client leader window, and the
+client should make sure that it is given back on the next restart:
+
+	client, err := xsmp.Connect(previousId, xsmp.Handlers{
+		SaveYourself: func(c *xsmp.Client, sy xsmp.SaveYourself) bool {
+			err := c.SetProperties(
+				xsmp.StringProp(xsmp.PropProgram, os.Args[0]),
+				xsmp.StringProp(xsmp.PropUserID, os.Getenv("USER")),
+				xsmp.ListProp(xsmp.PropRestartCommand,
+					[]string{os.Args[0], "--sm-client-id", c.Id}),
+				xsmp.ListProp(xsmp.PropCloneCommand, []string{os.Args[0]}))
+			return err == nil
+		},
+		Die: func(c *xsmp.Client) {
+			xevent.Quit(X)
+		},
+	})
+	if err != nil {
+		log.Println("No session manager:", err)
+	} else {
+		icccm.WmClientLeaderSet(X, leader, leader)
+		icccm.SmClientIdSet(X, leader, client.Id)
+	}
+
+Messages from the session manager are processed in a separate goroutine, so
+handlers that touch shared state must synchronize with the rest of the
+program.
+
+Limitations
+
+Interaction with the user during a save (InteractRequest), phase 2 saves and
+reading properties back from the session manager are not supported. Only the
+MIT-MAGIC-COOKIE-1 authentication method is supported.
+*/
+package xsmp
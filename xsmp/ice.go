@@ -0,0 +1,480 @@
+package xsmp
+
+/*
+xsmp/ice.go contains just enough of the Inter-Client Exchange (ICE) protocol
+to run XSMP on top of it. Namely, connecting to a session manager, the ICE
+connection and protocol setup handshakes (with MIT-MAGIC-COOKIE-1
+authentication from the ICEauthority file) and reading and writing messages.
+
+Every ICE message starts with an 8 byte header: a major opcode, a minor
+opcode, two bytes of message specific data and the length of the rest of the
+message in units of 8 bytes. Major opcode 0 is ICE itself.
+*/
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ICE minor opcodes.
+const (
+	iceError = iota
+	iceByteOrder
+	iceConnectionSetup
+	iceAuthRequired
+	iceAuthReply
+	iceAuthNextPhase
+	iceConnectionReply
+	iceProtocolSetup
+	iceProtocolReply
+	icePing
+	icePingReply
+	iceWantToClose
+	iceNoClose
+)
+
+const (
+	vendor  = "xgbutil"
+	release = "1.0"
+
+	// authMethod is the only authentication method supported.
+	authMethod = "MIT-MAGIC-COOKIE-1"
+
+	// xsmpOpcode is the major opcode that we use for XSMP messages.
+	xsmpOpcode = 1
+
+	// maxMessageLength bounds the length of a message we're willing to read.
+	// No XSMP message should come anywhere close to it.
+	maxMessageLength = 1 << 20
+)
+
+// iceConn is an ICE connection with XSMP set up on it.
+// We always send messages in little endian byte order, and read messages in
+// whatever byte order the session manager told us it uses.
+type iceConn struct {
+	conn      net.Conn
+	networkId string
+	order     binary.ByteOrder
+
+	// hisOpcode is the major opcode the session manager uses for XSMP
+	// messages.
+	hisOpcode byte
+
+	wlck *sync.Mutex
+}
+
+// message is a single ICE message, including its header.
+type message struct {
+	major, minor byte
+	buf          []byte
+}
+
+// dial connects to the first session manager in the SESSION_MANAGER
+// environment variable that answers, and sets up XSMP on the connection.
+func dial() (*iceConn, error) {
+	addrs := os.Getenv("SESSION_MANAGER")
+	if addrs == "" {
+		return nil, fmt.Errorf("dial: SESSION_MANAGER is not set.")
+	}
+
+	var lastErr error
+	for _, networkId := range strings.Split(addrs, ",") {
+		network, addr, err := parseNetworkId(networkId)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		conn, err := net.Dial(network, addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		ice := &iceConn{
+			conn:      conn,
+			networkId: networkId,
+			order:     binary.LittleEndian,
+			wlck:      &sync.Mutex{},
+		}
+		if err := ice.setup(); err != nil {
+			conn.Close()
+			lastErr = err
+			continue
+		}
+		return ice, nil
+	}
+	return nil, lastErr
+}
+
+// parseNetworkId turns an ICE network id, like
+// "local/host:/tmp/.ICE-unix/1234" or "tcp/host:5000", into arguments for
+// net.Dial.
+func parseNetworkId(networkId string) (string, string, error) {
+	slash := strings.Index(networkId, "/")
+	if slash < 0 {
+		return "", "", fmt.Errorf("parseNetworkId: Invalid network id '%s'.",
+			networkId)
+	}
+	transport, rest := networkId[:slash], networkId[slash+1:]
+
+	colon := strings.Index(rest, ":")
+	if colon < 0 {
+		return "", "", fmt.Errorf("parseNetworkId: Invalid network id '%s'.",
+			networkId)
+	}
+	host, addr := rest[:colon], rest[colon+1:]
+
+	switch transport {
+	case "local", "unix":
+		return "unix", addr, nil
+	case "tcp":
+		return "tcp", net.JoinHostPort(host, addr), nil
+	}
+	return "", "", fmt.Errorf("parseNetworkId: Unsupported transport '%s'.",
+		transport)
+}
+
+// setup performs the ICE connection setup and then the XSMP protocol setup.
+func (ice *iceConn) setup() error {
+	bo := newEncoder(0, iceByteOrder)
+	bo.buf[2] = 0 // LSBFirst
+	if err := ice.send(bo); err != nil {
+		return err
+	}
+
+	// The first thing the other side sends is its byte order.
+	msg, err := ice.read()
+	if err != nil {
+		return err
+	}
+	if msg.major != 0 || msg.minor != iceByteOrder {
+		return fmt.Errorf("setup: Expected a ByteOrder message, but got "+
+			"opcode %d/%d.", msg.major, msg.minor)
+	}
+	if msg.buf[2] == 1 {
+		ice.order = binary.BigEndian
+	}
+
+	// Connection setup: one version (1.0) and the methods we can
+	// authenticate with.
+	cookie := authCookie("ICE", ice.networkId)
+	cs := newEncoder(0, iceConnectionSetup)
+	cs.buf[2] = 1
+	cs.buf[3] = byte(authCount(cookie))
+	cs.skip(8) // mustAuthenticate and unused
+	cs.putString(vendor)
+	cs.putString(release)
+	if cookie != nil {
+		cs.putString(authMethod)
+	}
+	cs.put16(1)
+	cs.put16(0)
+	if err := ice.send(cs); err != nil {
+		return err
+	}
+	if _, err := ice.authenticate(iceConnectionReply, cookie); err != nil {
+		return err
+	}
+
+	// Protocol setup for XSMP 1.0.
+	cookie = authCookie("XSMP", ice.networkId)
+	ps := newEncoder(0, iceProtocolSetup)
+	ps.buf[2] = xsmpOpcode
+	ps.put8(1)
+	ps.put8(byte(authCount(cookie)))
+	ps.skip(6)
+	ps.putString("XSMP")
+	ps.putString(vendor)
+	ps.putString(release)
+	if cookie != nil {
+		ps.putString(authMethod)
+	}
+	ps.put16(1)
+	ps.put16(0)
+	if err := ice.send(ps); err != nil {
+		return err
+	}
+	reply, err := ice.authenticate(iceProtocolReply, cookie)
+	if err != nil {
+		return err
+	}
+	ice.hisOpcode = reply.buf[3]
+	return nil
+}
+
+// authenticate answers authentication requests until a message with the
+// minor opcode 'done' (a ConnectionReply or ProtocolReply) is read, which is
+// returned.
+func (ice *iceConn) authenticate(done byte, cookie []byte) (*message, error) {
+	for {
+		msg, err := ice.read()
+		if err != nil {
+			return nil, err
+		}
+		if msg.major != 0 {
+			return nil, fmt.Errorf("authenticate: Unexpected message with "+
+				"major opcode %d during setup.", msg.major)
+		}
+
+		switch msg.minor {
+		case done:
+			return msg, nil
+		case iceAuthRequired:
+			if cookie == nil {
+				return nil, fmt.Errorf("authenticate: Authentication " +
+					"required, but no ICE cookie was found.")
+			}
+			ar := newEncoder(0, iceAuthReply)
+			ar.put16(uint16(len(cookie)))
+			ar.skip(6)
+			ar.putBytes(cookie)
+			if err := ice.send(ar); err != nil {
+				return nil, err
+			}
+		case iceAuthNextPhase:
+			return nil, fmt.Errorf("authenticate: Multi-phase " +
+				"authentication is not supported.")
+		case iceError:
+			return nil, ice.error(msg)
+		case icePing:
+			if err := ice.send(newEncoder(0, icePingReply)); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("authenticate: Unexpected ICE message "+
+				"with minor opcode %d during setup.", msg.minor)
+		}
+	}
+}
+
+// error turns an Error message (from ICE or XSMP) into a Go error.
+func (ice *iceConn) error(msg *message) error {
+	d := ice.decoder(msg)
+	class := d.get16At(2)
+	offending, reason := byte(0), ""
+	if len(msg.buf) > 8 {
+		offending = msg.buf[8]
+	}
+	if len(msg.buf) > 16 {
+		d.off = 16
+		reason = d.getString()
+	}
+	return fmt.Errorf("error: The session manager sent an error (class "+
+		"0x%x, offending minor opcode %d): %s", class, offending, reason)
+}
+
+// read reads the next message from the connection.
+func (ice *iceConn) read() (*message, error) {
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(ice.conn, buf); err != nil {
+		return nil, err
+	}
+
+	length := int(ice.order.Uint32(buf[4:])) * 8
+	if length > maxMessageLength-8 {
+		return nil, fmt.Errorf("read: The session manager sent a message "+
+			"of %d bytes, which is more than the maximum of %d bytes.",
+			length+8, maxMessageLength)
+	}
+	if length > 0 {
+		buf = append(buf, make([]byte, length)...)
+		if _, err := io.ReadFull(ice.conn, buf[8:]); err != nil {
+			return nil, err
+		}
+	}
+	return &message{major: buf[0], minor: buf[1], buf: buf}, nil
+}
+
+// send writes a message to the connection. Concurrent calls are safe.
+func (ice *iceConn) send(e *encoder) error {
+	ice.wlck.Lock()
+	defer ice.wlck.Unlock()
+
+	_, err := ice.conn.Write(e.bytes())
+	return err
+}
+
+// decoder returns a decoder for the body of a message.
+func (ice *iceConn) decoder(msg *message) *decoder {
+	return &decoder{order: ice.order, buf: msg.buf, off: 8}
+}
+
+// authCount returns the number of authentication methods we can offer.
+func authCount(cookie []byte) int {
+	if cookie == nil {
+		return 0
+	}
+	return 1
+}
+
+// authCookie finds the MIT-MAGIC-COOKIE-1 for 'protocol' ("ICE" or "XSMP")
+// and 'networkId' in the ICEauthority file. nil is returned if there isn't
+// one. The file is $ICEAUTHORITY, or $HOME/.ICEauthority if that isn't set.
+func authCookie(protocol, networkId string) []byte {
+	fpath := os.Getenv("ICEAUTHORITY")
+	if fpath == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return nil
+		}
+		fpath = filepath.Join(home, ".ICEauthority")
+	}
+	data, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return nil
+	}
+
+	// Each entry is five big endian, 16 bit length prefixed fields:
+	// protocol name, protocol data, network id, auth name and auth data.
+	for len(data) > 0 {
+		var fields [5][]byte
+		for i := range fields {
+			if len(data) < 2 {
+				return nil
+			}
+			size := int(binary.BigEndian.Uint16(data))
+			if len(data) < 2+size {
+				return nil
+			}
+			fields[i] = data[2 : 2+size]
+			data = data[2+size:]
+		}
+		if string(fields[0]) == protocol && string(fields[2]) == networkId &&
+			string(fields[3]) == authMethod {
+
+			return fields[4]
+		}
+	}
+	return nil
+}
+
+// encoder builds a message. The header is written by newEncoder, and the
+// length is filled in by bytes.
+type encoder struct {
+	buf []byte
+}
+
+func newEncoder(major, minor byte) *encoder {
+	return &encoder{buf: []byte{major, minor, 0, 0, 0, 0, 0, 0}}
+}
+
+func (e *encoder) put8(v byte) {
+	e.buf = append(e.buf, v)
+}
+
+func (e *encoder) put16(v uint16) {
+	e.buf = append(e.buf, 0, 0)
+	binary.LittleEndian.PutUint16(e.buf[len(e.buf)-2:], v)
+}
+
+func (e *encoder) put32(v uint32) {
+	e.buf = append(e.buf, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(e.buf[len(e.buf)-4:], v)
+}
+
+func (e *encoder) putBytes(v []byte) {
+	e.buf = append(e.buf, v...)
+}
+
+func (e *encoder) skip(n int) {
+	e.buf = append(e.buf, make([]byte, n)...)
+}
+
+// putString writes an ICE STRING: a 16 bit length followed by the bytes,
+// padded to a multiple of 4 bytes.
+func (e *encoder) putString(s string) {
+	e.put16(uint16(len(s)))
+	e.buf = append(e.buf, s...)
+	e.skip(pad(2+len(s), 4))
+}
+
+// putArray8 writes an XSMP ARRAY8: a 32 bit length followed by the bytes,
+// padded to a multiple of 8 bytes.
+func (e *encoder) putArray8(v []byte) {
+	e.put32(uint32(len(v)))
+	e.buf = append(e.buf, v...)
+	e.skip(pad(4+len(v), 8))
+}
+
+// putListOfArray8 writes an XSMP LISTofARRAY8: a 32 bit count, 4 bytes of
+// padding and then each ARRAY8.
+func (e *encoder) putListOfArray8(vs [][]byte) {
+	e.put32(uint32(len(vs)))
+	e.skip(4)
+	for _, v := range vs {
+		e.putArray8(v)
+	}
+}
+
+// bytes pads the message to a multiple of 8 bytes, fills in the length in
+// the header and returns the message.
+func (e *encoder) bytes() []byte {
+	e.skip(pad(len(e.buf), 8))
+	binary.LittleEndian.PutUint32(e.buf[4:], uint32((len(e.buf)-8)/8))
+	return e.buf
+}
+
+// decoder reads the fields of a message. Reading past the end of the message
+// yields zero values rather than a panic, since the message comes from
+// another process.
+type decoder struct {
+	order binary.ByteOrder
+	buf   []byte
+	off   int
+}
+
+func (d *decoder) get16At(off int) uint16 {
+	if off+2 > len(d.buf) {
+		return 0
+	}
+	return d.order.Uint16(d.buf[off:])
+}
+
+func (d *decoder) get32() uint32 {
+	if d.off+4 > len(d.buf) {
+		d.off = len(d.buf)
+		return 0
+	}
+	v := d.order.Uint32(d.buf[d.off:])
+	d.off += 4
+	return v
+}
+
+func (d *decoder) getBytes(n int) []byte {
+	if n < 0 || d.off+n > len(d.buf) {
+		d.off = len(d.buf)
+		return nil
+	}
+	v := d.buf[d.off : d.off+n]
+	d.off += n
+	return v
+}
+
+// getString reads an ICE STRING.
+func (d *decoder) getString() string {
+	n := int(d.get16At(d.off))
+	d.off += 2
+	s := string(d.getBytes(n))
+	d.off += pad(2+n, 4)
+	return s
+}
+
+// getArray8 reads an XSMP ARRAY8.
+func (d *decoder) getArray8() []byte {
+	n := int(d.get32())
+	v := d.getBytes(n)
+	d.off += pad(4+n, 8)
+	return v
+}
+
+// pad returns the number of bytes needed to pad 'n' to a multiple of 'to'.
+func pad(n, to int) int {
+	return (to - n%to) % to
+}
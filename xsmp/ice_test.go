@@ -0,0 +1,142 @@
+package xsmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	e := newEncoder(xsmpOpcode, smSetProperties)
+	e.putString("xgbutil")
+	e.putArray8([]byte("RestartCommand"))
+	e.putListOfArray8([][]byte{[]byte("xterm"), []byte("-e"), nil})
+	buf := e.bytes()
+
+	if len(buf)%8 != 0 {
+		t.Fatalf("message length %d is not a multiple of 8", len(buf))
+	}
+	if buf[0] != xsmpOpcode || buf[1] != smSetProperties {
+		t.Fatalf("bad opcodes %d/%d", buf[0], buf[1])
+	}
+	if got := int(binary.LittleEndian.Uint32(buf[4:])); got != len(buf)/8-1 {
+		t.Fatalf("header length is %d, but the body is %d units", got,
+			len(buf)/8-1)
+	}
+
+	d := &decoder{order: binary.LittleEndian, buf: buf, off: 8}
+	if s := d.getString(); s != "xgbutil" {
+		t.Errorf("string: got %q", s)
+	}
+	if a := d.getArray8(); string(a) != "RestartCommand" {
+		t.Errorf("array8: got %q", a)
+	}
+	if n := d.get32(); n != 3 {
+		t.Fatalf("list count: got %d", n)
+	}
+	d.off += 4
+	for _, want := range []string{"xterm", "-e", ""} {
+		if a := d.getArray8(); string(a) != want {
+			t.Errorf("list element: got %q, want %q", a, want)
+		}
+	}
+	// Only the padding of the whole message should be left.
+	if len(buf)-d.off >= 8 {
+		t.Errorf("decoded %d bytes of %d", d.off, len(buf))
+	}
+}
+
+func TestDecodeShort(t *testing.T) {
+	// A length that runs past the end of the message mustn't panic.
+	d := &decoder{order: binary.LittleEndian, buf: []byte{0xff, 0, 0, 0}}
+	if a := d.getArray8(); a != nil {
+		t.Errorf("got %q from a truncated ARRAY8", a)
+	}
+	if n := d.get32(); n != 0 {
+		t.Errorf("got %d past the end of the message", n)
+	}
+}
+
+func TestReadTooLong(t *testing.T) {
+	// A length chosen by a broken session manager mustn't be allocated.
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+	go peer.Write([]byte{xsmpOpcode, smSetProperties, 0, 0,
+		0xff, 0xff, 0xff, 0x7f})
+
+	ice := &iceConn{conn: conn, order: binary.LittleEndian}
+	if msg, err := ice.read(); err == nil {
+		t.Errorf("read a message of %d bytes", len(msg.buf))
+	}
+}
+
+func TestParseNetworkId(t *testing.T) {
+	tests := []struct {
+		id, network, addr string
+		ok                bool
+	}{
+		{"local/host:/tmp/.ICE-unix/1234", "unix", "/tmp/.ICE-unix/1234",
+			true},
+		{"unix/host:/tmp/.ICE-unix/1234", "unix", "/tmp/.ICE-unix/1234",
+			true},
+		{"tcp/host:5000", "tcp", "host:5000", true},
+		{"decnet/host:5000", "", "", false},
+		{"local", "", "", false},
+		{"tcp/host", "", "", false},
+	}
+	for _, test := range tests {
+		network, addr, err := parseNetworkId(test.id)
+		if (err == nil) != test.ok {
+			t.Errorf("%s: unexpected error value %v", test.id, err)
+			continue
+		}
+		if network != test.network || addr != test.addr {
+			t.Errorf("%s: got (%s, %s), want (%s, %s)", test.id,
+				network, addr, test.network, test.addr)
+		}
+	}
+}
+
+func TestAuthCookie(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xsmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fpath := filepath.Join(dir, "ICEauthority")
+	data := append(authEntry("ICE", "local/host:/a", "ice-cookie"),
+		authEntry("XSMP", "local/host:/a", "xsmp-cookie")...)
+	if err := ioutil.WriteFile(fpath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("ICEAUTHORITY", os.Getenv("ICEAUTHORITY"))
+	os.Setenv("ICEAUTHORITY", fpath)
+
+	if c := authCookie("XSMP", "local/host:/a"); string(c) != "xsmp-cookie" {
+		t.Errorf("got XSMP cookie %q", c)
+	}
+	if c := authCookie("ICE", "local/host:/a"); string(c) != "ice-cookie" {
+		t.Errorf("got ICE cookie %q", c)
+	}
+	if c := authCookie("ICE", "local/host:/b"); c != nil {
+		t.Errorf("got cookie %q for an unknown network id", c)
+	}
+}
+
+// authEntry encodes one entry of an ICEauthority file.
+func authEntry(protocol, networkId, cookie string) []byte {
+	var buf bytes.Buffer
+	for _, field := range []string{protocol, "", networkId, authMethod,
+		cookie} {
+
+		binary.Write(&buf, binary.BigEndian, uint16(len(field)))
+		buf.WriteString(field)
+	}
+	return buf.Bytes()
+}
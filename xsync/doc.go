@@ -0,0 +1,53 @@
+/*
+Package xsync implements the client side of _NET_WM_SYNC_REQUEST on top of
+counters from the X SYNC extension.
+
+During an interactive resize, a window manager that supports
+_NET_WM_SYNC_REQUEST waits for a client to redraw at its new size before
+resizing it again. Without it, the window manager resizes windows faster than
+they can be painted, which shows up as flicker. The protocol is described in
+the EWMH:
+http://standards.freedesktop.org/wm-spec/wm-spec-latest.html
+
+XGB doesn't include a binding for the SYNC extension, so this package
+contains the few requests needed to create, set and destroy counters. Init
+must be called before they are used. (NewFrameSync does this for you.)
+
+Usage
+
+Create a FrameSync for a top-level window before it is mapped, and tell it
+whenever the window has been painted:
+
+	win, _ := xwindow.Generate(X)
+	win.Create(X.RootWin(), 0, 0, 400, 300, 0)
+
+	fsync, err := xsync.NewFrameSync(win, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	xevent.ConfigureNotifyFun(
+		func(X *xgbutil.XUtil, ev xevent.ConfigureNotifyEvent) {
+			ximg := draw(int(ev.Width), int(ev.Height)) // an *xgraphics.Image
+			ximg.XSurfaceSet(win.Id)
+			ximg.XDraw()
+			ximg.XPaint(win.Id)
+			fsync.FrameDone()
+		}).Connect(X, win.Id)
+
+	win.Map()
+
+The number sent with each _NET_WM_SYNC_REQUEST is recorded when the request
+arrives (which requires xgbutil's main event loop to be running; see
+xevent.Main), and the counter is set to it by the next call to FrameDone.
+
+Extended frame synchronization
+
+If the second argument to NewFrameSync is true, a second counter is created
+and both are stored in _NET_WM_SYNC_REQUEST_COUNTER. This tells a compositing
+window manager that the window reports the start and end of *every* frame,
+not just the ones drawn in response to a resize. Call FrameStart before
+drawing a frame and FrameDone after painting it. (If FrameStart isn't called,
+FrameDone reports both.)
+*/
+package xsync
@@ -0,0 +1,189 @@
+package xsync
+
+/*
+xsync/frame.go contains the client side of _NET_WM_SYNC_REQUEST, which lets a
+window manager wait for a client to redraw before it draws the next frame of
+an interactive resize.
+
+In the basic variant, the window manager sends a value with each request, and
+the client sets its counter to that value once it has redrawn at the new
+size. In the extended variant, the client has a second counter that it makes
+odd when it starts drawing a frame and even when it is done. A request for
+the extended counter tells the client which (even) value to reach at the end
+of the next frame.
+*/
+
+import (
+	"sync"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/xprop"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// FrameSync synchronizes the drawing of a top-level window with the window
+// manager. Create one with NewFrameSync before the window is mapped, and call
+// FrameDone every time the window has been painted (e.g., after
+// xgraphics.Image.XPaint). When using the extended variant, also call
+// FrameStart before painting begins.
+type FrameSync struct {
+	win *xwindow.Window
+
+	lck      *sync.Mutex
+	basic    Counter
+	extended Counter // 0 if the extended variant isn't used
+	value    int64   // the current value of the extended counter
+
+	pending         uint64 // the value of the last request, or 0
+	pendingExtended bool
+}
+
+// NewFrameSync creates the counter(s) for 'win', stores them in
+// _NET_WM_SYNC_REQUEST_COUNTER and adds _NET_WM_SYNC_REQUEST to
+// WM_PROTOCOLS. If 'extended' is true, a second counter is created for
+// extended frame synchronization. The SYNC extension is initialized if
+// necessary.
+//
+// The window manager reads _NET_WM_SYNC_REQUEST_COUNTER when the window is
+// mapped, so NewFrameSync should be called before mapping 'win'.
+func NewFrameSync(win *xwindow.Window, extended bool) (*FrameSync, error) {
+	if err := Init(win.X); err != nil {
+		return nil, err
+	}
+
+	fs := &FrameSync{win: win, lck: &sync.Mutex{}}
+	var err error
+	if fs.basic, err = CreateCounter(win.X, 0); err != nil {
+		return nil, err
+	}
+	if extended {
+		if fs.extended, err = CreateCounter(win.X, 0); err != nil {
+			fs.destroyCounters()
+			return nil, err
+		}
+		err = ewmh.WmSyncRequestCountersSet(win.X, win.Id,
+			uint(fs.basic), uint(fs.extended))
+	} else {
+		err = ewmh.WmSyncRequestCounterSet(win.X, win.Id, uint(fs.basic))
+	}
+	if err != nil {
+		fs.destroyCounters()
+		return nil, err
+	}
+
+	err = win.Protocols().SyncRequestExtended(
+		func(w *xwindow.Window, tstamp xproto.Timestamp, value uint64,
+			extended bool) {
+
+			fs.lck.Lock()
+			fs.pending, fs.pendingExtended = value, extended
+			fs.lck.Unlock()
+		})
+	if err != nil {
+		fs.Destroy()
+		return nil, err
+	}
+	return fs, nil
+}
+
+// Extended returns whether the extended variant is in use.
+func (fs *FrameSync) Extended() bool {
+	return fs.extended != 0
+}
+
+// Pending returns whether a request from the window manager hasn't been
+// answered yet. Clients may use this to skip the wait for their next frame
+// and redraw right away.
+func (fs *FrameSync) Pending() bool {
+	fs.lck.Lock()
+	defer fs.lck.Unlock()
+
+	return fs.pending != 0
+}
+
+// FrameStart tells the window manager that the window has started drawing a
+// new frame, by making the extended counter odd. It does nothing if the
+// extended variant isn't in use, or if a frame has already been started.
+func (fs *FrameSync) FrameStart() error {
+	fs.lck.Lock()
+	defer fs.lck.Unlock()
+
+	return fs.frameStart()
+}
+
+// FrameDone tells the window manager that the window has been redrawn.
+// If there is a pending request for the basic counter, the basic counter is
+// set to the requested value. If the extended variant is in use, the
+// extended counter is made even (starting a frame first if needed).
+func (fs *FrameSync) FrameDone() error {
+	fs.lck.Lock()
+	defer fs.lck.Unlock()
+
+	if fs.pending != 0 && !(fs.pendingExtended && fs.Extended()) {
+		err := SetCounter(fs.win.X, fs.basic, int64(fs.pending))
+		if err != nil {
+			return err
+		}
+		fs.pending = 0
+	}
+	if !fs.Extended() {
+		return nil
+	}
+	if err := fs.frameStart(); err != nil {
+		return err
+	}
+	fs.value++
+	return SetCounter(fs.win.X, fs.extended, fs.value)
+}
+
+// Destroy removes _NET_WM_SYNC_REQUEST from WM_PROTOCOLS, deletes
+// _NET_WM_SYNC_REQUEST_COUNTER and destroys the counter(s).
+func (fs *FrameSync) Destroy() {
+	fs.win.Protocols().Remove("_NET_WM_SYNC_REQUEST")
+	atm, err := xprop.Atm(fs.win.X, "_NET_WM_SYNC_REQUEST_COUNTER")
+	if err == nil {
+		xproto.DeleteProperty(fs.win.X.Conn(), fs.win.Id, atm)
+	}
+	fs.destroyCounters()
+}
+
+// frameStart makes the extended counter odd, unless it already is. If there
+// is a pending request for the extended counter, the counter is moved up so
+// that the end of this frame reaches the requested value.
+// fs.lck must be held.
+func (fs *FrameSync) frameStart() error {
+	if !fs.Extended() || fs.value%2 == 1 {
+		return nil
+	}
+
+	next := fs.value + 1
+	if fs.pending != 0 && fs.pendingExtended {
+		target := int64(fs.pending)
+		if target%2 == 1 {
+			target++
+		}
+		if target-1 > next {
+			next = target - 1
+		}
+		fs.pending = 0
+	}
+	if err := SetCounter(fs.win.X, fs.extended, next); err != nil {
+		return err
+	}
+	fs.value = next
+	return nil
+}
+
+// destroyCounters destroys the counters. Errors are only logged.
+func (fs *FrameSync) destroyCounters() {
+	for _, counter := range []Counter{fs.basic, fs.extended} {
+		if counter != 0 {
+			if err := DestroyCounter(fs.win.X, counter); err != nil {
+				xgbutil.Logger.Println(err)
+			}
+		}
+	}
+}
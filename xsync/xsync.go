@@ -0,0 +1,214 @@
+package xsync
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+)
+
+// XGB has no binding for the SYNC extension, so the few requests needed to
+// work with counters are built by hand here.
+
+// extName is the name of the SYNC extension, and the key used for its major
+// opcode in xgb.Conn.Extensions.
+const extName = "SYNC"
+
+// SYNC minor opcodes.
+const (
+	syncInitialize     = 0
+	syncCreateCounter  = 2
+	syncSetCounter     = 3
+	syncDestroyCounter = 6
+)
+
+// SYNC error numbers, relative to the first error of the extension.
+const (
+	errCounter = 0
+	errAlarm   = 1
+)
+
+// The version of the SYNC extension that we ask for.
+const (
+	majorVersion = 3
+	minorVersion = 1
+)
+
+// registerErrors makes sure that the SYNC errors are only registered with
+// XGB once. XGB keeps its error constructors in a global map (read by the
+// goroutine of every connection), so writing to it for each connection
+// would race.
+var registerErrors sync.Once
+
+// Counter is a SYNC counter: a 64 bit value on the server that clients can
+// set and wait on.
+type Counter uint32
+
+// Error is an error reported by the SYNC extension, e.g., when using a
+// counter that doesn't exist.
+type Error struct {
+	Sequence    uint16
+	NiceName    string
+	BadValue    uint32
+	MinorOpcode uint16
+	MajorOpcode byte
+}
+
+// SequenceId returns the sequence number of the request that failed.
+func (err Error) SequenceId() uint16 {
+	return err.Sequence
+}
+
+// BadId returns the counter or alarm that caused the error.
+func (err Error) BadId() uint32 {
+	return err.BadValue
+}
+
+// Error satisfies the error interface.
+func (err Error) Error() string {
+	return fmt.Sprintf("%s {Sequence: %d, BadValue: %d, MinorOpcode: %d, "+
+		"MajorOpcode: %d}", err.NiceName, err.Sequence, err.BadValue,
+		err.MinorOpcode, err.MajorOpcode)
+}
+
+// newErrorFun returns a function that reads the SYNC error 'name'.
+func newErrorFun(name string) xgb.NewErrorFun {
+	return func(buf []byte) xgb.Error {
+		return Error{
+			Sequence:    xgb.Get16(buf[2:]),
+			NiceName:    name,
+			BadValue:    xgb.Get32(buf[4:]),
+			MinorOpcode: xgb.Get16(buf[8:]),
+			MajorOpcode: buf[10],
+		}
+	}
+}
+
+// Init initializes the SYNC extension. It must be called before any other
+// function in this package that talks to the X server, and may be called
+// more than once.
+func Init(xu *xgbutil.XUtil) error {
+	c := xu.Conn()
+	if _, err := opcode(c); err == nil {
+		return nil
+	}
+
+	reply, err := xproto.QueryExtension(c, uint16(len(extName)),
+		extName).Reply()
+	switch {
+	case err != nil:
+		return err
+	case !reply.Present:
+		return fmt.Errorf("Init: The SYNC extension is not supported by " +
+			"the X server.")
+	}
+
+	// Every connection is assumed to share the error numbers of the first
+	// one, which holds as long as they are to the same X server.
+	registerErrors.Do(func() {
+		xgb.NewErrorFuncs[int(reply.FirstError)+errCounter] =
+			newErrorFun("Counter")
+		xgb.NewErrorFuncs[int(reply.FirstError)+errAlarm] =
+			newErrorFun("Alarm")
+	})
+
+	// The client must tell the server which version of SYNC it speaks before
+	// making any other requests.
+	buf := make([]byte, 8)
+	buf[0] = reply.MajorOpcode
+	buf[1] = syncInitialize
+	xgb.Put16(buf[2:], 2)
+	buf[4] = majorVersion
+	buf[5] = minorVersion
+
+	cookie := c.NewCookie(true, true)
+	c.NewRequest(buf, cookie)
+	if _, err := cookie.Reply(); err != nil {
+		return fmt.Errorf("Init: Could not initialize the SYNC extension: %s",
+			err)
+	}
+
+	c.ExtLock.Lock()
+	c.Extensions[extName] = reply.MajorOpcode
+	c.ExtLock.Unlock()
+	return nil
+}
+
+// CreateCounter creates a new counter with the initial value 'value'.
+func CreateCounter(xu *xgbutil.XUtil, value int64) (Counter, error) {
+	id, err := xu.Conn().NewId()
+	if err != nil {
+		return 0, err
+	}
+	counter := Counter(id)
+	err = counterRequest(xu, syncCreateCounter, counter, value)
+	if err != nil {
+		return 0, err
+	}
+	return counter, nil
+}
+
+// SetCounter sets the value of 'counter' to 'value'.
+func SetCounter(xu *xgbutil.XUtil, counter Counter, value int64) error {
+	return counterRequest(xu, syncSetCounter, counter, value)
+}
+
+// DestroyCounter destroys 'counter'.
+func DestroyCounter(xu *xgbutil.XUtil, counter Counter) error {
+	c := xu.Conn()
+	op, err := opcode(c)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 8)
+	buf[0] = op
+	buf[1] = syncDestroyCounter
+	xgb.Put16(buf[2:], 2)
+	xgb.Put32(buf[4:], uint32(counter))
+
+	cookie := c.NewCookie(true, false)
+	c.NewRequest(buf, cookie)
+	return cookie.Check()
+}
+
+// counterRequest sends a CreateCounter or SetCounter request. Both take a
+// counter followed by a 64 bit value (the high 32 bits first).
+func counterRequest(xu *xgbutil.XUtil, minor byte, counter Counter,
+	value int64) error {
+
+	c := xu.Conn()
+	op, err := opcode(c)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 16)
+	buf[0] = op
+	buf[1] = minor
+	xgb.Put16(buf[2:], 4)
+	xgb.Put32(buf[4:], uint32(counter))
+	xgb.Put32(buf[8:], uint32(value>>32))
+	xgb.Put32(buf[12:], uint32(value))
+
+	cookie := c.NewCookie(true, false)
+	c.NewRequest(buf, cookie)
+	return cookie.Check()
+}
+
+// opcode returns the major opcode of the SYNC extension, or an error if Init
+// hasn't been called.
+func opcode(c *xgb.Conn) (byte, error) {
+	c.ExtLock.RLock()
+	defer c.ExtLock.RUnlock()
+
+	op, ok := c.Extensions[extName]
+	if !ok {
+		return 0, fmt.Errorf("The SYNC extension has not been initialized. " +
+			"Please call xsync.Init first.")
+	}
+	return op, nil
+}
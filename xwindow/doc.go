@@ -29,6 +29,53 @@ pixels tall and map the window, use something like:
 You may also want to use CreateChecked instead of Create if you want to see if
 there was an error when creating a window.
 
+Window protocols
+
+Each window has a WM_PROTOCOLS manager, returned by Window.Protocols, that
+keeps the WM_PROTOCOLS property in sync with the protocols you've added
+handlers for. For example, to close a window gracefully and answer pings from
+the window manager:
+
+	prots := win.Protocols()
+	prots.Delete(func(w *xwindow.Window, tstamp xproto.Timestamp) {
+		w.Destroy()
+	})
+	prots.Ping()
+
+Searching for windows
+
+Search finds windows much like 'xdotool search'. For example, all Firefox
+windows on the third desktop:
+
+	wins, err := xwindow.Search(X, &xwindow.Query{
+		Clients:    true,
+		Class:      regexp.MustCompile("^Firefox$"),
+		HasDesktop: true,
+		Desktop:    2,
+	})
+
+To go from a window manager's frame (e.g., the window clicked by the user) to
+the client window inside of it, use ClientWindow. Toplevel does the opposite.
+
+Tracked windows
+
+Geom is only as current as the last call to Geometry. A window manager that
+needs the state of a window all the time should use Track instead, which keeps
+Geom, the mapped state and a set of properties up to date by listening for
+events on the window:
+
+	tracker, err := win.Track("_NET_WM_NAME", "WM_NAME")
+	if err != nil {
+		log.Fatal(err)
+	}
+	tracker.GeometryFun(func(w *xwindow.Window, old xrect.Rect) {
+		log.Printf("%x moved from %s to %s", w.Id, old, w.Geom)
+	})
+	tracker.PropertyFun(func(w *xwindow.Window, name string) {
+		title, _ := xprop.PropValStr(tracker.Property(name), nil)
+		log.Printf("%s of %x is now '%s'", name, w.Id, title)
+	})
+
 More examples
 
 The xwindow package is used in many of the examples in the examples directory
@@ -0,0 +1,156 @@
+package xwindow
+
+/*
+xwindow/gravity.go contains functions for reparenting window managers that
+translate between the geometry of a client window and the geometry of the
+frame around it, according to the client's window gravity.
+
+Section 4.1.2.3 of the ICCCM says that the window manager should position
+the frame so that the reference point of the frame (given by the gravity)
+is at the same position as the reference point of the client window,
+including its border. For example, with SouthEast gravity, the bottom right
+corner of the frame is where the client expected its bottom right corner to
+be. With Static gravity, the frame is positioned so that the client window
+itself doesn't move.
+*/
+
+import (
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xrect"
+)
+
+// FrameGeometry returns the geometry of the frame that should surround a
+// client window with the geometry 'client', border width 'borderWidth' and
+// window gravity 'gravity'. (The gravity is usually the WinGravity field of
+// icccm.NormalHints.) The position of 'client' is the position that the
+// client asked for, i.e., of the outside corner of its border.
+// 'extents' is the size of the frame decorations on each side, and may be nil
+// if there are no decorations.
+// This is what a window manager uses when it first maps a client.
+func FrameGeometry(gravity int, client xrect.Rect, borderWidth int,
+	extents *ewmh.FrameExtents) xrect.Rect {
+
+	left, right, top, bottom := extentPieces(extents)
+	x, y, w, h := xrect.Pieces(client)
+	fw, fh := w+left+right, h+top+bottom
+
+	if gravity == xproto.GravityStatic {
+		return xrect.New(x+borderWidth-left, y+borderWidth-top, fw, fh)
+	}
+
+	gx, gy := gravityFactors(gravity)
+	cw, ch := w+2*borderWidth, h+2*borderWidth
+	return xrect.New(x+gx*(cw-fw)/2, y+gy*(ch-fh)/2, fw, fh)
+}
+
+// ClientGeometry is the inverse of FrameGeometry. It takes the geometry of a
+// frame and returns the geometry of the client window inside it, with the
+// position adjusted for gravity. That is, the position returned is where the
+// client window would be if the frame were removed.
+// This is what a window manager uses when it unmanages a client (so that the
+// client stays put when the window manager exits) and to report positions in
+// synthetic ConfigureNotify events.
+func ClientGeometry(gravity int, frame xrect.Rect, borderWidth int,
+	extents *ewmh.FrameExtents) xrect.Rect {
+
+	left, right, top, bottom := extentPieces(extents)
+	fx, fy, fw, fh := xrect.Pieces(frame)
+	w, h := fw-left-right, fh-top-bottom
+
+	if gravity == xproto.GravityStatic {
+		return xrect.New(fx+left-borderWidth, fy+top-borderWidth, w, h)
+	}
+
+	gx, gy := gravityFactors(gravity)
+	cw, ch := w+2*borderWidth, h+2*borderWidth
+	return xrect.New(fx-gx*(cw-fw)/2, fy-gy*(ch-fh)/2, w, h)
+}
+
+// ConfigureRequestFrame computes the new geometry of a frame in response to
+// a ConfigureRequest event sent by the client inside it. 'frame' is the
+// current geometry of the frame, and 'borderWidth' is the current border
+// width of the client. (If the request includes a new border width, it is
+// used instead.)
+//
+// Any of x, y, width and height missing from the request keep their current
+// values. When the client is resized without being moved, the reference point
+// of its gravity is kept in place. For example, a client with SouthEast
+// gravity that gets bigger will grow up and to the left.
+//
+// Note that the size is not checked against the client's size hints. Use
+// icccm.ConstrainSize on the client size for that.
+func ConfigureRequestFrame(ev xevent.ConfigureRequestEvent, gravity int,
+	frame xrect.Rect, borderWidth int,
+	extents *ewmh.FrameExtents) xrect.Rect {
+
+	cur := ClientGeometry(gravity, frame, borderWidth, extents)
+	x, y, w, h := xrect.Pieces(cur)
+	bw := borderWidth
+
+	if ev.ValueMask&xproto.ConfigWindowBorderWidth > 0 {
+		bw = int(ev.BorderWidth)
+	}
+	if ev.ValueMask&xproto.ConfigWindowWidth > 0 {
+		w = int(ev.Width)
+	}
+	if ev.ValueMask&xproto.ConfigWindowHeight > 0 {
+		h = int(ev.Height)
+	}
+
+	// Keep the gravity's reference point where it is by moving the client by
+	// the change in its (outer) size, unless the client gave a position.
+	gx, gy := gravityFactors(gravity)
+	if gravity == xproto.GravityStatic {
+		gx, gy = 0, 0
+	}
+	if ev.ValueMask&xproto.ConfigWindowX > 0 {
+		x = int(ev.X)
+	} else {
+		x -= gx * ((w + 2*bw) - (cur.Width() + 2*borderWidth)) / 2
+	}
+	if ev.ValueMask&xproto.ConfigWindowY > 0 {
+		y = int(ev.Y)
+	} else {
+		y -= gy * ((h + 2*bw) - (cur.Height() + 2*borderWidth)) / 2
+	}
+
+	return FrameGeometry(gravity, xrect.New(x, y, w, h), bw, extents)
+}
+
+// gravityFactors returns how far along each axis the reference point of a
+// gravity is, in halves of the window size. i.e., 0 for the west/north edge,
+// 1 for the center and 2 for the east/south edge. Unknown gravities (and the
+// 'forget'/'unmap' gravity of 0) are treated as NorthWest.
+func gravityFactors(gravity int) (int, int) {
+	switch gravity {
+	case xproto.GravityNorth:
+		return 1, 0
+	case xproto.GravityNorthEast:
+		return 2, 0
+	case xproto.GravityWest:
+		return 0, 1
+	case xproto.GravityCenter:
+		return 1, 1
+	case xproto.GravityEast:
+		return 2, 1
+	case xproto.GravitySouthWest:
+		return 0, 2
+	case xproto.GravitySouth:
+		return 1, 2
+	case xproto.GravitySouthEast:
+		return 2, 2
+	}
+	return 0, 0
+}
+
+// extentPieces returns the left, right, top and bottom frame extents, or all
+// zeroes if 'extents' is nil.
+func extentPieces(extents *ewmh.FrameExtents) (int, int, int, int) {
+	if extents == nil {
+		return 0, 0, 0, 0
+	}
+	return extents.Left, extents.Right, extents.Top, extents.Bottom
+}
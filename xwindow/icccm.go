@@ -1,11 +1,13 @@
 package xwindow
 
 import (
+	"fmt"
+
 	"github.com/BurntSushi/xgb/xproto"
 
 	"github.com/BurntSushi/xgbutil"
 	"github.com/BurntSushi/xgbutil/icccm"
-	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
 )
 
 // WMGracefulClose will do all the necessary setup to implement the
@@ -17,31 +19,14 @@ import (
 // asks you to close your window. (You may provide some means of confirmation
 // to the user, i.e., "Do you really want to quit?", but you should probably
 // just wrap things up and call DestroyWindow.)
+// This is a shortcut for w.Protocols().Delete.
 func (w *Window) WMGracefulClose(cb func(w *Window)) {
-	// Get the current protocols so we don't overwrite anything.
-	prots, _ := icccm.WmProtocolsGet(w.X, w.Id)
-
-	// If WM_DELETE_WINDOW isn't here, add it. Otherwise, move on.
-	wmdelete := false
-	for _, prot := range prots {
-		if prot == "WM_DELETE_WINDOW" {
-			wmdelete = true
-			break
-		}
-	}
-	if !wmdelete {
-		icccm.WmProtocolsSet(w.X, w.Id, append(prots, "WM_DELETE_WINDOW"))
+	err := w.Protocols().Delete(func(w *Window, tstamp xproto.Timestamp) {
+		cb(w)
+	})
+	if err != nil {
+		xgbutil.Logger.Println(err)
 	}
-
-	// Attach a ClientMessage event handler. It will determine whether the
-	// ClientMessage is a 'close' request, and if so, run the callback 'cb'
-	// provided.
-	xevent.ClientMessageFun(
-		func(X *xgbutil.XUtil, ev xevent.ClientMessageEvent) {
-			if icccm.IsDeleteProtocol(X, ev) {
-				cb(w)
-			}
-		}).Connect(w.X, w.Id)
 }
 
 // WMTakeFocus will do all the necessary setup to support the WM_TAKE_FOCUS
@@ -53,6 +38,8 @@ func (w *Window) WMGracefulClose(cb func(w *Window)) {
 // Typically, the callback function should include a call to SetInputFocus
 // with the "Parent" InputFocus type, the sub-window id of the window that
 // should have focus, and the 'tstamp' timestamp.
+// Besides setting the Input flag in WM_HINTS, this is a shortcut for
+// w.Protocols().TakeFocus.
 func (w *Window) WMTakeFocus(cb func(w *Window, tstamp xproto.Timestamp)) {
 	// Make sure the Input flag is set to true in WM_HINTS. We first
 	// must retrieve the current WM_HINTS, so we don't overwrite the flags.
@@ -65,28 +52,103 @@ func (w *Window) WMTakeFocus(cb func(w *Window, tstamp xproto.Timestamp)) {
 		Input: 1,
 	})
 
-	// Get the current protocols so we don't overwrite anything.
-	prots, _ := icccm.WmProtocolsGet(w.X, w.Id)
+	if err := w.Protocols().TakeFocus(cb); err != nil {
+		xgbutil.Logger.Println(err)
+	}
+}
+
+// ClientWindow finds the client window managed by the window manager at or
+// below this window, using the same logic as XmuClientWindow. That is, if
+// this window has the WM_STATE property, it is returned. Otherwise, its
+// children are checked for WM_STATE, and then the subtree of each child is
+// searched in the same way, one child at a time. The first window found with
+// WM_STATE is returned, or this window if there is none.
+//
+// This is typically used to go from a window manager's frame (e.g., the
+// window under the pointer) to the client window it contains.
+func (w *Window) ClientWindow() (*Window, error) {
+	wmState, err := xprop.Atm(w.X, "WM_STATE")
+	if err != nil {
+		return nil, err
+	}
+	if w.withWmState(wmState, []xproto.Window{w.Id}) != 0 {
+		return w, nil
+	}
+
+	tree, err := xproto.QueryTree(w.X.Conn(), w.Id).Reply()
+	if err != nil {
+		return nil, fmt.Errorf("ClientWindow: Could not query the children "+
+			"of %x: %s", w.Id, err)
+	}
+	if found := w.tryChildren(wmState, tree.Children); found != 0 {
+		return New(w.X, found), nil
+	}
+	return w, nil
+}
+
+// tryChildren is TryChildren from Xmu: it returns the first of 'children'
+// with WM_STATE, or else the first window with WM_STATE in the subtree of
+// each child in turn. It returns 0 if there is no such window.
+func (w *Window) tryChildren(wmState xproto.Atom,
+	children []xproto.Window) xproto.Window {
 
-	// If WM_TAKE_FOCUS isn't here, add it. Otherwise, move on.
-	wmfocus := false
-	for _, prot := range prots {
-		if prot == "WM_TAKE_FOCUS" {
-			wmfocus = true
-			break
+	if found := w.withWmState(wmState, children); found != 0 {
+		return found
+	}
+	for _, child := range children {
+		tree, err := xproto.QueryTree(w.X.Conn(), child).Reply()
+		if err != nil {
+			continue // the child is gone
+		}
+		if found := w.tryChildren(wmState, tree.Children); found != 0 {
+			return found
 		}
 	}
-	if !wmfocus {
-		icccm.WmProtocolsSet(w.X, w.Id, append(prots, "WM_TAKE_FOCUS"))
+	return 0
+}
+
+// withWmState returns the first of 'wins' that has WM_STATE, or 0. The
+// requests for all windows are sent at once.
+func (w *Window) withWmState(wmState xproto.Atom,
+	wins []xproto.Window) xproto.Window {
+
+	cookies := make([]xproto.GetPropertyCookie, len(wins))
+	for i, win := range wins {
+		cookies[i] = xproto.GetProperty(w.X.Conn(), false, win, wmState,
+			xproto.GetPropertyTypeAny, 0, 0)
+	}
+	found := xproto.Window(0)
+	for i, cookie := range cookies {
+		reply, err := cookie.Reply()
+		if err == nil && reply.Type != 0 && found == 0 {
+			found = wins[i]
+		}
+	}
+	return found
+}
+
+// Toplevel finds the top-level window containing this window. That is, the
+// ancestor of this window (or this window itself) whose parent is the root
+// window. For a client window managed by a reparenting window manager, this
+// is the outermost frame of the window manager. This is the inverse of
+// ClientWindow.
+func (w *Window) Toplevel() (*Window, error) {
+	root := w.X.RootWin()
+	if w.Id == root {
+		return nil, fmt.Errorf("Toplevel: The root window has no top-level " +
+			"window.")
 	}
 
-	// Attach a ClientMessage event handler. It will determine whether the
-	// ClientMessage is a 'focus' request, and if so, run the callback 'cb'
-	// provided.
-	xevent.ClientMessageFun(
-		func(X *xgbutil.XUtil, ev xevent.ClientMessageEvent) {
-			if icccm.IsFocusProtocol(X, ev) {
-				cb(w, xproto.Timestamp(ev.Data.Data32[1]))
-			}
-		}).Connect(w.X, w.Id)
+	cur := w
+	for {
+		parent, err := cur.Parent()
+		if err != nil {
+			return nil, fmt.Errorf("Toplevel: Could not find the top-level "+
+				"window of %x: %s", w.Id, err)
+		}
+		if parent.Id == root {
+			return cur, nil
+		}
+		cur = parent
+	}
 }
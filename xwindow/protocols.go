@@ -0,0 +1,265 @@
+package xwindow
+
+/*
+xwindow/protocols.go contains a manager for the WM_PROTOCOLS of a window. It
+keeps the WM_PROTOCOLS property in sync with the protocols that have handlers,
+and routes each WM_PROTOCOLS client message to the right handler.
+*/
+
+import (
+	"sync"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/icccm"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// Protocols manages the WM_PROTOCOLS supported by a window. Handlers are
+// registered with Delete, TakeFocus, Ping and SyncRequest. Whenever a handler
+// is added or removed, the WM_PROTOCOLS property is updated to match.
+// Protocols in WM_PROTOCOLS that weren't added with a Protocols value are
+// left alone.
+//
+// Each Window value has its own Protocols value, which is returned by
+// Window.Protocols. (Several Window values for the same window may each add
+// their own protocols; WM_PROTOCOLS holds all of them, and a protocol stays in
+// WM_PROTOCOLS until none of them handle it.)
+type Protocols struct {
+	win      *Window
+	lck      *sync.Mutex
+	handlers map[string]func(ev xevent.ClientMessageEvent)
+
+	// dispatcher is attached with xevent.Attach. It also lets us find the
+	// other Protocols values of the same window among its callbacks.
+	dispatcher *protocolsDispatcher
+}
+
+// protocolsDispatcher is the ClientMessage callback of a Protocols value.
+type protocolsDispatcher struct {
+	p *Protocols
+}
+
+func (d *protocolsDispatcher) Connect(xu *xgbutil.XUtil, win xproto.Window) {
+	xevent.Attach(xu, xevent.ClientMessage, win, d)
+}
+
+func (d *protocolsDispatcher) Run(xu *xgbutil.XUtil, ev interface{}) {
+	d.p.dispatch(ev.(xevent.ClientMessageEvent))
+}
+
+// Protocols returns the WM_PROTOCOLS manager for this window. It is created
+// (and its ClientMessage handler attached) the first time it is requested.
+// Stop, Detach and Destroy remove it.
+func (w *Window) Protocols() *Protocols {
+	if w.protocols != nil {
+		return w.protocols
+	}
+
+	p := &Protocols{
+		win:      w,
+		lck:      &sync.Mutex{},
+		handlers: make(map[string]func(ev xevent.ClientMessageEvent)),
+	}
+	p.dispatcher = &protocolsDispatcher{p}
+	p.dispatcher.Connect(w.X, w.Id)
+	w.protocols = p
+	return p
+}
+
+// Stop removes every handler and the ClientMessage handler of the manager,
+// and removes its protocols from WM_PROTOCOLS. The next call to
+// Window.Protocols creates a new manager.
+func (p *Protocols) Stop() error {
+	w := p.win
+	xevent.Disconnect(w.X, xevent.ClientMessage, w.Id, p.dispatcher)
+	if w.protocols == p {
+		w.protocols = nil
+	}
+
+	p.lck.Lock()
+	names := make([]string, 0, len(p.handlers))
+	for name := range p.handlers {
+		names = append(names, name)
+	}
+	p.handlers = make(map[string]func(ev xevent.ClientMessageEvent))
+	p.lck.Unlock()
+
+	for _, name := range names {
+		if err := p.release(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete sets the handler for the WM_DELETE_WINDOW protocol, which is called
+// when the window manager asks us to close the window.
+func (p *Protocols) Delete(cb func(w *Window, tstamp xproto.Timestamp)) error {
+	return p.add("WM_DELETE_WINDOW", func(ev xevent.ClientMessageEvent) {
+		cb(p.win, xproto.Timestamp(ev.Data.Data32[1]))
+	})
+}
+
+// TakeFocus sets the handler for the WM_TAKE_FOCUS protocol, which is called
+// when the window manager wants us to set the input focus ourselves. The
+// handler should set focus to one of our windows using 'tstamp'.
+func (p *Protocols) TakeFocus(
+	cb func(w *Window, tstamp xproto.Timestamp)) error {
+
+	return p.add("WM_TAKE_FOCUS", func(ev xevent.ClientMessageEvent) {
+		cb(p.win, xproto.Timestamp(ev.Data.Data32[1]))
+	})
+}
+
+// Ping adds support for the _NET_WM_PING protocol. Pings from the window
+// manager are answered automatically, which tells the window manager that
+// we're still responsive.
+func (p *Protocols) Ping() error {
+	return p.add("_NET_WM_PING", func(ev xevent.ClientMessageEvent) {
+		err := ewmh.WmPingExtra(p.win.X, xproto.Window(ev.Data.Data32[2]),
+			true, xproto.Timestamp(ev.Data.Data32[1]))
+		if err != nil {
+			xgbutil.Logger.Printf("Could not respond to _NET_WM_PING: %s", err)
+		}
+	})
+}
+
+// SyncRequest sets the handler for the _NET_WM_SYNC_REQUEST protocol. It is
+// called before the window manager resizes the window, with the value that
+// the _NET_WM_SYNC_REQUEST_COUNTER should be set to once the window has been
+// redrawn at its new size.
+func (p *Protocols) SyncRequest(
+	cb func(w *Window, tstamp xproto.Timestamp, value uint64)) error {
+
+	return p.add("_NET_WM_SYNC_REQUEST", func(ev xevent.ClientMessageEvent) {
+		d := ev.Data.Data32
+		cb(p.win, xproto.Timestamp(d[1]), uint64(d[3])<<32|uint64(d[2]))
+	})
+}
+
+// SyncRequestExtended is like SyncRequest, except that the handler is also
+// told whether the request is for the extended counter. (Window managers only
+// send such requests to windows that set two counters in
+// _NET_WM_SYNC_REQUEST_COUNTER.) It replaces any handler set by SyncRequest.
+func (p *Protocols) SyncRequestExtended(cb func(w *Window,
+	tstamp xproto.Timestamp, value uint64, extended bool)) error {
+
+	return p.add("_NET_WM_SYNC_REQUEST", func(ev xevent.ClientMessageEvent) {
+		d := ev.Data.Data32
+		cb(p.win, xproto.Timestamp(d[1]), uint64(d[3])<<32|uint64(d[2]),
+			d[4] != 0)
+	})
+}
+
+// Remove removes the handler for the protocol 'name' (e.g.,
+// "WM_DELETE_WINDOW") and removes the protocol from WM_PROTOCOLS, unless
+// another Protocols value of the same window still handles it.
+func (p *Protocols) Remove(name string) error {
+	p.lck.Lock()
+	_, ok := p.handlers[name]
+	delete(p.handlers, name)
+	p.lck.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return p.release(name)
+}
+
+// release removes 'name' from WM_PROTOCOLS if no other Protocols value
+// attached to the window handles it. (Our own lock mustn't be held, since
+// the others' locks are taken.)
+func (p *Protocols) release(name string) error {
+	X, win := p.win.X, p.win.Id
+
+	// Callbacks are copied on write, so the slice can be used unlocked.
+	X.CallbacksLck.RLock()
+	cbs := X.Callbacks[xevent.ClientMessage][win]
+	X.CallbacksLck.RUnlock()
+
+	for _, cb := range cbs {
+		d, ok := cb.(*protocolsDispatcher)
+		if ok && d.p != p && d.p.Supported(name) {
+			return nil
+		}
+	}
+
+	p.lck.Lock()
+	defer p.lck.Unlock()
+	return p.update(name, false)
+}
+
+// Supported returns whether a handler has been set for the protocol 'name'.
+func (p *Protocols) Supported(name string) bool {
+	p.lck.Lock()
+	defer p.lck.Unlock()
+
+	_, ok := p.handlers[name]
+	return ok
+}
+
+// add sets the handler for the protocol 'name', replacing any existing
+// handler, and makes sure 'name' is in WM_PROTOCOLS.
+func (p *Protocols) add(name string,
+	handler func(ev xevent.ClientMessageEvent)) error {
+
+	p.lck.Lock()
+	defer p.lck.Unlock()
+
+	p.handlers[name] = handler
+	return p.update(name, true)
+}
+
+// update adds 'name' to or removes 'name' from the WM_PROTOCOLS property,
+// keeping everything else in the property as it is.
+// The property is only written if it changes.
+func (p *Protocols) update(name string, present bool) error {
+	cur, _ := icccm.WmProtocolsGet(p.win.X, p.win.Id)
+
+	prots := make([]string, 0, len(cur)+1)
+	found := false
+	for _, prot := range cur {
+		if prot == name {
+			found = true
+			if !present {
+				continue
+			}
+		}
+		prots = append(prots, prot)
+	}
+	if found == present {
+		return nil
+	}
+	if present {
+		prots = append(prots, name)
+	}
+	return icccm.WmProtocolsSet(p.win.X, p.win.Id, prots)
+}
+
+// dispatch runs the handler for a WM_PROTOCOLS client message, if there is
+// one. Other client messages are ignored.
+func (p *Protocols) dispatch(ev xevent.ClientMessageEvent) {
+	if ev.Format != 32 {
+		return
+	}
+	typeName, err := xprop.AtomName(p.win.X, ev.Type)
+	if err != nil || typeName != "WM_PROTOCOLS" {
+		return
+	}
+	name, err := xprop.AtomName(p.win.X, xproto.Atom(ev.Data.Data32[0]))
+	if err != nil {
+		return
+	}
+
+	p.lck.Lock()
+	handler, ok := p.handlers[name]
+	p.lck.Unlock()
+
+	if ok {
+		handler(ev)
+	}
+}
@@ -0,0 +1,304 @@
+package xwindow
+
+/*
+xwindow/search.go contains Search, which finds windows by their names,
+classes, process ids, types, desktops, visibility and geometry, much like
+'xdotool search'.
+
+All requests for the windows being looked at are sent before any of the
+replies are read, so that a search over the whole tree takes a handful of
+round trips rather than several per window.
+*/
+
+import (
+	"regexp"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/xprop"
+	"github.com/BurntSushi/xgbutil/xrect"
+)
+
+// Query describes the windows returned by Search. A window must match every
+// criterion that is set; the zero value of a field matches every window.
+type Query struct {
+	// Clients restricts the search to the windows in _NET_CLIENT_LIST.
+	// Otherwise, the whole window tree is searched (up to MaxDepth levels
+	// below the root window, if MaxDepth isn't 0).
+	Clients  bool
+	MaxDepth int
+
+	// Name is matched against _NET_WM_NAME, or WM_NAME if _NET_WM_NAME isn't
+	// set. Instance and Class are matched against the two parts of WM_CLASS.
+	Name     *regexp.Regexp
+	Instance *regexp.Regexp
+	Class    *regexp.Regexp
+
+	// Pid is the process id in _NET_WM_PID.
+	Pid uint
+
+	// Types matches windows with any of the given _NET_WM_WINDOW_TYPE values
+	// (e.g., "_NET_WM_WINDOW_TYPE_DOCK").
+	Types []string
+
+	// Desktop is the desktop in _NET_WM_DESKTOP, and is only checked if
+	// HasDesktop is true. Windows on all desktops (0xFFFFFFFF) always match.
+	HasDesktop bool
+	Desktop    uint
+
+	// Visible only matches windows that are viewable (mapped, along with all
+	// of their ancestors).
+	Visible bool
+
+	// Area only matches windows that overlap it, in root window coordinates.
+	// MinWidth and MinHeight only match windows at least that large.
+	Area      xrect.Rect
+	MinWidth  int
+	MinHeight int
+
+	// Limit is the maximum number of windows returned, if it isn't 0.
+	Limit int
+}
+
+// Search returns the windows matching 'q'. When searching the window tree,
+// windows are returned level by level (parents before their children), and
+// in stacking order (bottom to top) within each level. When searching
+// _NET_CLIENT_LIST, windows are in the order of that property.
+//
+// Windows that are destroyed during the search are skipped.
+func Search(xu *xgbutil.XUtil, q *Query) ([]*Window, error) {
+	var wins []xproto.Window
+	var err error
+	if q.Clients {
+		wins, err = ewmh.ClientListGet(xu)
+	} else {
+		wins, err = searchTree(xu, q.MaxDepth)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	props, err := q.properties(xu)
+	if err != nil {
+		return nil, err
+	}
+	needGeom := q.Area != nil || q.MinWidth > 0 || q.MinHeight > 0
+
+	// Send every request first, and only then read the replies.
+	cookies := make([]searchCookies, len(wins))
+	for i, win := range wins {
+		c := &cookies[i]
+		c.props = make(map[string]xproto.GetPropertyCookie, len(props))
+		for name, atom := range props {
+			c.props[name] = xproto.GetProperty(xu.Conn(), false, win, atom,
+				xproto.GetPropertyTypeAny, 0, (1<<32)-1)
+		}
+		if q.Visible {
+			c.attrs = xproto.GetWindowAttributes(xu.Conn(), win)
+		}
+		if needGeom {
+			c.geom = xproto.GetGeometry(xu.Conn(), xproto.Drawable(win))
+			c.trans = xproto.TranslateCoordinates(xu.Conn(), win,
+				xu.RootWin(), 0, 0)
+		}
+	}
+
+	found := make([]*Window, 0)
+	for i, win := range wins {
+		if q.Limit > 0 && len(found) >= q.Limit {
+			break
+		}
+		if q.match(xu, &cookies[i], needGeom) {
+			found = append(found, New(xu, win))
+		}
+	}
+	return found, nil
+}
+
+// searchCookies are the requests sent for one window by Search.
+type searchCookies struct {
+	props map[string]xproto.GetPropertyCookie
+	attrs xproto.GetWindowAttributesCookie
+	geom  xproto.GetGeometryCookie
+	trans xproto.TranslateCoordinatesCookie
+}
+
+// searchTree returns every window below the root window, level by level,
+// going at most 'maxDepth' levels deep (or all the way if it's 0). The
+// QueryTree requests for a level are all sent at once.
+func searchTree(xu *xgbutil.XUtil, maxDepth int) ([]xproto.Window, error) {
+	wins := make([]xproto.Window, 0)
+	level := []xproto.Window{xu.RootWin()}
+	for depth := 1; len(level) > 0; depth++ {
+		if maxDepth > 0 && depth > maxDepth {
+			break
+		}
+
+		cookies := make([]xproto.QueryTreeCookie, len(level))
+		for i, win := range level {
+			cookies[i] = xproto.QueryTree(xu.Conn(), win)
+		}
+		next := make([]xproto.Window, 0)
+		for i, cookie := range cookies {
+			reply, err := cookie.Reply()
+			if err != nil {
+				// The root window must be there. Others may have been
+				// destroyed in the meantime.
+				if level[i] == xu.RootWin() {
+					return nil, err
+				}
+				continue
+			}
+			next = append(next, reply.Children...)
+		}
+		wins = append(wins, next...)
+		level = next
+	}
+	return wins, nil
+}
+
+// properties returns the atoms of the properties needed to check 'q'.
+func (q *Query) properties(xu *xgbutil.XUtil) (map[string]xproto.Atom, error) {
+	names := make([]string, 0, 4)
+	if q.Name != nil {
+		names = append(names, "_NET_WM_NAME", "WM_NAME")
+	}
+	if q.Instance != nil || q.Class != nil {
+		names = append(names, "WM_CLASS")
+	}
+	if q.Pid > 0 {
+		names = append(names, "_NET_WM_PID")
+	}
+	if len(q.Types) > 0 {
+		names = append(names, "_NET_WM_WINDOW_TYPE")
+	}
+	if q.HasDesktop {
+		names = append(names, "_NET_WM_DESKTOP")
+	}
+
+	props := make(map[string]xproto.Atom, len(names))
+	for _, name := range names {
+		atom, err := xprop.Atm(xu, name)
+		if err != nil {
+			return nil, err
+		}
+		props[name] = atom
+	}
+	return props, nil
+}
+
+// match reads the replies in 'c' and returns whether the window matches 'q'.
+func (q *Query) match(xu *xgbutil.XUtil, c *searchCookies,
+	needGeom bool) bool {
+
+	replies := make(map[string]*xproto.GetPropertyReply, len(c.props))
+	for name, cookie := range c.props {
+		reply, err := cookie.Reply()
+		if err == nil && reply.Format != 0 {
+			replies[name] = reply
+		}
+	}
+	var attrs *xproto.GetWindowAttributesReply
+	if q.Visible {
+		var err error
+		if attrs, err = c.attrs.Reply(); err != nil {
+			return false
+		}
+	}
+	var geom xrect.Rect
+	if needGeom {
+		g, err := c.geom.Reply()
+		t, terr := c.trans.Reply()
+		if err != nil || terr != nil {
+			return false
+		}
+		geom = xrect.New(int(t.DstX), int(t.DstY),
+			int(g.Width), int(g.Height))
+	}
+
+	if q.Name != nil {
+		reply, ok := replies["_NET_WM_NAME"]
+		if !ok {
+			reply = replies["WM_NAME"]
+		}
+		if reply == nil {
+			return false
+		}
+		name, err := xprop.PropValStr(reply, nil)
+		if err != nil || !q.Name.MatchString(name) {
+			return false
+		}
+	}
+	if q.Instance != nil || q.Class != nil {
+		reply := replies["WM_CLASS"]
+		if reply == nil {
+			return false
+		}
+		class, err := xprop.PropValStrs(reply, nil)
+		if err != nil || len(class) != 2 {
+			return false
+		}
+		if q.Instance != nil && !q.Instance.MatchString(class[0]) {
+			return false
+		}
+		if q.Class != nil && !q.Class.MatchString(class[1]) {
+			return false
+		}
+	}
+	if q.Pid > 0 {
+		reply := replies["_NET_WM_PID"]
+		if reply == nil {
+			return false
+		}
+		pid, err := xprop.PropValNum(reply, nil)
+		if err != nil || pid != q.Pid {
+			return false
+		}
+	}
+	if len(q.Types) > 0 {
+		reply := replies["_NET_WM_WINDOW_TYPE"]
+		if reply == nil {
+			return false
+		}
+		types, err := xprop.PropValAtoms(xu, reply, nil)
+		if err != nil || !anyOf(types, q.Types) {
+			return false
+		}
+	}
+	if q.HasDesktop {
+		reply := replies["_NET_WM_DESKTOP"]
+		if reply == nil {
+			return false
+		}
+		desk, err := xprop.PropValNum(reply, nil)
+		if err != nil || (desk != q.Desktop && desk != 0xFFFFFFFF) {
+			return false
+		}
+	}
+	if q.Visible && attrs.MapState != xproto.MapStateViewable {
+		return false
+	}
+	if needGeom {
+		if q.Area != nil && xrect.IntersectArea(q.Area, geom) == 0 {
+			return false
+		}
+		if geom.Width() < q.MinWidth || geom.Height() < q.MinHeight {
+			return false
+		}
+	}
+	return true
+}
+
+// anyOf returns whether any string in 'haystack' is in 'needles'.
+func anyOf(haystack, needles []string) bool {
+	for _, s := range haystack {
+		for _, needle := range needles {
+			if s == needle {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,414 @@
+package xwindow
+
+/*
+xwindow/track.go contains the tracked mode of a window. A tracked window
+listens for its own ConfigureNotify, ReparentNotify, MapNotify, UnmapNotify,
+DestroyNotify and PropertyNotify events, and keeps its geometry, mapped state
+and a chosen set of properties up to date, so that they can be read without
+asking the X server again.
+*/
+
+import (
+	"sync"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xprop"
+	"github.com/BurntSushi/xgbutil/xrect"
+)
+
+// Tracker keeps the state of a tracked window current. It is returned by
+// Window.Track.
+//
+// The geometry is stored in the Geom member of the Window, and is always
+// relative to the window's parent (just like Geometry). Window managers also
+// send synthetic ConfigureNotify events in root coordinates (see ICCCM
+// 4.1.5), which can't be told apart from real ones. So when a window that
+// isn't a child of the root window gets a ConfigureNotify event with a new
+// position, its position is read again with GetGeometry, at the cost of a
+// round trip to the X server. (The size, and the position in all other
+// events, are taken as they are.) Note that Configure (along with Move,
+// Resize, etc.) still updates Geom right away; the geometry that the window
+// actually gets is stored when its ConfigureNotify event arrives. (Window
+// managers send one even when they refuse a request.)
+//
+// Change handlers are run by xgbutil's main event loop (see xevent.Main),
+// after the tracked state has been updated.
+type Tracker struct {
+	win *Window
+	lck *sync.Mutex
+
+	parent xproto.Window
+	mapped bool
+	props  map[string]*xproto.GetPropertyReply
+
+	geomFuns    []func(w *Window, old xrect.Rect)
+	mapFuns     []func(w *Window, mapped bool)
+	propFuns    []func(w *Window, name string)
+	destroyFuns []func(w *Window)
+
+	// callbacks attached with xevent.Attach
+	configureFun xevent.ConfigureNotifyFun
+	reparentFun  xevent.ReparentNotifyFun
+	mapFun       xevent.MapNotifyFun
+	unmapFun     xevent.UnmapNotifyFun
+	propFun      xevent.PropertyNotifyFun
+	destroyFun   xevent.DestroyNotifyFun
+}
+
+// Track turns on the tracked mode of this window, and returns its Tracker.
+// The geometry and mapped state are loaded, and the properties 'props'
+// (e.g., "_NET_WM_NAME") are loaded and tracked from then on.
+//
+// The Tracker is created (and its event handlers attached) the first time
+// Track is called on this Window value, so a tracked window should only have
+// one Window value. Later calls only add to the tracked properties. Stop,
+// Detach and Destroy turn tracking off.
+func (w *Window) Track(props ...string) (*Tracker, error) {
+	if w.tracker != nil {
+		return w.tracker, w.tracker.PropertyTrack(props...)
+	}
+
+	err := w.ListenAdd(xproto.EventMaskStructureNotify,
+		xproto.EventMaskPropertyChange)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ask for the geometry, the parent and the attributes at the same time.
+	geomCookie := xproto.GetGeometry(w.X.Conn(), xproto.Drawable(w.Id))
+	treeCookie := xproto.QueryTree(w.X.Conn(), w.Id)
+	attrsCookie := xproto.GetWindowAttributes(w.X.Conn(), w.Id)
+	geom, err := geomCookie.Reply()
+	if err != nil {
+		return nil, err
+	}
+	tree, err := treeCookie.Reply()
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := attrsCookie.Reply()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tracker{
+		win:    w,
+		lck:    &sync.Mutex{},
+		parent: tree.Parent,
+		mapped: attrs.MapState != xproto.MapStateUnmapped,
+		props:  make(map[string]*xproto.GetPropertyReply),
+	}
+	w.Geom = xrect.New(int(geom.X), int(geom.Y),
+		int(geom.Width), int(geom.Height))
+	if err := t.PropertyTrack(props...); err != nil {
+		return nil, err
+	}
+	t.connect()
+	w.tracker = t
+	return t, nil
+}
+
+// Window returns the tracked window.
+func (t *Tracker) Window() *Window {
+	return t.win
+}
+
+// Stop turns off the tracked mode of the window.
+func (t *Tracker) Stop() {
+	w := t.win
+	xevent.Disconnect(w.X, xevent.ConfigureNotify, w.Id, &t.configureFun)
+	xevent.Disconnect(w.X, xevent.ReparentNotify, w.Id, &t.reparentFun)
+	xevent.Disconnect(w.X, xevent.MapNotify, w.Id, &t.mapFun)
+	xevent.Disconnect(w.X, xevent.UnmapNotify, w.Id, &t.unmapFun)
+	xevent.Disconnect(w.X, xevent.PropertyNotify, w.Id, &t.propFun)
+	xevent.Disconnect(w.X, xevent.DestroyNotify, w.Id, &t.destroyFun)
+	if w.tracker == t {
+		w.tracker = nil
+	}
+}
+
+// Mapped returns whether the window is mapped.
+func (t *Tracker) Mapped() bool {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	return t.mapped
+}
+
+// PropertyTrack loads the properties 'names' and keeps them up to date.
+// Properties that are already tracked are left alone.
+func (t *Tracker) PropertyTrack(names ...string) error {
+	X, win := t.win.X, t.win.Id
+
+	// Ask for every new property at once.
+	fresh := make([]string, 0, len(names))
+	cookies := make([]xproto.GetPropertyCookie, 0, len(names))
+	for _, name := range names {
+		if t.tracked(name) {
+			continue
+		}
+		atom, err := xprop.Atm(X, name)
+		if err != nil {
+			return err
+		}
+		fresh = append(fresh, name)
+		cookies = append(cookies, xproto.GetProperty(X.Conn(), false, win,
+			atom, xproto.GetPropertyTypeAny, 0, (1<<32)-1))
+	}
+
+	replies := make([]*xproto.GetPropertyReply, len(cookies))
+	for i, cookie := range cookies {
+		reply, err := cookie.Reply()
+		if err != nil {
+			return err
+		}
+		if reply.Format != 0 {
+			replies[i] = reply
+		}
+	}
+
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	for i, name := range fresh {
+		t.props[name] = replies[i]
+	}
+	return nil
+}
+
+// Property returns the current value of the tracked property 'name', or nil
+// if the property isn't set (or isn't tracked). The reply can be decoded with
+// the PropVal functions in xprop, e.g., xprop.PropValStr(t.Property(name),
+// nil). It must not be modified.
+func (t *Tracker) Property(name string) *xproto.GetPropertyReply {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	return t.props[name]
+}
+
+// GeometryFun adds a handler that is called whenever the geometry of the
+// window changes, with its previous geometry.
+func (t *Tracker) GeometryFun(cb func(w *Window, old xrect.Rect)) {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	t.geomFuns = append(t.geomFuns, cb)
+}
+
+// MapFun adds a handler that is called whenever the window is mapped or
+// unmapped.
+func (t *Tracker) MapFun(cb func(w *Window, mapped bool)) {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	t.mapFuns = append(t.mapFuns, cb)
+}
+
+// PropertyFun adds a handler that is called whenever one of the tracked
+// properties changes (or is deleted), with the property's name. Its new value
+// is available with Property.
+func (t *Tracker) PropertyFun(cb func(w *Window, name string)) {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	t.propFuns = append(t.propFuns, cb)
+}
+
+// DestroyFun adds a handler that is called when the window is destroyed
+// by someone else (Window.Destroy turns tracking off beforehand). By then,
+// Destroyed is true and tracking has been turned off with Stop. Other event
+// handlers on the window are left to their owners.
+func (t *Tracker) DestroyFun(cb func(w *Window)) {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	t.destroyFuns = append(t.destroyFuns, cb)
+}
+
+// tracked returns whether the property 'name' is tracked.
+func (t *Tracker) tracked(name string) bool {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	_, ok := t.props[name]
+	return ok
+}
+
+// connect attaches the event handlers that keep the tracked state current.
+// Since events about the window may also be sent to its parent, only events
+// whose Window is the tracked window are used.
+func (t *Tracker) connect() {
+	w := t.win
+
+	t.configureFun = func(X *xgbutil.XUtil, ev xevent.ConfigureNotifyEvent) {
+		if ev.Window == w.Id {
+			t.configured(ev)
+		}
+	}
+	xevent.Attach(w.X, xevent.ConfigureNotify, w.Id, &t.configureFun)
+
+	t.reparentFun = func(X *xgbutil.XUtil, ev xevent.ReparentNotifyEvent) {
+		if ev.Window == w.Id {
+			t.reparented(ev)
+		}
+	}
+	xevent.Attach(w.X, xevent.ReparentNotify, w.Id, &t.reparentFun)
+
+	t.mapFun = func(X *xgbutil.XUtil, ev xevent.MapNotifyEvent) {
+		if ev.Window == w.Id {
+			t.mappedSet(true)
+		}
+	}
+	xevent.Attach(w.X, xevent.MapNotify, w.Id, &t.mapFun)
+
+	t.unmapFun = func(X *xgbutil.XUtil, ev xevent.UnmapNotifyEvent) {
+		if ev.Window == w.Id {
+			t.mappedSet(false)
+		}
+	}
+	xevent.Attach(w.X, xevent.UnmapNotify, w.Id, &t.unmapFun)
+
+	t.propFun = func(X *xgbutil.XUtil, ev xevent.PropertyNotifyEvent) {
+		t.property(ev)
+	}
+	xevent.Attach(w.X, xevent.PropertyNotify, w.Id, &t.propFun)
+
+	t.destroyFun = func(X *xgbutil.XUtil, ev xevent.DestroyNotifyEvent) {
+		if ev.Window == w.Id {
+			t.destroyed()
+		}
+	}
+	xevent.Attach(w.X, xevent.DestroyNotify, w.Id, &t.destroyFun)
+}
+
+// configured stores the geometry from a ConfigureNotify event. If the event
+// moves a window whose parent isn't the root window, it may be a synthetic
+// event in root coordinates, so the position is read from the X server.
+func (t *Tracker) configured(ev xevent.ConfigureNotifyEvent) {
+	w := t.win
+	x, y := int(ev.X), int(ev.Y)
+
+	t.lck.Lock()
+	moved := x != w.Geom.X() || y != w.Geom.Y()
+	child := t.parent != w.X.RootWin()
+	t.lck.Unlock()
+
+	if moved && child {
+		geom, err := xproto.GetGeometry(w.X.Conn(),
+			xproto.Drawable(w.Id)).Reply()
+		if err != nil {
+			// The window is probably gone, which DestroyNotify takes
+			// care of.
+			return
+		}
+		x, y = int(geom.X), int(geom.Y)
+	}
+	t.geometry(x, y, int(ev.Width), int(ev.Height))
+}
+
+// reparented stores the new parent and position from a ReparentNotify
+// event. The size doesn't change.
+func (t *Tracker) reparented(ev xevent.ReparentNotifyEvent) {
+	w := t.win
+
+	t.lck.Lock()
+	t.parent = ev.Parent
+	width, height := w.Geom.Width(), w.Geom.Height()
+	t.lck.Unlock()
+
+	t.geometry(int(ev.X), int(ev.Y), width, height)
+}
+
+// geometry stores a new geometry and runs the GeometryFun handlers if it
+// changed.
+func (t *Tracker) geometry(x, y, width, height int) {
+	w := t.win
+
+	t.lck.Lock()
+	old := xrect.New(xrect.Pieces(w.Geom))
+	changed := old.X() != x || old.Y() != y ||
+		old.Width() != width || old.Height() != height
+	if changed {
+		w.Geom = xrect.New(x, y, width, height)
+	}
+	funs := t.geomFuns
+	t.lck.Unlock()
+
+	if !changed {
+		return
+	}
+	for _, f := range funs {
+		f(w, old)
+	}
+}
+
+// mappedSet stores the mapped state and runs the MapFun handlers if it
+// changed.
+func (t *Tracker) mappedSet(mapped bool) {
+	t.lck.Lock()
+	changed := t.mapped != mapped
+	t.mapped = mapped
+	funs := t.mapFuns
+	t.lck.Unlock()
+
+	if !changed {
+		return
+	}
+	for _, f := range funs {
+		f(t.win, mapped)
+	}
+}
+
+// property reloads a tracked property after a PropertyNotify event and runs
+// the PropertyFun handlers.
+func (t *Tracker) property(ev xevent.PropertyNotifyEvent) {
+	w := t.win
+	name, err := xprop.AtomName(w.X, ev.Atom)
+	if err != nil || !t.tracked(name) {
+		return
+	}
+
+	var reply *xproto.GetPropertyReply
+	if ev.State == xproto.PropertyNewValue {
+		reply, err = xproto.GetProperty(w.X.Conn(), false, w.Id, ev.Atom,
+			xproto.GetPropertyTypeAny, 0, (1<<32)-1).Reply()
+		if err != nil {
+			xgbutil.Logger.Printf("Could not reload property '%s' of "+
+				"window %x: %s", name, w.Id, err)
+			return
+		}
+		if reply.Format == 0 {
+			reply = nil
+		}
+	}
+
+	t.lck.Lock()
+	t.props[name] = reply
+	funs := t.propFuns
+	t.lck.Unlock()
+
+	for _, f := range funs {
+		f(w, name)
+	}
+}
+
+// destroyed records that the window was destroyed, turns tracking off and
+// runs the DestroyFun handlers.
+func (t *Tracker) destroyed() {
+	w := t.win
+	w.Destroyed = true
+	t.Stop()
+
+	t.lck.Lock()
+	t.mapped = false
+	funs := t.destroyFuns
+	t.lck.Unlock()
+
+	for _, f := range funs {
+		f(w)
+	}
+}
@@ -21,6 +21,12 @@ type Window struct {
 	Id        xproto.Window
 	Geom      xrect.Rect
 	Destroyed bool
+
+	// protocols is the WM_PROTOCOLS manager, created by Protocols.
+	protocols *Protocols
+
+	// tracker keeps the window's state current, and is created by Track.
+	tracker *Tracker
 }
 
 // New creates a new window value from a window id and an XUtil type.
@@ -144,6 +150,23 @@ func (w *Window) Listen(evMasks ...int) error {
 		xproto.CwEventMask, []uint32{uint32(evMask)}).Check()
 }
 
+// ListenAdd is just like Listen, except it adds the event masks provided to
+// the events this client has already selected on the window instead of
+// replacing them. This is useful when several independent pieces of code
+// need to listen to events on the same window (like the root window).
+func (w *Window) ListenAdd(evMasks ...int) error {
+	attrs, err := xproto.GetWindowAttributes(w.X.Conn(), w.Id).Reply()
+	if err != nil {
+		return err
+	}
+
+	evMask := int(attrs.YourEventMask)
+	for _, mask := range evMasks {
+		evMask |= mask
+	}
+	return w.Listen(evMask)
+}
+
 // Geometry retrieves an up-to-date version of the this window's geometry.
 // It also loads the geometry into the Geom member of Window.
 func (w *Window) Geometry() (xrect.Rect, error) {
@@ -331,11 +354,14 @@ func (w *Window) Destroy() {
 }
 
 // Detach will detach this window's event handlers from all xevent, keybind
-// and mousebind callbacks.
+// and mousebind callbacks, along with its WM_PROTOCOLS handlers. It also
+// turns off the tracked mode of the window (see Track).
 func (w *Window) Detach() {
 	keybind.Detach(w.X, w.Id)
 	mousebind.Detach(w.X, w.Id)
 	xevent.Detach(w.X, w.Id)
+	w.protocols = nil
+	w.tracker = nil
 }
 
 // Focus tries to issue a SetInputFocus to get the focus.